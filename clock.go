@@ -0,0 +1,23 @@
+package gofs
+
+import "time"
+
+type (
+	// clock is the source of the current time of the in-memory database (the upload creation time)
+	// and the uploader (the age of the stale uploads), it's replaced in tests to control the time.
+	// SQLDB has none: it keeps no timestamps.
+	clock interface {
+		Now() time.Time
+	}
+
+	// clockFunc adapts a function to the clock interface.
+	clockFunc func() time.Time
+)
+
+// systemClock returns the current local time.
+var systemClock clock = clockFunc(time.Now)
+
+// Now returns the current time.
+func (f clockFunc) Now() time.Time {
+	return f()
+}
@@ -0,0 +1,73 @@
+package gofs
+
+import "context"
+
+// contextDB adapts the DB without the context support to ContextDB, the context is ignored.
+type contextDB struct {
+	DB
+}
+
+// withContext returns the db itself if it implements ContextDB and the adapter otherwise.
+func withContext(db DB) ContextDB {
+	if cdb, ok := db.(ContextDB); ok {
+		return cdb
+	}
+
+	return contextDB{DB: db}
+}
+
+func (db contextDB) CreateUploadWithContext(_ context.Context, key string, uploadID string, totalParts int64) error {
+	return db.CreateUpload(key, uploadID, totalParts)
+}
+
+func (db contextDB) UpdateTotalPartsWithContext(_ context.Context, key string, totalParts int64) error {
+	return db.UpdateTotalParts(key, totalParts)
+}
+
+func (db contextDB) AddPartWithContext(_ context.Context, key string, partNumber int64, etag string, size int64) error {
+	return db.AddPart(key, partNumber, etag, size)
+}
+
+func (db contextDB) CompleteUploadWithContext(_ context.Context, key string) error {
+	return db.CompleteUpload(key)
+}
+
+func (db contextDB) AbortUploadWithContext(_ context.Context, key string) error {
+	return db.AbortUpload(key)
+}
+
+func (db contextDB) GetUploadIDWithContext(_ context.Context, key string) (string, error) {
+	return db.GetUploadID(key)
+}
+
+func (db contextDB) GetPartsWithContext(_ context.Context, key string) ([]CompletedPart, error) {
+	return db.GetParts(key)
+}
+
+func (db contextDB) GetStatusWithContext(_ context.Context, key string) (UploadStatus, error) {
+	return db.GetStatus(key)
+}
+
+func (db contextDB) ListUploadsWithContext(_ context.Context) ([]string, error) {
+	return db.ListUploads()
+}
+
+// addPartIfAbsent adds the part with the compare-and-set semantics if the db implements ConcurrentDB,
+// so the part recorded by another instance with another ETag fails with ErrPartConflict.
+// Other databases add the part with AddPart.
+func addPartIfAbsent(ctx context.Context, db ContextDB, key string, partNumber int64, etag string, size int64) error {
+	switch cdb := db.(type) {
+	case interface {
+		AddPartIfAbsentWithContext(ctx context.Context, key string, partNumber int64, etag string, size int64) error
+	}:
+		return cdb.AddPartIfAbsentWithContext(ctx, key, partNumber, etag, size)
+	case contextDB:
+		if concurrent, ok := cdb.DB.(ConcurrentDB); ok {
+			return concurrent.AddPartIfAbsent(key, partNumber, etag, size)
+		}
+	case ConcurrentDB:
+		return cdb.AddPartIfAbsent(key, partNumber, etag, size)
+	}
+
+	return db.AddPartWithContext(ctx, key, partNumber, etag, size)
+}
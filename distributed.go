@@ -0,0 +1,181 @@
+package gofs
+
+import (
+	"context"
+	"io"
+	"strings"
+
+	"github.com/dmitrymomot/gofs/storage"
+)
+
+// PartRange returns the offset and the length of the part with the given number
+// of the file of the given size split into the parts of partSize,
+// so the workers of a distributed upload can read their byte ranges.
+// The length is zero if the part is out of the file.
+func PartRange(size, partSize, partNum int64) (offset, length int64) {
+	if size <= 0 || partSize <= 0 || partNum < 1 {
+		return 0, 0
+	}
+
+	offset = (partNum - 1) * partSize
+	if offset >= size {
+		return 0, 0
+	}
+	length = partSize
+	if offset+length > size {
+		length = size - offset
+	}
+
+	return offset, length
+}
+
+// NextPartFunc returns the next part of the file: its number, the reader of its content and its size.
+// ok is false once all the parts are returned.
+type NextPartFunc func() (partNum int64, r io.Reader, size int64, ok bool)
+
+// PartReader splits the file of fileSize into the sequential parts of partSize, the last part can be smaller.
+// The parts are read from the file on demand, so the file is never held in memory as a whole.
+// The readers of the parts are independent and can be read concurrently.
+func PartReader(file io.ReaderAt, partSize, fileSize int64) NextPartFunc {
+	var partNum int64
+	return func() (int64, io.Reader, int64, bool) {
+		offset, length := PartRange(fileSize, partSize, partNum+1)
+		if length == 0 {
+			return 0, nil, 0, false
+		}
+		partNum++
+
+		return partNum, io.NewSectionReader(file, offset, length), length, true
+	}
+}
+
+// StartUpload starts a distributed upload of totalParts parts to the given key
+// and records it in the database. The parts are uploaded by UploadPart in any order,
+// e.g. by several workers sharing the database, each handling its own part numbers,
+// and the upload is completed by CompleteIfReady.
+// The part numbers must be contiguous: the upload is completed only once every part
+// from 1 to totalParts is recorded, and every part but the last must be at least storage.MinPartSize.
+// Returns ErrAlreadyExists if an upload to the key is already in progress.
+func (u *Uploader) StartUpload(ctx context.Context, key string, totalParts int64, contentType string, acl storage.ACL) (string, error) {
+	if key == "" {
+		return "", ErrFileKeyEmpty
+	}
+	if totalParts <= 0 || totalParts > storage.MaxParts {
+		return "", ErrInvalidTotalParts
+	}
+
+	var uploadID string
+	if err := u.retry(ctx, func() (err error) {
+		uploadID, err = u.storage.CreateMultipartUploadWithContext(ctx, key, storage.UploadOptions{ACL: acl, ContentType: contentType})
+		return err
+	}); err != nil {
+		return "", err
+	}
+
+	if err := u.db.CreateUploadWithContext(ctx, key, uploadID, totalParts); err != nil {
+		u.abortFailedUpload(key, uploadID)
+		return "", err
+	}
+
+	return uploadID, nil
+}
+
+// UploadPart uploads the part with the given number of the upload started by StartUpload
+// and records it in the database. The part uploaded again with the same content is recorded once:
+// if the database implements ConcurrentDB, the part recorded by another worker with another ETag
+// fails with ErrPartConflict, and the record is updated to the part the storage keeps,
+// so the upload can still be completed. Returns ErrNotFound if there is no such upload in progress
+// and storage.ErrPartNum if the part number is out of the upload.
+func (u *Uploader) UploadPart(ctx context.Context, key string, partNum int64, data []byte) error {
+	uploadID, err := u.db.GetUploadIDWithContext(ctx, key)
+	if err != nil {
+		return err
+	}
+	status, err := u.db.GetStatusWithContext(ctx, key)
+	if err != nil {
+		return err
+	}
+	if partNum < 1 || partNum > status.TotalParts() {
+		return storage.ErrPartNum
+	}
+
+	_, err = u.uploadPart(ctx, key, uploadID, data, partNum, status.TotalParts(), recordIfAbsent)
+	return err
+}
+
+// CompleteIfReady completes the upload started by StartUpload if all its parts are recorded in the database.
+// It returns false without an error if any part is still missed.
+// Call it from a single coordinator: concurrent calls may try to complete the same upload twice.
+func (u *Uploader) CompleteIfReady(ctx context.Context, key string) (bool, error) {
+	uploadID, err := u.db.GetUploadIDWithContext(ctx, key)
+	if err != nil {
+		return false, err
+	}
+	status, err := u.db.GetStatusWithContext(ctx, key)
+	if err != nil {
+		return false, err
+	}
+	if !status.IsCompleted() {
+		return false, nil
+	}
+
+	dbParts, err := u.db.GetPartsWithContext(ctx, key)
+	if err != nil {
+		return false, err
+	}
+	parts := make([]storage.CompletedPart, 0, status.TotalParts())
+	for _, part := range dbParts {
+		if part.PartNumber() <= status.TotalParts() {
+			parts = append(parts, part)
+		}
+	}
+
+	if err := u.retry(ctx, func() error {
+		_, err := u.storage.CompleteMultipartUploadWithContext(ctx, key, uploadID, parts...)
+		return err
+	}); err != nil {
+		return false, err
+	}
+
+	if err := u.db.CompleteUploadWithContext(ctx, key); err != nil {
+		return false, err
+	}
+
+	return true, nil
+}
+
+// VerifyParts checks the parts of the upload recorded in the database against the parts in the storage
+// before the irreversible completion, to catch the database which went out of sync with the storage.
+// Returns *PartsMismatchError with the numbers of the parts missed in the storage or having another ETag.
+// The parts in the storage which aren't recorded in the database are ignored, they aren't completed anyway.
+func (u *Uploader) VerifyParts(ctx context.Context, key string) error {
+	uploadID, err := u.db.GetUploadIDWithContext(ctx, key)
+	if err != nil {
+		return err
+	}
+	dbParts, err := u.db.GetPartsWithContext(ctx, key)
+	if err != nil {
+		return err
+	}
+	report, err := u.storage.PartsReport(key, uploadID)
+	if err != nil {
+		return err
+	}
+
+	stored := make(map[int64]string, len(report))
+	for _, part := range report {
+		stored[part.PartNumber] = strings.Trim(part.ETag, `"`)
+	}
+
+	var mismatched []int64
+	for _, part := range dbParts {
+		if etag, ok := stored[part.PartNumber()]; !ok || etag != strings.Trim(part.ETag(), `"`) {
+			mismatched = append(mismatched, part.PartNumber())
+		}
+	}
+	if len(mismatched) > 0 {
+		return &PartsMismatchError{Key: key, PartNumbers: mismatched}
+	}
+
+	return nil
+}
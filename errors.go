@@ -1,11 +1,38 @@
 package gofs
 
-import "errors"
+import (
+	"errors"
+	"fmt"
+)
 
 // Predefined errors.
 var (
-	ErrNotFound          = errors.New("not found")
-	ErrAlreadyExists     = errors.New("already exists")
-	ErrFileKeyEmpty      = errors.New("file uploading key cannot be empty")
-	ErrInvalidTotalParts = errors.New("total parts must be greater than zero and not more than 10000")
+	ErrNotFound           = errors.New("not found")
+	ErrAlreadyExists      = errors.New("already exists")
+	ErrFileKeyEmpty       = errors.New("file uploading key cannot be empty")
+	ErrInvalidTotalParts  = errors.New("total parts must be greater than zero and not more than 10000")
+	ErrUnsupportedDialect = errors.New("unsupported sql dialect")
+	ErrIncompleteUpload   = errors.New("upload has missing parts")
+	ErrTotalPartsTooSmall = errors.New("total parts cannot be less than the uploaded part numbers")
+	ErrPartConflict       = errors.New("part is already added with another etag")
+	ErrUploaderDraining   = errors.New("uploader is draining, the upload can be resumed later")
+	ErrPartsMismatch      = errors.New("parts in the database don't match the parts in the storage")
 )
+
+// PartsMismatchError is returned by VerifyParts when the parts recorded in the database
+// are missed in the storage or have another ETag there, e.g. the part was re-uploaded
+// by another instance. It matches ErrPartsMismatch with errors.Is.
+type PartsMismatchError struct {
+	Key         string
+	PartNumbers []int64
+}
+
+// Error returns the error message.
+func (e *PartsMismatchError) Error() string {
+	return fmt.Sprintf("%s: key %s, part numbers %v", ErrPartsMismatch, e.Key, e.PartNumbers)
+}
+
+// Is reports whether the target is ErrPartsMismatch.
+func (e *PartsMismatchError) Is(target error) bool {
+	return target == ErrPartsMismatch
+}
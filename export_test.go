@@ -0,0 +1,20 @@
+package gofs
+
+import "time"
+
+// SetClock replaces the clock of the in-memory database or the uploader with the given time source.
+func SetClock(v interface{}, now func() time.Time) {
+	switch v := v.(type) {
+	case *inMemoryDB:
+		v.clock = clockFunc(now)
+	case *Uploader:
+		v.clock = clockFunc(now)
+	default:
+		panic("gofs: SetClock: unsupported type")
+	}
+}
+
+// FreeBuffers returns the number of the free part buffers kept by the uploader.
+func FreeBuffers(u *Uploader) int {
+	return len(u.buffers.free)
+}
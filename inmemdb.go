@@ -1,12 +1,17 @@
 package gofs
 
-import "sync"
+import (
+	"sort"
+	"sync"
+	"time"
+)
 
 type (
 	// InMemoryDB is an in-memory implementation of the DB interface.
 	inMemoryDB struct {
 		sync.RWMutex
 		records map[string]inMemoryRecord
+		clock   clock
 	}
 
 	// Represents a record in the database. It has an uploadID string field, an integer totalParts field indicating how many parts the record is split into, and a map from part numbers to inMemoryPart values (parts).
@@ -14,19 +19,29 @@ type (
 		uploadID   string
 		totalParts int64
 		parts      map[int64]inMemoryPart
+		createdAt  time.Time
 	}
 
-	// Represents a single part of a larger record. It has a partNumber integer field and an eTag string field.
+	// Represents a single part of a larger record. It has a partNumber integer field, an eTag string field
+	// and a size integer field with the size of the part in bytes.
 	inMemoryPart struct {
 		partNumber int64
 		eTag       string
+		size       int64
 	}
 )
 
+var (
+	_ ConcurrentDB = (*inMemoryDB)(nil)
+	_ SizedPart    = inMemoryPart{}
+	_ SizedStatus  = inMemoryRecord{}
+)
+
 // NewInMemoryDB creates a new in-memory database.
 func NewInMemoryDB() DB {
 	return &inMemoryDB{
 		records: make(map[string]inMemoryRecord),
+		clock:   systemClock,
 	}
 }
 
@@ -53,6 +68,7 @@ func (db *inMemoryDB) CreateUpload(key string, uploadID string, totalParts int64
 		uploadID:   uploadID,
 		totalParts: totalParts,
 		parts:      make(map[int64]inMemoryPart),
+		createdAt:  db.clock.Now(),
 	}
 
 	db.records[key] = record // Add the new record to the database
@@ -60,9 +76,36 @@ func (db *inMemoryDB) CreateUpload(key string, uploadID string, totalParts int64
 	return nil // Return nil error indicating that the operation was successful
 }
 
-// AddPart is a method of the inMemoryDB struct that takes in a key (string), a partNumber (int64) and an eTag (string)
-// and returns an error.
-func (db *inMemoryDB) AddPart(key string, partNumber int64, eTag string) error {
+// UpdateTotalParts changes the totalParts (int64) of the upload with the given key (string).
+// The new value can't be less than the number of any uploaded part.
+func (db *inMemoryDB) UpdateTotalParts(key string, totalParts int64) error {
+	if totalParts <= 0 || totalParts > 10000 {
+		return ErrInvalidTotalParts
+	}
+
+	db.Lock()
+	defer db.Unlock()
+
+	record, ok := db.records[key]
+	if !ok {
+		return ErrNotFound
+	}
+
+	for partNumber := range record.parts {
+		if partNumber > totalParts {
+			return ErrTotalPartsTooSmall
+		}
+	}
+
+	record.totalParts = totalParts
+	db.records[key] = record
+
+	return nil
+}
+
+// AddPart is a method of the inMemoryDB struct that takes in a key (string), a partNumber (int64), an eTag (string)
+// and a size (int64) and returns an error.
+func (db *inMemoryDB) AddPart(key string, partNumber int64, eTag string, size int64) error {
 	db.Lock()
 	defer db.Unlock()
 
@@ -74,6 +117,7 @@ func (db *inMemoryDB) AddPart(key string, partNumber int64, eTag string) error {
 	record.parts[partNumber] = inMemoryPart{
 		partNumber: partNumber,
 		eTag:       eTag,
+		size:       size,
 	}
 
 	db.records[key] = record
@@ -81,19 +125,53 @@ func (db *inMemoryDB) AddPart(key string, partNumber int64, eTag string) error {
 	return nil
 }
 
+// AddPartIfAbsent adds the part unless the part with the same number is already added.
+// It returns ErrPartConflict if the added part has another eTag.
+func (db *inMemoryDB) AddPartIfAbsent(key string, partNumber int64, eTag string, size int64) error {
+	db.Lock()
+	defer db.Unlock()
+
+	record, ok := db.records[key]
+	if !ok {
+		return ErrNotFound
+	}
+
+	if part, ok := record.parts[partNumber]; ok {
+		if part.eTag != eTag {
+			return ErrPartConflict
+		}
+		return nil
+	}
+
+	record.parts[partNumber] = inMemoryPart{
+		partNumber: partNumber,
+		eTag:       eTag,
+		size:       size,
+	}
+
+	return nil
+}
+
 // CompleteUpload is a method of the struct inMemoryDB that takes in a key (string) and completes the corresponding upload.
-// It returns an error if the operation was unsuccessful, specifically if the key was not found in the records.
+// It returns an error if the operation was unsuccessful, specifically if the key was not found in the records
+// or some parts of the upload are missed.
 func (db *inMemoryDB) CompleteUpload(key string) error {
 	// acquire a write lock on the database to protect against concurrent access
 	db.Lock()
 	defer db.Unlock()
 
 	// check if the given key exists in the records map
-	if _, ok := db.records[key]; !ok {
+	record, ok := db.records[key]
+	if !ok {
 		// return an error indicating that the record was not found
 		return ErrNotFound
 	}
 
+	// check if all the parts are uploaded, the lock keeps them from changing meanwhile
+	if !record.IsCompleted() {
+		return ErrIncompleteUpload
+	}
+
 	// remove the record associated with the given key from the records map
 	delete(db.records, key)
 
@@ -131,7 +209,7 @@ func (db *inMemoryDB) GetUploadID(key string) (string, error) {
 }
 
 // GetParts is a method of the inMemoryDB struct that takes in a key (string)
-// and returns a slice of CompletedPart interface and an error.
+// and returns a slice of CompletedPart interface sorted by part number and an error.
 func (db *inMemoryDB) GetParts(key string) ([]CompletedPart, error) {
 	db.RLock()
 	defer db.RUnlock()
@@ -141,12 +219,7 @@ func (db *inMemoryDB) GetParts(key string) ([]CompletedPart, error) {
 		return nil, ErrNotFound
 	}
 
-	parts := make([]CompletedPart, 0, len(record.parts))
-	for _, part := range record.parts {
-		parts = append(parts, part)
-	}
-
-	return parts, nil
+	return record.CompletedParts(), nil
 }
 
 // GetStatus returns the status of the upload.
@@ -162,6 +235,25 @@ func (db *inMemoryDB) GetStatus(key string) (UploadStatus, error) {
 	return record, nil
 }
 
+// ListUploads returns the keys of all in-progress uploads, sorted in ascending order.
+func (db *inMemoryDB) ListUploads() ([]string, error) {
+	db.RLock()
+	defer db.RUnlock()
+
+	keys := make([]string, 0, len(db.records))
+	for key := range db.records {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	return keys, nil
+}
+
+// Close does nothing, there are no resources to release.
+func (db *inMemoryDB) Close() error {
+	return nil
+}
+
 // PartNumber returns the part number.
 // Part numbers start at 1.
 func (part inMemoryPart) PartNumber() int64 {
@@ -174,9 +266,25 @@ func (part inMemoryPart) ETag() string {
 	return part.eTag
 }
 
-// IsCompleted returns true if the upload is completed.
+// Size returns the size of the part in bytes.
+func (part inMemoryPart) Size() int64 {
+	return part.size
+}
+
+// IsCompleted returns true if the upload is completed:
+// every part number from 1 to totalParts is present.
 func (record inMemoryRecord) IsCompleted() bool {
-	return int64(len(record.parts)) == record.totalParts
+	if int64(len(record.parts)) < record.totalParts {
+		return false
+	}
+
+	for partNumber := int64(1); partNumber <= record.totalParts; partNumber++ {
+		if _, ok := record.parts[partNumber]; !ok {
+			return false
+		}
+	}
+
+	return true
 }
 
 // TotalParts returns the total number of parts in the upload.
@@ -184,16 +292,24 @@ func (record inMemoryRecord) TotalParts() int64 {
 	return record.totalParts
 }
 
-// Parts returns the parts that have been uploaded.
+// Parts returns the parts that have been uploaded, sorted by part number.
 func (record inMemoryRecord) CompletedParts() []CompletedPart {
 	parts := make([]CompletedPart, 0, len(record.parts))
 	for _, part := range record.parts {
 		parts = append(parts, part)
 	}
+	sort.Slice(parts, func(i, j int) bool {
+		return parts[i].PartNumber() < parts[j].PartNumber()
+	})
 
 	return parts
 }
 
+// CreatedAt returns the time the upload was created.
+func (record inMemoryRecord) CreatedAt() time.Time {
+	return record.createdAt
+}
+
 // UploadID returns the upload ID.
 func (record inMemoryRecord) UploadID() string {
 	return record.uploadID
@@ -203,3 +319,13 @@ func (record inMemoryRecord) UploadID() string {
 func (record inMemoryRecord) CompletedPartsNum() int64 {
 	return int64(len(record.parts))
 }
+
+// UploadedBytes returns the total size of the completed parts.
+func (record inMemoryRecord) UploadedBytes() int64 {
+	var total int64
+	for _, part := range record.parts {
+		total += part.size
+	}
+
+	return total
+}
@@ -0,0 +1,164 @@
+package gofs_test
+
+import (
+	"testing"
+	"time"
+
+	"github.com/dmitrymomot/gofs"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestInMemoryDBIsCompleted(t *testing.T) {
+	db := gofs.NewInMemoryDB()
+
+	t.Run("Test Case 1 - All parts", func(t *testing.T) {
+		require.NoError(t, db.CreateUpload("complete.txt", "upload-id", 3))
+		for partNumber := int64(1); partNumber <= 3; partNumber++ {
+			require.NoError(t, db.AddPart("complete.txt", partNumber, "etag", 5))
+		}
+
+		status, err := db.GetStatus("complete.txt")
+		require.NoError(t, err)
+		assert.True(t, status.IsCompleted())
+	})
+
+	t.Run("Test Case 2 - Gap in part numbers", func(t *testing.T) {
+		require.NoError(t, db.CreateUpload("gap.txt", "upload-id", 3))
+		for _, partNumber := range []int64{1, 2, 4} {
+			require.NoError(t, db.AddPart("gap.txt", partNumber, "etag", 5))
+		}
+
+		status, err := db.GetStatus("gap.txt")
+		require.NoError(t, err)
+		assert.Equal(t, int64(3), status.CompletedPartsNum())
+		assert.False(t, status.IsCompleted())
+	})
+}
+
+func TestInMemoryDBGetParts(t *testing.T) {
+	db := gofs.NewInMemoryDB()
+	require.NoError(t, db.CreateUpload("sorted.txt", "upload-id", 5))
+	for _, partNumber := range []int64{4, 1, 5, 3, 2} {
+		require.NoError(t, db.AddPart("sorted.txt", partNumber, "etag", 5))
+	}
+
+	parts, err := db.GetParts("sorted.txt")
+	require.NoError(t, err)
+	require.Len(t, parts, 5)
+	for i, part := range parts {
+		assert.Equal(t, int64(i+1), part.PartNumber())
+	}
+}
+
+func TestInMemoryDBCreatedAt(t *testing.T) {
+	db := gofs.NewInMemoryDB()
+
+	now := time.Date(2024, 6, 15, 12, 0, 0, 0, time.UTC)
+	gofs.SetClock(db, func() time.Time { return now })
+
+	require.NoError(t, db.CreateUpload("file.txt", "upload-id", 3))
+
+	status, err := db.GetStatus("file.txt")
+	require.NoError(t, err)
+
+	record, ok := status.(interface{ CreatedAt() time.Time })
+	require.True(t, ok)
+	assert.Equal(t, now, record.CreatedAt())
+}
+
+func TestInMemoryDBCompleteUpload(t *testing.T) {
+	db := gofs.NewInMemoryDB()
+
+	require.NoError(t, db.CreateUpload("file.txt", "upload-id", 2))
+	require.NoError(t, db.AddPart("file.txt", 1, "etag", 5))
+
+	// The upload with a missed part is left in progress.
+	assert.ErrorIs(t, db.CompleteUpload("file.txt"), gofs.ErrIncompleteUpload)
+	_, err := db.GetUploadID("file.txt")
+	require.NoError(t, err)
+
+	require.NoError(t, db.AddPart("file.txt", 2, "etag", 5))
+	require.NoError(t, db.CompleteUpload("file.txt"))
+
+	assert.ErrorIs(t, db.CompleteUpload("file.txt"), gofs.ErrNotFound)
+}
+
+func TestInMemoryDBUpdateTotalParts(t *testing.T) {
+	db := gofs.NewInMemoryDB()
+
+	require.NoError(t, db.CreateUpload("file.txt", "upload-id", 2))
+	require.NoError(t, db.AddPart("file.txt", 1, "etag", 5))
+	require.NoError(t, db.AddPart("file.txt", 2, "etag", 5))
+
+	assert.ErrorIs(t, db.UpdateTotalParts("file.txt", 1), gofs.ErrTotalPartsTooSmall)
+	assert.ErrorIs(t, db.UpdateTotalParts("file.txt", 0), gofs.ErrInvalidTotalParts)
+	assert.ErrorIs(t, db.UpdateTotalParts("missed.txt", 3), gofs.ErrNotFound)
+
+	require.NoError(t, db.UpdateTotalParts("file.txt", 3))
+	status, err := db.GetStatus("file.txt")
+	require.NoError(t, err)
+	assert.Equal(t, int64(3), status.TotalParts())
+	assert.False(t, status.IsCompleted())
+}
+
+func TestInMemoryDBAddPartIfAbsent(t *testing.T) {
+	db, ok := gofs.NewInMemoryDB().(gofs.ConcurrentDB)
+	require.True(t, ok)
+
+	require.NoError(t, db.CreateUpload("file.txt", "upload-id", 2))
+	require.NoError(t, db.AddPartIfAbsent("file.txt", 1, "etag-1", 5))
+	require.NoError(t, db.AddPartIfAbsent("file.txt", 1, "etag-1", 5))
+	assert.ErrorIs(t, db.AddPartIfAbsent("file.txt", 1, "etag-2", 5), gofs.ErrPartConflict)
+	assert.ErrorIs(t, db.AddPartIfAbsent("missed.txt", 1, "etag-1", 5), gofs.ErrNotFound)
+
+	parts, err := db.GetParts("file.txt")
+	require.NoError(t, err)
+	require.Len(t, parts, 1)
+	assert.Equal(t, "etag-1", parts[0].ETag())
+}
+
+func TestInMemoryDBPartSizes(t *testing.T) {
+	db := gofs.NewInMemoryDB()
+
+	require.NoError(t, db.CreateUpload("file.txt", "upload-id", 3))
+	require.NoError(t, db.AddPart("file.txt", 1, "etag-1", 10))
+	require.NoError(t, db.AddPart("file.txt", 2, "etag-2", 10))
+	require.NoError(t, db.AddPart("file.txt", 2, "etag-2", 7))
+
+	parts, err := db.GetParts("file.txt")
+	require.NoError(t, err)
+	require.Len(t, parts, 2)
+	part, ok := parts[1].(gofs.SizedPart)
+	require.True(t, ok)
+	assert.Equal(t, int64(7), part.Size())
+
+	status, err := db.GetStatus("file.txt")
+	require.NoError(t, err)
+	sized, ok := status.(gofs.SizedStatus)
+	require.True(t, ok)
+	assert.Equal(t, int64(17), sized.UploadedBytes())
+}
+
+func TestInMemoryDBListUploads(t *testing.T) {
+	db := gofs.NewInMemoryDB()
+
+	uploads, err := db.ListUploads()
+	require.NoError(t, err)
+	assert.Empty(t, uploads)
+
+	for _, key := range []string{"c.txt", "a.txt", "b.txt"} {
+		require.NoError(t, db.CreateUpload(key, "upload-id", 1))
+	}
+	require.NoError(t, db.AddPart("b.txt", 1, "etag", 5))
+	require.NoError(t, db.CompleteUpload("b.txt"))
+
+	uploads, err = db.ListUploads()
+	require.NoError(t, err)
+	assert.Equal(t, []string{"a.txt", "c.txt"}, uploads)
+
+	require.NoError(t, db.AbortUpload("a.txt"))
+	uploads, err = db.ListUploads()
+	require.NoError(t, err)
+	assert.Equal(t, []string{"c.txt"}, uploads)
+}
@@ -0,0 +1,69 @@
+package gofs
+
+import (
+	"math/rand"
+	"sync"
+	"time"
+)
+
+// Jitter strategies of the retry backoff.
+// See https://aws.amazon.com/blogs/architecture/exponential-backoff-and-jitter/
+const (
+	// JitterFull waits a random delay between zero and the backoff.
+	// It spreads the retries of the concurrent requests the most, it's the default.
+	JitterFull JitterStrategy = "full"
+	// JitterEqual waits half of the backoff plus a random delay up to the other half,
+	// so the delay never drops much below the backoff.
+	JitterEqual JitterStrategy = "equal"
+	// JitterNone waits exactly the backoff.
+	JitterNone JitterStrategy = "none"
+)
+
+// JitterStrategy randomizes the delays between the retries,
+// so the requests failed at once, e.g. throttled, aren't retried at once as well.
+type JitterStrategy string
+
+// jitterRand is the random source of the delays, seeded once per process.
+var jitterRand = struct {
+	sync.Mutex
+	*rand.Rand
+}{Rand: rand.New(rand.NewSource(time.Now().UnixNano()))}
+
+// String returns the string representation of the jitter strategy.
+func (s JitterStrategy) String() string {
+	return string(s)
+}
+
+// IsValid reports whether the strategy is one of the predefined ones.
+func (s JitterStrategy) IsValid() bool {
+	switch s {
+	case JitterFull, JitterEqual, JitterNone:
+		return true
+	}
+
+	return false
+}
+
+// Delay returns the randomized delay before the retry with the given backoff.
+func (s JitterStrategy) Delay(backoff time.Duration) time.Duration {
+	if backoff <= 0 {
+		return 0
+	}
+
+	switch s {
+	case JitterNone:
+		return backoff
+	case JitterEqual:
+		return backoff/2 + randDuration(backoff-backoff/2)
+	default:
+		return randDuration(backoff)
+	}
+}
+
+// randDuration returns a random duration in [0, max].
+func randDuration(max time.Duration) time.Duration {
+	jitterRand.Lock()
+	defer jitterRand.Unlock()
+
+	return time.Duration(jitterRand.Int63n(int64(max) + 1))
+}
@@ -0,0 +1,462 @@
+package gofs
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"strconv"
+	"strings"
+)
+
+// Supported SQL dialects
+const (
+	Postgres Dialect = "postgres"
+	MySQL    Dialect = "mysql"
+)
+
+// Table names used by the SQL database.
+const (
+	sqlUploadsTable = "gofs_uploads"
+	sqlPartsTable   = "gofs_upload_parts"
+)
+
+type (
+	// Dialect is the SQL dialect of the database.
+	Dialect string
+
+	// SQLDB is the SQL implementation of the DB interface.
+	// It works with any database/sql driver of the supported dialects,
+	// the driver must be imported by the caller.
+	SQLDB struct {
+		db      *sql.DB
+		dialect Dialect
+		owned   bool // whether the connection is opened by OpenSQLDB
+	}
+)
+
+var (
+	_ ConcurrentDB = (*SQLDB)(nil)
+	_ ContextDB    = (*SQLDB)(nil)
+)
+
+// NewSQLDB creates a new SQL database.
+// Call Migrate to create the tables if they don't exist yet.
+func NewSQLDB(db *sql.DB, dialect Dialect) *SQLDB {
+	return &SQLDB{
+		db:      db,
+		dialect: dialect,
+	}
+}
+
+// OpenSQLDB opens a new connection and creates the SQL database which owns it:
+// the connection is closed by Close.
+func OpenSQLDB(driverName, dataSourceName string, dialect Dialect) (*SQLDB, error) {
+	conn, err := sql.Open(driverName, dataSourceName)
+	if err != nil {
+		return nil, err
+	}
+
+	db := NewSQLDB(conn, dialect)
+	db.owned = true
+
+	return db, nil
+}
+
+// Close closes the underlying connection if it's opened by OpenSQLDB.
+// The connection passed to NewSQLDB is left open, it's managed by the caller.
+func (db *SQLDB) Close() error {
+	if !db.owned {
+		return nil
+	}
+
+	return db.db.Close()
+}
+
+// Migrate creates the uploads tables if they don't exist.
+// It's safe to call it on every application start.
+func (db *SQLDB) Migrate(ctx context.Context) error {
+	var keyType string
+	switch db.dialect {
+	case Postgres:
+		keyType = "TEXT"
+	case MySQL:
+		// VARBINARY fits the 1024 bytes of the S3 key into the index length limit.
+		keyType = "VARBINARY(1024)"
+	default:
+		return ErrUnsupportedDialect
+	}
+
+	queries := []string{
+		`CREATE TABLE IF NOT EXISTS ` + sqlUploadsTable + ` (
+			upload_key ` + keyType + ` NOT NULL,
+			upload_id VARCHAR(1024) NOT NULL,
+			total_parts BIGINT NOT NULL,
+			PRIMARY KEY (upload_key)
+		)`,
+		`CREATE TABLE IF NOT EXISTS ` + sqlPartsTable + ` (
+			upload_key ` + keyType + ` NOT NULL,
+			part_number BIGINT NOT NULL,
+			etag VARCHAR(255) NOT NULL,
+			size BIGINT NOT NULL DEFAULT 0,
+			PRIMARY KEY (upload_key, part_number)
+		)`,
+	}
+	for _, query := range queries {
+		if _, err := db.db.ExecContext(ctx, query); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// CreateUpload creates a new upload with the given key (string), uploadID (string) and totalParts (int64).
+func (db *SQLDB) CreateUpload(key string, uploadID string, totalParts int64) error {
+	return db.CreateUploadWithContext(context.Background(), key, uploadID, totalParts)
+}
+
+// CreateUploadWithContext is CreateUpload with the context.
+func (db *SQLDB) CreateUploadWithContext(ctx context.Context, key string, uploadID string, totalParts int64) error {
+	if key == "" {
+		return ErrFileKeyEmpty
+	}
+	if totalParts <= 0 || totalParts > 10000 {
+		return ErrInvalidTotalParts
+	}
+
+	return db.tx(ctx, func(tx *sql.Tx) error {
+		exists, err := db.exists(ctx, tx, key)
+		if err != nil {
+			return err
+		}
+		if exists {
+			return ErrAlreadyExists
+		}
+
+		_, err = tx.ExecContext(ctx, db.rebind(`INSERT INTO `+sqlUploadsTable+` (upload_key, upload_id, total_parts) VALUES (?, ?, ?)`),
+			key, uploadID, totalParts)
+		if isUniqueViolation(err) {
+			// The upload is created concurrently after the check.
+			return ErrAlreadyExists
+		}
+		return err
+	})
+}
+
+// UpdateTotalParts changes the expected number of parts of the upload.
+// The upload row is locked to keep the parts from being added meanwhile.
+func (db *SQLDB) UpdateTotalParts(key string, totalParts int64) error {
+	return db.UpdateTotalPartsWithContext(context.Background(), key, totalParts)
+}
+
+// UpdateTotalPartsWithContext is UpdateTotalParts with the context.
+func (db *SQLDB) UpdateTotalPartsWithContext(ctx context.Context, key string, totalParts int64) error {
+	if totalParts <= 0 || totalParts > 10000 {
+		return ErrInvalidTotalParts
+	}
+
+	return db.tx(ctx, func(tx *sql.Tx) error {
+		var current int64
+		err := tx.QueryRowContext(ctx, db.rebind(`SELECT total_parts FROM `+sqlUploadsTable+` WHERE upload_key = ? FOR UPDATE`), key).
+			Scan(&current)
+		if errors.Is(err, sql.ErrNoRows) {
+			return ErrNotFound
+		}
+		if err != nil {
+			return err
+		}
+
+		var maxPartNumber int64
+		if err := tx.QueryRowContext(ctx, db.rebind(`SELECT COALESCE(MAX(part_number), 0) FROM `+sqlPartsTable+` WHERE upload_key = ?`), key).
+			Scan(&maxPartNumber); err != nil {
+			return err
+		}
+		if maxPartNumber > totalParts {
+			return ErrTotalPartsTooSmall
+		}
+
+		_, err = tx.ExecContext(ctx, db.rebind(`UPDATE `+sqlUploadsTable+` SET total_parts = ? WHERE upload_key = ?`), totalParts, key)
+		return err
+	})
+}
+
+// AddPart adds the part to the upload, replacing the part with the same number.
+func (db *SQLDB) AddPart(key string, partNumber int64, eTag string, size int64) error {
+	return db.AddPartWithContext(context.Background(), key, partNumber, eTag, size)
+}
+
+// AddPartWithContext is AddPart with the context.
+func (db *SQLDB) AddPartWithContext(ctx context.Context, key string, partNumber int64, eTag string, size int64) error {
+	return db.tx(ctx, func(tx *sql.Tx) error {
+		exists, err := db.exists(ctx, tx, key)
+		if err != nil {
+			return err
+		}
+		if !exists {
+			return ErrNotFound
+		}
+
+		query := `INSERT INTO ` + sqlPartsTable + ` (upload_key, part_number, etag, size) VALUES (?, ?, ?, ?)`
+		if db.dialect == MySQL {
+			query += ` ON DUPLICATE KEY UPDATE etag = VALUES(etag), size = VALUES(size)`
+		} else {
+			query += ` ON CONFLICT (upload_key, part_number) DO UPDATE SET etag = EXCLUDED.etag, size = EXCLUDED.size`
+		}
+
+		_, err = tx.ExecContext(ctx, db.rebind(query), key, partNumber, eTag, size)
+		return err
+	})
+}
+
+// AddPartIfAbsent adds the part unless the part with the same number is already added.
+// The upload row is locked, so the concurrent calls for the same upload are serialized
+// across the instances sharing the database.
+// Returns ErrPartConflict if the added part has another ETag.
+func (db *SQLDB) AddPartIfAbsent(key string, partNumber int64, eTag string, size int64) error {
+	return db.AddPartIfAbsentWithContext(context.Background(), key, partNumber, eTag, size)
+}
+
+// AddPartIfAbsentWithContext is AddPartIfAbsent with the context.
+func (db *SQLDB) AddPartIfAbsentWithContext(ctx context.Context, key string, partNumber int64, eTag string, size int64) error {
+	return db.tx(ctx, func(tx *sql.Tx) error {
+		var n int
+		err := tx.QueryRowContext(ctx, db.rebind(`SELECT 1 FROM `+sqlUploadsTable+` WHERE upload_key = ? FOR UPDATE`), key).Scan(&n)
+		if errors.Is(err, sql.ErrNoRows) {
+			return ErrNotFound
+		}
+		if err != nil {
+			return err
+		}
+
+		var current string
+		err = tx.QueryRowContext(ctx, db.rebind(`SELECT etag FROM `+sqlPartsTable+` WHERE upload_key = ? AND part_number = ?`), key, partNumber).
+			Scan(&current)
+		switch {
+		case err == nil && current == eTag:
+			return nil
+		case err == nil:
+			return ErrPartConflict
+		case !errors.Is(err, sql.ErrNoRows):
+			return err
+		}
+
+		_, err = tx.ExecContext(ctx, db.rebind(`INSERT INTO `+sqlPartsTable+` (upload_key, part_number, etag, size) VALUES (?, ?, ?, ?)`),
+			key, partNumber, eTag, size)
+		return err
+	})
+}
+
+// CompleteUpload removes the completed upload.
+// The parts are verified and the upload is removed in a single transaction,
+// the upload row is locked to keep the parts from changing meanwhile.
+// Returns ErrNotFound if there is no upload with the given key
+// and ErrIncompleteUpload if any part is missed.
+func (db *SQLDB) CompleteUpload(key string) error {
+	return db.CompleteUploadWithContext(context.Background(), key)
+}
+
+// CompleteUploadWithContext is CompleteUpload with the context.
+func (db *SQLDB) CompleteUploadWithContext(ctx context.Context, key string) error {
+	return db.tx(ctx, func(tx *sql.Tx) error {
+		var totalParts int64
+		err := tx.QueryRowContext(ctx, db.rebind(`SELECT total_parts FROM `+sqlUploadsTable+` WHERE upload_key = ? FOR UPDATE`), key).
+			Scan(&totalParts)
+		if errors.Is(err, sql.ErrNoRows) {
+			return ErrNotFound
+		}
+		if err != nil {
+			return err
+		}
+
+		var completedParts int64
+		if err := tx.QueryRowContext(ctx, db.rebind(`SELECT COUNT(*) FROM `+sqlPartsTable+` WHERE upload_key = ? AND part_number BETWEEN 1 AND ?`), key, totalParts).
+			Scan(&completedParts); err != nil {
+			return err
+		}
+		if completedParts < totalParts {
+			return ErrIncompleteUpload
+		}
+
+		return db.delete(ctx, tx, key)
+	})
+}
+
+// AbortUpload removes the aborted upload.
+func (db *SQLDB) AbortUpload(key string) error {
+	return db.AbortUploadWithContext(context.Background(), key)
+}
+
+// AbortUploadWithContext is AbortUpload with the context.
+func (db *SQLDB) AbortUploadWithContext(ctx context.Context, key string) error {
+	return db.tx(ctx, func(tx *sql.Tx) error {
+		return db.delete(ctx, tx, key)
+	})
+}
+
+// GetUploadID returns the upload ID for the given key.
+func (db *SQLDB) GetUploadID(key string) (string, error) {
+	return db.GetUploadIDWithContext(context.Background(), key)
+}
+
+// GetUploadIDWithContext is GetUploadID with the context.
+func (db *SQLDB) GetUploadIDWithContext(ctx context.Context, key string) (string, error) {
+	var uploadID string
+	err := db.db.QueryRowContext(ctx, db.rebind(`SELECT upload_id FROM `+sqlUploadsTable+` WHERE upload_key = ?`), key).Scan(&uploadID)
+	if errors.Is(err, sql.ErrNoRows) {
+		return "", ErrNotFound
+	}
+
+	return uploadID, err
+}
+
+// GetParts returns the parts for the given key.
+func (db *SQLDB) GetParts(key string) ([]CompletedPart, error) {
+	return db.GetPartsWithContext(context.Background(), key)
+}
+
+// GetPartsWithContext is GetParts with the context.
+func (db *SQLDB) GetPartsWithContext(ctx context.Context, key string) ([]CompletedPart, error) {
+	record, err := db.record(ctx, key)
+	if err != nil {
+		return nil, err
+	}
+
+	return record.CompletedParts(), nil
+}
+
+// GetStatus returns the status of the upload.
+func (db *SQLDB) GetStatus(key string) (UploadStatus, error) {
+	return db.GetStatusWithContext(context.Background(), key)
+}
+
+// GetStatusWithContext is GetStatus with the context.
+func (db *SQLDB) GetStatusWithContext(ctx context.Context, key string) (UploadStatus, error) {
+	return db.record(ctx, key)
+}
+
+// ListUploads returns the keys of all in-progress uploads, sorted in ascending order.
+func (db *SQLDB) ListUploads() ([]string, error) {
+	return db.ListUploadsWithContext(context.Background())
+}
+
+// ListUploadsWithContext is ListUploads with the context.
+func (db *SQLDB) ListUploadsWithContext(ctx context.Context) ([]string, error) {
+	rows, err := db.db.QueryContext(ctx, `SELECT upload_key FROM `+sqlUploadsTable+` ORDER BY upload_key`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var keys []string
+	for rows.Next() {
+		var key string
+		if err := rows.Scan(&key); err != nil {
+			return nil, err
+		}
+		keys = append(keys, key)
+	}
+
+	return keys, rows.Err()
+}
+
+// record loads the upload with its parts.
+// The in-memory record type is reused to share the status logic.
+func (db *SQLDB) record(ctx context.Context, key string) (inMemoryRecord, error) {
+	record := inMemoryRecord{parts: make(map[int64]inMemoryPart)}
+
+	err := db.db.QueryRowContext(ctx, db.rebind(`SELECT upload_id, total_parts FROM `+sqlUploadsTable+` WHERE upload_key = ?`), key).
+		Scan(&record.uploadID, &record.totalParts)
+	if errors.Is(err, sql.ErrNoRows) {
+		return record, ErrNotFound
+	}
+	if err != nil {
+		return record, err
+	}
+
+	rows, err := db.db.QueryContext(ctx, db.rebind(`SELECT part_number, etag, size FROM `+sqlPartsTable+` WHERE upload_key = ?`), key)
+	if err != nil {
+		return record, err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var part inMemoryPart
+		if err := rows.Scan(&part.partNumber, &part.eTag, &part.size); err != nil {
+			return record, err
+		}
+		record.parts[part.partNumber] = part
+	}
+
+	return record, rows.Err()
+}
+
+// exists reports whether the upload with the given key exists.
+func (db *SQLDB) exists(ctx context.Context, tx *sql.Tx, key string) (bool, error) {
+	var n int
+	err := tx.QueryRowContext(ctx, db.rebind(`SELECT 1 FROM `+sqlUploadsTable+` WHERE upload_key = ?`), key).Scan(&n)
+	if errors.Is(err, sql.ErrNoRows) {
+		return false, nil
+	}
+
+	return err == nil, err
+}
+
+// isUniqueViolation reports whether err is the unique constraint violation error of the database driver.
+// The drivers aren't imported: the Postgres drivers (lib/pq, pgx) report the SQLSTATE code,
+// the MySQL driver reports the error number in the message.
+func isUniqueViolation(err error) bool {
+	if err == nil {
+		return false
+	}
+
+	var state interface{ SQLState() string }
+	if errors.As(err, &state) {
+		return state.SQLState() == "23505"
+	}
+
+	return strings.Contains(err.Error(), "Error 1062")
+}
+
+// delete removes the upload and its parts.
+func (db *SQLDB) delete(ctx context.Context, tx *sql.Tx, key string) error {
+	if _, err := tx.ExecContext(ctx, db.rebind(`DELETE FROM `+sqlPartsTable+` WHERE upload_key = ?`), key); err != nil {
+		return err
+	}
+	_, err := tx.ExecContext(ctx, db.rebind(`DELETE FROM `+sqlUploadsTable+` WHERE upload_key = ?`), key)
+	return err
+}
+
+// tx runs fn in a transaction, which is committed if fn succeeds and rolled back otherwise.
+func (db *SQLDB) tx(ctx context.Context, fn func(tx *sql.Tx) error) error {
+	tx, err := db.db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+
+	if err := fn(tx); err != nil {
+		_ = tx.Rollback()
+		return err
+	}
+
+	return tx.Commit()
+}
+
+// rebind replaces "?" placeholders with the dialect specific ones.
+func (db *SQLDB) rebind(query string) string {
+	if db.dialect != Postgres {
+		return query
+	}
+
+	var b strings.Builder
+	n := 0
+	for _, r := range query {
+		if r == '?' {
+			n++
+			b.WriteString("$" + strconv.Itoa(n))
+			continue
+		}
+		b.WriteRune(r)
+	}
+
+	return b.String()
+}
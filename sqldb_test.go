@@ -0,0 +1,423 @@
+package gofs_test
+
+import (
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"errors"
+	"io"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/dmitrymomot/gofs"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type (
+	// sqlMock is the database/sql driver replaying the expected statements in order.
+	sqlMock struct {
+		t        *testing.T
+		mu       sync.Mutex
+		expected []sqlExpectation
+	}
+
+	// sqlExpectation is the statement expected by sqlMock and its result.
+	sqlExpectation struct {
+		query   string         // prefix of the statement with the whitespace collapsed
+		args    []driver.Value // nil matches any arguments
+		columns []string
+		rows    [][]driver.Value
+		err     error
+	}
+
+	sqlMockConn struct {
+		mock *sqlMock
+	}
+
+	sqlMockRows struct {
+		columns []string
+		rows    [][]driver.Value
+	}
+
+	// sqlStateError mimics the errors of the Postgres drivers reporting the SQLSTATE code.
+	sqlStateError struct {
+		code string
+	}
+)
+
+var (
+	sqlBegin    = sqlExpectation{query: "BEGIN"}
+	sqlCommit   = sqlExpectation{query: "COMMIT"}
+	sqlRollback = sqlExpectation{query: "ROLLBACK"}
+)
+
+// newSQLMockDB returns the SQL database executing exactly the expected statements.
+func newSQLMockDB(t *testing.T, dialect gofs.Dialect, expected ...sqlExpectation) *gofs.SQLDB {
+	mock := &sqlMock{t: t, expected: expected}
+	conn := sql.OpenDB(mock)
+	t.Cleanup(func() {
+		_ = conn.Close()
+		assert.Empty(t, mock.expected, "the expected statements aren't executed")
+	})
+
+	return gofs.NewSQLDB(conn, dialect)
+}
+
+// returning returns the expectation of the query returning the given rows.
+func (e sqlExpectation) returning(columns []string, rows ...[]driver.Value) sqlExpectation {
+	e.columns, e.rows = columns, rows
+	return e
+}
+
+func (m *sqlMock) Connect(context.Context) (driver.Conn, error) {
+	return &sqlMockConn{mock: m}, nil
+}
+
+func (m *sqlMock) Driver() driver.Driver {
+	return m
+}
+
+func (m *sqlMock) Open(string) (driver.Conn, error) {
+	return &sqlMockConn{mock: m}, nil
+}
+
+// next returns the result of the next expected statement if it matches the executed one.
+func (m *sqlMock) next(query string, args []driver.NamedValue) (sqlExpectation, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	query = strings.Join(strings.Fields(query), " ")
+	if len(m.expected) == 0 {
+		m.t.Errorf("unexpected statement %q", query)
+		return sqlExpectation{}, errors.New("unexpected statement")
+	}
+	e := m.expected[0]
+	m.expected = m.expected[1:]
+
+	if !strings.HasPrefix(query, e.query) {
+		m.t.Errorf("expected statement %q, got %q", e.query, query)
+		return sqlExpectation{}, errors.New("unexpected statement")
+	}
+	if e.args != nil {
+		values := make([]driver.Value, len(args))
+		for i, arg := range args {
+			values[i] = arg.Value
+		}
+		assert.Equal(m.t, e.args, values, query)
+	}
+
+	return e, e.err
+}
+
+func (c *sqlMockConn) Prepare(string) (driver.Stmt, error) {
+	return nil, errors.New("prepared statements aren't supported")
+}
+
+func (c *sqlMockConn) Close() error {
+	return nil
+}
+
+func (c *sqlMockConn) Begin() (driver.Tx, error) {
+	_, err := c.mock.next("BEGIN", nil)
+	return c, err
+}
+
+func (c *sqlMockConn) Commit() error {
+	_, err := c.mock.next("COMMIT", nil)
+	return err
+}
+
+func (c *sqlMockConn) Rollback() error {
+	_, err := c.mock.next("ROLLBACK", nil)
+	return err
+}
+
+func (c *sqlMockConn) ExecContext(ctx context.Context, query string, args []driver.NamedValue) (driver.Result, error) {
+	if _, err := c.mock.next(query, args); err != nil {
+		return nil, err
+	}
+
+	return driver.RowsAffected(1), nil
+}
+
+func (c *sqlMockConn) QueryContext(ctx context.Context, query string, args []driver.NamedValue) (driver.Rows, error) {
+	e, err := c.mock.next(query, args)
+	if err != nil {
+		return nil, err
+	}
+
+	return &sqlMockRows{columns: e.columns, rows: e.rows}, nil
+}
+
+func (r *sqlMockRows) Columns() []string {
+	return r.columns
+}
+
+func (r *sqlMockRows) Close() error {
+	return nil
+}
+
+func (r *sqlMockRows) Next(dest []driver.Value) error {
+	if len(r.rows) == 0 {
+		return io.EOF
+	}
+	copy(dest, r.rows[0])
+	r.rows = r.rows[1:]
+
+	return nil
+}
+
+func (e sqlStateError) Error() string {
+	return "pq: SQLSTATE " + e.code
+}
+
+func (e sqlStateError) SQLState() string {
+	return e.code
+}
+
+func TestSQLDBMigrate(t *testing.T) {
+	db := newSQLMockDB(t, gofs.Postgres,
+		sqlExpectation{query: "CREATE TABLE IF NOT EXISTS gofs_uploads ( upload_key TEXT NOT NULL"},
+		sqlExpectation{query: "CREATE TABLE IF NOT EXISTS gofs_upload_parts ( upload_key TEXT NOT NULL"},
+	)
+	require.NoError(t, db.Migrate(context.Background()))
+
+	db = newSQLMockDB(t, gofs.MySQL,
+		sqlExpectation{query: "CREATE TABLE IF NOT EXISTS gofs_uploads ( upload_key VARBINARY(1024) NOT NULL"},
+		sqlExpectation{query: "CREATE TABLE IF NOT EXISTS gofs_upload_parts ( upload_key VARBINARY(1024) NOT NULL, part_number BIGINT NOT NULL, etag VARCHAR(255) NOT NULL, size BIGINT NOT NULL DEFAULT 0,"},
+	)
+	require.NoError(t, db.Migrate(context.Background()))
+
+	db = newSQLMockDB(t, gofs.Dialect("sqlite"))
+	assert.ErrorIs(t, db.Migrate(context.Background()), gofs.ErrUnsupportedDialect)
+}
+
+func TestSQLDBCreateUpload(t *testing.T) {
+	insert := sqlExpectation{
+		query: "INSERT INTO gofs_uploads (upload_key, upload_id, total_parts) VALUES ($1, $2, $3)",
+		args:  []driver.Value{"file.txt", "upload-id", int64(3)},
+	}
+	exists := sqlExpectation{query: "SELECT 1 FROM gofs_uploads WHERE upload_key = $1", args: []driver.Value{"file.txt"}}
+
+	t.Run("created", func(t *testing.T) {
+		db := newSQLMockDB(t, gofs.Postgres, sqlBegin, exists, insert, sqlCommit)
+		assert.NoError(t, db.CreateUpload("file.txt", "upload-id", 3))
+	})
+
+	t.Run("invalid", func(t *testing.T) {
+		db := newSQLMockDB(t, gofs.Postgres)
+		assert.ErrorIs(t, db.CreateUpload("", "upload-id", 3), gofs.ErrFileKeyEmpty)
+		assert.ErrorIs(t, db.CreateUpload("file.txt", "upload-id", 0), gofs.ErrInvalidTotalParts)
+	})
+
+	t.Run("exists", func(t *testing.T) {
+		db := newSQLMockDB(t, gofs.Postgres, sqlBegin, exists.returning([]string{"1"}, []driver.Value{int64(1)}), sqlRollback)
+		assert.ErrorIs(t, db.CreateUpload("file.txt", "upload-id", 3), gofs.ErrAlreadyExists)
+	})
+
+	t.Run("created concurrently", func(t *testing.T) {
+		conflict := insert
+		conflict.err = sqlStateError{code: "23505"}
+		db := newSQLMockDB(t, gofs.Postgres, sqlBegin, exists, conflict, sqlRollback)
+		assert.ErrorIs(t, db.CreateUpload("file.txt", "upload-id", 3), gofs.ErrAlreadyExists)
+
+		db = newSQLMockDB(t, gofs.MySQL,
+			sqlBegin,
+			sqlExpectation{query: "SELECT 1 FROM gofs_uploads WHERE upload_key = ?"},
+			sqlExpectation{
+				query: "INSERT INTO gofs_uploads (upload_key, upload_id, total_parts) VALUES (?, ?, ?)",
+				err:   errors.New("Error 1062 (23000): Duplicate entry 'file.txt' for key 'PRIMARY'"),
+			},
+			sqlRollback,
+		)
+		assert.ErrorIs(t, db.CreateUpload("file.txt", "upload-id", 3), gofs.ErrAlreadyExists)
+	})
+
+	t.Run("other error", func(t *testing.T) {
+		failed := insert
+		failed.err = sqlStateError{code: "53100"}
+		db := newSQLMockDB(t, gofs.Postgres, sqlBegin, exists, failed, sqlRollback)
+		err := db.CreateUpload("file.txt", "upload-id", 3)
+		assert.Equal(t, failed.err, err)
+	})
+}
+
+func TestSQLDBUpdateTotalParts(t *testing.T) {
+	current := sqlExpectation{query: "SELECT total_parts FROM gofs_uploads WHERE upload_key = $1 FOR UPDATE"}
+	maxPart := sqlExpectation{query: "SELECT COALESCE(MAX(part_number), 0) FROM gofs_upload_parts WHERE upload_key = $1"}
+
+	db := newSQLMockDB(t, gofs.Postgres,
+		sqlBegin,
+		current.returning([]string{"total_parts"}, []driver.Value{int64(3)}),
+		maxPart.returning([]string{"max"}, []driver.Value{int64(3)}),
+		sqlExpectation{query: "UPDATE gofs_uploads SET total_parts = $1 WHERE upload_key = $2", args: []driver.Value{int64(5), "file.txt"}},
+		sqlCommit,
+	)
+	assert.NoError(t, db.UpdateTotalParts("file.txt", 5))
+
+	db = newSQLMockDB(t, gofs.Postgres,
+		sqlBegin,
+		current.returning([]string{"total_parts"}, []driver.Value{int64(5)}),
+		maxPart.returning([]string{"max"}, []driver.Value{int64(4)}),
+		sqlRollback,
+	)
+	assert.ErrorIs(t, db.UpdateTotalParts("file.txt", 3), gofs.ErrTotalPartsTooSmall)
+
+	db = newSQLMockDB(t, gofs.Postgres, sqlBegin, current, sqlRollback)
+	assert.ErrorIs(t, db.UpdateTotalParts("file.txt", 3), gofs.ErrNotFound)
+	assert.ErrorIs(t, db.UpdateTotalParts("file.txt", 0), gofs.ErrInvalidTotalParts)
+}
+
+func TestSQLDBAddPart(t *testing.T) {
+	exists := sqlExpectation{query: "SELECT 1 FROM gofs_uploads WHERE upload_key = $1"}.returning([]string{"1"}, []driver.Value{int64(1)})
+	args := []driver.Value{"file.txt", int64(2), "etag-2", int64(5)}
+
+	db := newSQLMockDB(t, gofs.Postgres,
+		sqlBegin,
+		exists,
+		sqlExpectation{
+			query: "INSERT INTO gofs_upload_parts (upload_key, part_number, etag, size) VALUES ($1, $2, $3, $4) ON CONFLICT (upload_key, part_number) DO UPDATE",
+			args:  args,
+		},
+		sqlCommit,
+	)
+	assert.NoError(t, db.AddPart("file.txt", 2, "etag-2", 5))
+
+	db = newSQLMockDB(t, gofs.MySQL,
+		sqlBegin,
+		sqlExpectation{query: "SELECT 1 FROM gofs_uploads WHERE upload_key = ?"}.returning([]string{"1"}, []driver.Value{int64(1)}),
+		sqlExpectation{
+			query: "INSERT INTO gofs_upload_parts (upload_key, part_number, etag, size) VALUES (?, ?, ?, ?) ON DUPLICATE KEY UPDATE",
+			args:  args,
+		},
+		sqlCommit,
+	)
+	assert.NoError(t, db.AddPart("file.txt", 2, "etag-2", 5))
+
+	db = newSQLMockDB(t, gofs.Postgres, sqlBegin, sqlExpectation{query: "SELECT 1 FROM gofs_uploads"}, sqlRollback)
+	assert.ErrorIs(t, db.AddPart("file.txt", 2, "etag-2", 5), gofs.ErrNotFound)
+}
+
+func TestSQLDBAddPartIfAbsent(t *testing.T) {
+	locked := sqlExpectation{query: "SELECT 1 FROM gofs_uploads WHERE upload_key = $1 FOR UPDATE"}.returning([]string{"1"}, []driver.Value{int64(1)})
+	current := sqlExpectation{
+		query: "SELECT etag FROM gofs_upload_parts WHERE upload_key = $1 AND part_number = $2",
+		args:  []driver.Value{"file.txt", int64(2)},
+	}
+
+	db := newSQLMockDB(t, gofs.Postgres,
+		sqlBegin,
+		locked,
+		current,
+		sqlExpectation{
+			query: "INSERT INTO gofs_upload_parts (upload_key, part_number, etag, size) VALUES ($1, $2, $3, $4)",
+			args:  []driver.Value{"file.txt", int64(2), "etag-2", int64(5)},
+		},
+		sqlCommit,
+	)
+	assert.NoError(t, db.AddPartIfAbsent("file.txt", 2, "etag-2", 5))
+
+	// The part with the same ETag is already added.
+	recorded := current.returning([]string{"etag"}, []driver.Value{"etag-2"})
+	db = newSQLMockDB(t, gofs.Postgres, sqlBegin, locked, recorded, sqlCommit)
+	assert.NoError(t, db.AddPartIfAbsent("file.txt", 2, "etag-2", 5))
+
+	db = newSQLMockDB(t, gofs.Postgres, sqlBegin, locked, recorded, sqlRollback)
+	assert.ErrorIs(t, db.AddPartIfAbsent("file.txt", 2, "etag-other", 5), gofs.ErrPartConflict)
+
+	db = newSQLMockDB(t, gofs.Postgres, sqlBegin, sqlExpectation{query: "SELECT 1 FROM gofs_uploads"}, sqlRollback)
+	assert.ErrorIs(t, db.AddPartIfAbsent("file.txt", 2, "etag-2", 5), gofs.ErrNotFound)
+}
+
+func TestSQLDBCompleteUpload(t *testing.T) {
+	total := sqlExpectation{query: "SELECT total_parts FROM gofs_uploads WHERE upload_key = $1 FOR UPDATE"}.
+		returning([]string{"total_parts"}, []driver.Value{int64(2)})
+	count := sqlExpectation{
+		query: "SELECT COUNT(*) FROM gofs_upload_parts WHERE upload_key = $1 AND part_number BETWEEN 1 AND $2",
+		args:  []driver.Value{"file.txt", int64(2)},
+	}
+
+	db := newSQLMockDB(t, gofs.Postgres,
+		sqlBegin,
+		total,
+		count.returning([]string{"count"}, []driver.Value{int64(2)}),
+		sqlExpectation{query: "DELETE FROM gofs_upload_parts WHERE upload_key = $1", args: []driver.Value{"file.txt"}},
+		sqlExpectation{query: "DELETE FROM gofs_uploads WHERE upload_key = $1", args: []driver.Value{"file.txt"}},
+		sqlCommit,
+	)
+	assert.NoError(t, db.CompleteUpload("file.txt"))
+
+	db = newSQLMockDB(t, gofs.Postgres, sqlBegin, total, count.returning([]string{"count"}, []driver.Value{int64(1)}), sqlRollback)
+	assert.ErrorIs(t, db.CompleteUpload("file.txt"), gofs.ErrIncompleteUpload)
+
+	db = newSQLMockDB(t, gofs.Postgres, sqlBegin, sqlExpectation{query: "SELECT total_parts FROM gofs_uploads"}, sqlRollback)
+	assert.ErrorIs(t, db.CompleteUpload("file.txt"), gofs.ErrNotFound)
+}
+
+func TestSQLDBAbortUpload(t *testing.T) {
+	db := newSQLMockDB(t, gofs.MySQL,
+		sqlBegin,
+		sqlExpectation{query: "DELETE FROM gofs_upload_parts WHERE upload_key = ?", args: []driver.Value{"file.txt"}},
+		sqlExpectation{query: "DELETE FROM gofs_uploads WHERE upload_key = ?", args: []driver.Value{"file.txt"}},
+		sqlCommit,
+	)
+	assert.NoError(t, db.AbortUpload("file.txt"))
+}
+
+func TestSQLDBGetUploadID(t *testing.T) {
+	query := sqlExpectation{query: "SELECT upload_id FROM gofs_uploads WHERE upload_key = $1", args: []driver.Value{"file.txt"}}
+	db := newSQLMockDB(t, gofs.Postgres, query.returning([]string{"upload_id"}, []driver.Value{"upload-id"}), query)
+
+	uploadID, err := db.GetUploadID("file.txt")
+	require.NoError(t, err)
+	assert.Equal(t, "upload-id", uploadID)
+
+	_, err = db.GetUploadID("file.txt")
+	assert.ErrorIs(t, err, gofs.ErrNotFound)
+}
+
+func TestSQLDBGetStatus(t *testing.T) {
+	upload := sqlExpectation{query: "SELECT upload_id, total_parts FROM gofs_uploads WHERE upload_key = $1"}.
+		returning([]string{"upload_id", "total_parts"}, []driver.Value{"upload-id", int64(3)})
+	parts := sqlExpectation{query: "SELECT part_number, etag, size FROM gofs_upload_parts WHERE upload_key = $1"}.
+		returning([]string{"part_number", "etag", "size"},
+			[]driver.Value{int64(2), "etag-2", int64(5)},
+			[]driver.Value{int64(1), "etag-1", int64(10)},
+		)
+	db := newSQLMockDB(t, gofs.Postgres, upload, parts, upload, parts, sqlExpectation{query: "SELECT upload_id, total_parts"})
+
+	status, err := db.GetStatus("file.txt")
+	require.NoError(t, err)
+	assert.Equal(t, int64(3), status.TotalParts())
+	assert.Equal(t, int64(2), status.CompletedPartsNum())
+	assert.False(t, status.IsCompleted())
+	require.Implements(t, (*gofs.SizedStatus)(nil), status)
+	assert.Equal(t, int64(15), status.(gofs.SizedStatus).UploadedBytes())
+
+	completed, err := db.GetParts("file.txt")
+	require.NoError(t, err)
+	require.Len(t, completed, 2)
+	for i, part := range completed {
+		assert.Equal(t, int64(i+1), part.PartNumber())
+	}
+	assert.Equal(t, "etag-1", completed[0].ETag())
+
+	_, err = db.GetStatus("file.txt")
+	assert.ErrorIs(t, err, gofs.ErrNotFound)
+}
+
+func TestSQLDBListUploads(t *testing.T) {
+	db := newSQLMockDB(t, gofs.Postgres,
+		sqlExpectation{query: "SELECT upload_key FROM gofs_uploads ORDER BY upload_key"}.
+			returning([]string{"upload_key"}, []driver.Value{"a.txt"}, []driver.Value{"b.txt"}),
+	)
+
+	keys, err := db.ListUploads()
+	require.NoError(t, err)
+	assert.Equal(t, []string{"a.txt", "b.txt"}, keys)
+
+	// The connection passed to NewSQLDB is left open.
+	require.NoError(t, db.Close())
+}
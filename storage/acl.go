@@ -1,6 +1,12 @@
 package storage
 
-import "github.com/aws/aws-sdk-go/service/s3"
+import (
+	"io"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/s3"
+	"github.com/pkg/errors"
+)
 
 // Predefined ACL permissions
 const (
@@ -16,3 +22,71 @@ type ACL string
 func (a ACL) String() string {
 	return string(a)
 }
+
+// IsValid reports whether the ACL is one of the canned ACLs supported by S3.
+func (a ACL) IsValid() bool {
+	for _, v := range s3.ObjectCannedACL_Values() {
+		if string(a) == v {
+			return true
+		}
+	}
+
+	return false
+}
+
+// DefaultACL returns the ACL of the objects uploaded with the methods which don't take it.
+func (i *Interactor) DefaultACL() ACL {
+	return i.defaultACL
+}
+
+// UploadWithDefaultACL is Upload with the default ACL set by WithDefaultACL.
+func (i *Interactor) UploadWithDefaultACL(file []byte, filepath, contentType string) error {
+	if i.defaultACLErr != nil {
+		return errors.Wrap(i.defaultACLErr, "storage.upload")
+	}
+
+	return i.Upload(file, filepath, i.defaultACL, contentType)
+}
+
+// PutFileWithDefaultACL is PutFile with the default ACL set by WithDefaultACL.
+func (i *Interactor) PutFileWithDefaultACL(r io.ReadSeeker, key, contentType string) error {
+	if i.defaultACLErr != nil {
+		return errors.Wrap(i.defaultACLErr, "storage.putFile")
+	}
+
+	return i.PutFile(r, key, contentType, i.defaultACL)
+}
+
+// CreateMultipartUploadWithDefaultACL is CreateMultipartUpload with the default ACL set by WithDefaultACL.
+func (i *Interactor) CreateMultipartUploadWithDefaultACL(filename, contentType string) (string, error) {
+	if i.defaultACLErr != nil {
+		return "", errors.Wrap(i.defaultACLErr, "storage.createMultipartUpload")
+	}
+
+	return i.CreateMultipartUpload(filename, contentType, i.defaultACL)
+}
+
+// SetACL changes the ACL of the stored object in place,
+// the content and the metadata of the object are left untouched.
+// Returns ErrObjectNotFound if there is no object with the given path.
+func (i *Interactor) SetACL(filepath string, acl ACL) error {
+	input := &s3.PutObjectAclInput{
+		Bucket:       aws.String(i.bucket),
+		RequestPayer: i.requestPayer(),
+		Key:          aws.String(trimKey(filepath)),
+		ACL:          aws.String(acl.String()),
+	}
+	if err := input.Validate(); err != nil {
+		return errors.Wrap(err, "storage.setACL")
+	}
+
+	if _, err := i.s3.PutObjectAcl(input); err != nil {
+		if isNotFound(err) {
+			return ErrObjectNotFound
+		}
+		i.logError("storage: set acl %s of %s: %v", acl, filepath, err)
+		return errors.Wrap(err, "storage.setACL")
+	}
+
+	return nil
+}
@@ -0,0 +1,81 @@
+package storage
+
+import (
+	"io"
+
+	"github.com/pkg/errors"
+)
+
+// AppendData appends data to the end of the stored object, e.g. a log file.
+// S3 has no native append, so the object is rebuilt under the same key:
+// the stored content is copied on the storage side as the first parts of a multipart upload
+// and data is uploaded as the last part.
+// Only the last part of the multipart upload may be smaller than the min part size (5 MiB),
+// so an object smaller than that is downloaded and re-uploaded with data instead,
+// as well as the objects encrypted on the client side.
+// If the object doesn't exist, it's created with data.
+// The content type, encoding and metadata of the object are preserved,
+// the ACL is reset to the default one of the bucket.
+// Appending isn't atomic: concurrent appends to the same object overwrite each other.
+func (i *Interactor) AppendData(filepath string, data []byte) (err error) {
+	if len(data) == 0 {
+		return errors.Wrap(ErrFileEmpty, "storage.appendData")
+	}
+
+	info, err := i.Stat(filepath)
+	if errors.Is(err, ErrObjectNotFound) {
+		contentType, err := GetFileContentTypeByBytes(data)
+		if err != nil {
+			return errors.Wrap(err, "storage.appendData")
+		}
+		return i.UploadWithOptions(data, filepath, UploadOptions{ContentType: contentType})
+	}
+	if err != nil {
+		return err
+	}
+
+	opts := UploadOptions{
+		ContentType:     info.ContentType,
+		ContentEncoding: info.ContentEncoding,
+		Metadata:        info.Metadata,
+	}
+
+	// The encrypted content can't be extended on the storage side either.
+	if info.ContentLength < i.minPartSize || i.keys != nil {
+		body, _, err := i.Download(filepath)
+		if err != nil {
+			return err
+		}
+		defer body.Close()
+
+		content, err := io.ReadAll(body)
+		if err != nil {
+			return errors.Wrap(err, "storage.appendData")
+		}
+
+		return i.UploadWithOptions(append(content, data...), filepath, opts)
+	}
+
+	uploadID, err := i.CreateMultipartUploadWithOptions(filepath, opts)
+	if err != nil {
+		return err
+	}
+	defer func() {
+		if err != nil {
+			_ = i.abortMultipartUpload(filepath, uploadID)
+		}
+	}()
+
+	parts, err := i.copyParts(filepath, filepath, uploadID, info.ContentLength, 1)
+	if err != nil {
+		return err
+	}
+
+	partNum := int64(len(parts)) + 1
+	part, err := i.UploadPart(filepath, uploadID, data, partNum, partNum)
+	if err != nil {
+		return err
+	}
+
+	return i.CompleteMultipartUpload(filepath, uploadID, append(parts, part)...)
+}
@@ -0,0 +1,62 @@
+package storage
+
+import (
+	"io"
+
+	"github.com/google/uuid"
+	"github.com/pkg/errors"
+)
+
+// AtomicReplace replaces the file with the new content in two steps:
+// the content is uploaded to a temporary key next to the file
+// and then copied over the file on the server side, the temporary object is deleted afterwards.
+// S3 never exposes a partially written object, so the readers see either the old or the new content;
+// a failed upload leaves the file untouched.
+// A single request upload has the same guarantees, the temporary key helps with the big content
+// uploaded in parts from a stream, see AtomicReplaceReader.
+// The temporary object may be left behind if the process dies between the steps,
+// its key is the file path followed by ".tmp-<uuid>".
+func (i *Interactor) AtomicReplace(filepath string, file []byte, acl ACL, contentType string) error {
+	temp := tempKey(filepath)
+	if err := i.Upload(file, temp, acl, contentType); err != nil {
+		return errors.Wrap(err, "storage.atomicReplace")
+	}
+
+	return i.replaceWith(temp, filepath, acl)
+}
+
+// AtomicReplaceReader is AtomicReplace of the content of the given size read from r,
+// which is uploaded in parts if it's bigger than the multipart threshold.
+// The file is replaced only if r yields exactly size bytes, otherwise ErrSizeMismatch is returned.
+func (i *Interactor) AtomicReplaceReader(r io.Reader, size int64, filepath string, acl ACL, contentType string) error {
+	if r == nil {
+		return errors.Wrap(ErrInvalidReader, "storage.atomicReplace")
+	}
+
+	temp := tempKey(filepath)
+	if err := i.putSized(r, size, temp, UploadOptions{ACL: acl, ContentType: contentType}); err != nil {
+		return errors.Wrap(err, "storage.atomicReplace")
+	}
+
+	return i.replaceWith(temp, filepath, acl)
+}
+
+// replaceWith copies the temporary object over the file and deletes it.
+func (i *Interactor) replaceWith(temp, filepath string, acl ACL) error {
+	defer func() {
+		if err := i.deleteVersion(temp, ""); err != nil {
+			i.logError("storage: atomic replace %s: delete %s: %v", filepath, temp, err)
+		}
+	}()
+
+	if err := i.CopyLarge(temp, filepath, acl); err != nil {
+		return errors.Wrap(err, "storage.atomicReplace")
+	}
+
+	return nil
+}
+
+// tempKey returns the unique temporary key next to the given one.
+func tempKey(filepath string) string {
+	return filepath + ".tmp-" + uuid.New().String()
+}
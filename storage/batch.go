@@ -0,0 +1,78 @@
+package storage
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// BatchError is returned by the batch operations, e.g. DeleteMany and DownloadMany,
+// if some of the items failed. Errors holds the failure of every failed item by its key.
+// errors.Is and errors.As match any of the contained errors.
+type BatchError struct {
+	Errors map[string]error
+}
+
+// Error returns the error message listing the failed items.
+func (e *BatchError) Error() string {
+	keys := e.Failed()
+	msgs := make([]string, 0, len(keys))
+	for _, key := range keys {
+		msgs = append(msgs, fmt.Sprintf("%s: %v", key, e.Errors[key]))
+	}
+
+	return fmt.Sprintf("%d items failed: %s", len(keys), strings.Join(msgs, "; "))
+}
+
+// Failed returns the sorted keys of the failed items.
+func (e *BatchError) Failed() []string {
+	keys := make([]string, 0, len(e.Errors))
+	for key := range e.Errors {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	return keys
+}
+
+// Unwrap returns the contained errors.
+func (e *BatchError) Unwrap() []error {
+	errs := make([]error, 0, len(e.Errors))
+	for _, key := range e.Failed() {
+		errs = append(errs, e.Errors[key])
+	}
+
+	return errs
+}
+
+// Is reports whether any of the contained errors matches the target.
+func (e *BatchError) Is(target error) bool {
+	for _, err := range e.Errors {
+		if errors.Is(err, target) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// As finds the first contained error, in the order of the keys, that matches the target.
+func (e *BatchError) As(target interface{}) bool {
+	for _, err := range e.Unwrap() {
+		if errors.As(err, target) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// batchError returns *BatchError of the failures or nil if there are none.
+func batchError(failures map[string]error) error {
+	if len(failures) == 0 {
+		return nil
+	}
+
+	return &BatchError{Errors: failures}
+}
@@ -0,0 +1,26 @@
+package storage_test
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/dmitrymomot/gofs/storage"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestBatchError(t *testing.T) {
+	err := error(&storage.BatchError{Errors: map[string]error{
+		"b.txt": storage.ErrObjectNotFound,
+		"a.txt": &storage.WrongRegionError{Region: "eu-west-1"},
+	}})
+
+	assert.Equal(t, []string{"a.txt", "b.txt"}, err.(*storage.BatchError).Failed())
+	assert.Contains(t, err.Error(), "2 items failed")
+	assert.ErrorIs(t, err, storage.ErrObjectNotFound)
+	assert.ErrorIs(t, err, storage.ErrWrongRegion)
+	assert.NotErrorIs(t, err, storage.ErrFileEmpty)
+
+	var regionErr *storage.WrongRegionError
+	assert.True(t, errors.As(err, &regionErr))
+	assert.Equal(t, "eu-west-1", regionErr.Region)
+}
@@ -0,0 +1,121 @@
+package storage
+
+import (
+	"context"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/s3"
+	"github.com/pkg/errors"
+)
+
+// probeKey is the key of the missing object the capability probes refer to.
+const probeKey = ".gofs-capabilities-probe"
+
+// ProviderCapabilities describes the features the storage provider supports for the bucket.
+// S3-compatible storages, e.g. MinIO or DigitalOcean Spaces, implement different subsets of the S3 API.
+type ProviderCapabilities struct {
+	SupportsVersioning    bool // the bucket versioning API, VersioningEnabled reports its status
+	VersioningEnabled     bool
+	SupportsTagging       bool // the bucket and object tagging API
+	SupportsObjectLock    bool // the object lock is enabled for the bucket
+	SupportsMultipartCopy bool // CopyLarge, AppendData
+	SupportsSelect        bool // SelectContent
+}
+
+// Capabilities probes the features supported by the storage provider with a few read-only requests.
+// The result is cached by the interactor, so only the first successful call issues the requests.
+// A feature is reported unsupported if the provider rejects its request as not implemented,
+// other errors, e.g. access denied, fail the probe.
+func (i *Interactor) Capabilities(ctx context.Context) (*ProviderCapabilities, error) {
+	i.capsMu.Lock()
+	defer i.capsMu.Unlock()
+
+	if i.caps == nil {
+		caps, err := i.probeCapabilities(ctx)
+		if err != nil {
+			i.logError("storage: probe capabilities: %v", err)
+			return nil, errors.Wrap(err, "storage.capabilities")
+		}
+		i.caps = caps
+	}
+
+	caps := *i.caps
+	return &caps, nil
+}
+
+// probeCapabilities issues the feature-detection requests.
+func (i *Interactor) probeCapabilities(ctx context.Context) (*ProviderCapabilities, error) {
+	caps := &ProviderCapabilities{}
+
+	versioning, err := i.s3.GetBucketVersioningWithContext(ctx, &s3.GetBucketVersioningInput{
+		Bucket: aws.String(i.bucket),
+	})
+	if caps.SupportsVersioning, err = probeResult(err); err != nil {
+		return nil, err
+	}
+	if versioning != nil {
+		caps.VersioningEnabled = aws.StringValue(versioning.Status) == s3.BucketVersioningStatusEnabled
+	}
+
+	_, err = i.s3.GetBucketTaggingWithContext(ctx, &s3.GetBucketTaggingInput{
+		Bucket: aws.String(i.bucket),
+	})
+	if caps.SupportsTagging, err = probeResult(err, "NoSuchTagSet", "NoSuchTagSetError"); err != nil {
+		return nil, err
+	}
+
+	lock, err := i.s3.GetObjectLockConfigurationWithContext(ctx, &s3.GetObjectLockConfigurationInput{
+		Bucket: aws.String(i.bucket),
+	})
+	if _, err = probeResult(err, "ObjectLockConfigurationNotFoundError"); err != nil {
+		return nil, err
+	}
+	caps.SupportsObjectLock = lock != nil && lock.ObjectLockConfiguration != nil &&
+		aws.StringValue(lock.ObjectLockConfiguration.ObjectLockEnabled) == s3.ObjectLockEnabledEnabled
+
+	// The upload doesn't exist, so nothing is copied.
+	_, err = i.s3.UploadPartCopyWithContext(ctx, &s3.UploadPartCopyInput{
+		Bucket:       aws.String(i.bucket),
+		RequestPayer: i.requestPayer(),
+		Key:          aws.String(probeKey),
+		CopySource:   aws.String(i.bucket + "/" + probeKey),
+		UploadId:     aws.String("gofs-capabilities-probe"),
+		PartNumber:   aws.Int64(1),
+	})
+	if caps.SupportsMultipartCopy, err = probeResult(err, s3.ErrCodeNoSuchUpload, s3.ErrCodeNoSuchKey, "NotFound"); err != nil {
+		return nil, err
+	}
+
+	_, err = i.s3.SelectObjectContentWithContext(ctx, &s3.SelectObjectContentInput{
+		Bucket:         aws.String(i.bucket),
+		Key:            aws.String(probeKey),
+		Expression:     aws.String("SELECT * FROM S3Object"),
+		ExpressionType: aws.String(s3.ExpressionTypeSql),
+		InputSerialization: &s3.InputSerialization{
+			CSV: &s3.CSVInput{},
+		},
+		OutputSerialization: &s3.OutputSerialization{
+			CSV: &s3.CSVOutput{},
+		},
+	}, i.requestPayerHeader()...)
+	if caps.SupportsSelect, err = probeResult(err, s3.ErrCodeNoSuchKey, "NotFound"); err != nil {
+		return nil, err
+	}
+
+	return caps, nil
+}
+
+// probeResult interprets the error of a feature-detection request:
+// the feature is supported if the request succeeded or failed with one of the expected codes,
+// and unsupported if the provider doesn't implement the request.
+// Other errors are returned as is.
+func probeResult(err error, expected ...string) (bool, error) {
+	switch {
+	case err == nil, hasCode(err, expected...):
+		return true, nil
+	case isNotImplemented(err):
+		return false, nil
+	default:
+		return false, err
+	}
+}
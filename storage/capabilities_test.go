@@ -0,0 +1,50 @@
+package storage_test
+
+import (
+	"context"
+	"net/http"
+	"sync/atomic"
+	"testing"
+
+	"github.com/dmitrymomot/gofs/storage"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestCapabilities(t *testing.T) {
+	var requests int32
+	s := newLocalInteractor(t, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(&requests, 1)
+		w.Header().Set("Content-Type", "application/xml")
+
+		query := r.URL.Query()
+		switch {
+		case query.Has("versioning"):
+			_, _ = w.Write([]byte(`<VersioningConfiguration><Status>Enabled</Status></VersioningConfiguration>`))
+		case query.Has("object-lock"):
+			w.WriteHeader(http.StatusNotFound)
+			_, _ = w.Write([]byte(`<Error><Code>ObjectLockConfigurationNotFoundError</Code><Message>Object Lock configuration does not exist for this bucket</Message></Error>`))
+		case query.Has("uploadId"):
+			w.WriteHeader(http.StatusNotFound)
+			_, _ = w.Write([]byte(`<Error><Code>NoSuchUpload</Code><Message>The specified upload does not exist.</Message></Error>`))
+		default: // tagging, select
+			w.WriteHeader(http.StatusNotImplemented)
+			_, _ = w.Write([]byte(`<Error><Code>NotImplemented</Code><Message>A header you provided implies functionality that is not implemented.</Message></Error>`))
+		}
+	}))
+
+	caps, err := s.Capabilities(context.Background())
+	require.NoError(t, err)
+	assert.Equal(t, &storage.ProviderCapabilities{
+		SupportsVersioning:    true,
+		VersioningEnabled:     true,
+		SupportsMultipartCopy: true,
+	}, caps)
+
+	// The result is cached.
+	probed := atomic.LoadInt32(&requests)
+	caps, err = s.Capabilities(context.Background())
+	require.NoError(t, err)
+	assert.True(t, caps.SupportsVersioning)
+	assert.Equal(t, probed, atomic.LoadInt32(&requests))
+}
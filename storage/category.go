@@ -0,0 +1,85 @@
+package storage
+
+import "strings"
+
+// Predefined file categories
+const (
+	Other Category = iota
+	Image
+	Video
+	Audio
+	Document
+	Archive
+)
+
+// Category is a coarse file category derived from the content type.
+type Category int
+
+// String returns the string representation of the category.
+func (c Category) String() string {
+	switch c {
+	case Image:
+		return "image"
+	case Video:
+		return "video"
+	case Audio:
+		return "audio"
+	case Document:
+		return "document"
+	case Archive:
+		return "archive"
+	default:
+		return "other"
+	}
+}
+
+// categoriesByType maps content types which can't be categorized by the prefix.
+var categoriesByType = map[string]Category{
+	"application/pdf":                                 Document,
+	"application/msword":                              Document,
+	"application/rtf":                                 Document,
+	"application/vnd.ms-excel":                        Document,
+	"application/vnd.ms-powerpoint":                   Document,
+	"application/vnd.oasis.opendocument.text":         Document,
+	"application/vnd.oasis.opendocument.spreadsheet":  Document,
+	"application/vnd.oasis.opendocument.presentation": Document,
+	"application/vnd.openxmlformats-officedocument.wordprocessingml.document":   Document,
+	"application/vnd.openxmlformats-officedocument.spreadsheetml.sheet":         Document,
+	"application/vnd.openxmlformats-officedocument.presentationml.presentation": Document,
+	"application/epub+zip":         Document,
+	"application/json":             Document,
+	"application/xml":              Document,
+	"application/zip":              Archive,
+	"application/gzip":             Archive,
+	"application/x-gzip":           Archive,
+	"application/x-tar":            Archive,
+	"application/x-bzip2":          Archive,
+	"application/x-xz":             Archive,
+	"application/x-7z-compressed":  Archive,
+	"application/x-rar-compressed": Archive,
+	"application/vnd.rar":          Archive,
+	"application/ogg":              Audio,
+}
+
+// FileCategory returns the coarse category of the given content type.
+// Parameters of the content type (e.g. "; charset=utf-8") are ignored.
+func FileCategory(contentType string) Category {
+	contentType = strings.ToLower(strings.TrimSpace(strings.Split(contentType, ";")[0]))
+
+	if c, ok := categoriesByType[contentType]; ok {
+		return c
+	}
+
+	switch {
+	case strings.HasPrefix(contentType, "image/"):
+		return Image
+	case strings.HasPrefix(contentType, "video/"):
+		return Video
+	case strings.HasPrefix(contentType, "audio/"):
+		return Audio
+	case strings.HasPrefix(contentType, "text/"):
+		return Document
+	}
+
+	return Other
+}
@@ -0,0 +1,32 @@
+package storage_test
+
+import (
+	"testing"
+
+	"github.com/dmitrymomot/gofs/storage"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestFileCategory(t *testing.T) {
+	tests := map[string]storage.Category{
+		"image/png":                 storage.Image,
+		"image/svg+xml":             storage.Image,
+		"video/mp4":                 storage.Video,
+		"audio/mpeg":                storage.Audio,
+		"application/ogg":           storage.Audio,
+		"application/pdf":           storage.Document,
+		"text/plain; charset=utf-8": storage.Document,
+		"application/vnd.openxmlformats-officedocument.wordprocessingml.document": storage.Document,
+		"application/zip":          storage.Archive,
+		"application/x-tar":        storage.Archive,
+		"application/octet-stream": storage.Other,
+		"":                         storage.Other,
+	}
+
+	for contentType, expected := range tests {
+		assert.Equal(t, expected, storage.FileCategory(contentType), contentType)
+	}
+
+	assert.Equal(t, "image", storage.Image.String())
+	assert.Equal(t, "other", storage.Other.String())
+}
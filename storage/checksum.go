@@ -0,0 +1,167 @@
+package storage
+
+import (
+	"crypto/md5"
+	"crypto/sha1"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"fmt"
+	"hash"
+	"hash/crc32"
+	"io"
+	"strings"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/s3"
+	"github.com/pkg/errors"
+)
+
+// VerifyObjectChecksum checks that the stored object matches the expected hex-encoded SHA256 hash.
+// It uses the checksum stored by S3 when available, otherwise downloads and hashes the object,
+// e.g. for multipart objects (S3 stores a checksum of part checksums for them)
+// or storages without checksum support.
+// Returns ErrChecksumMismatch on disagreement.
+func (i *Interactor) VerifyObjectChecksum(filepath, expectedSHA256 string) error {
+	expected, err := hex.DecodeString(expectedSHA256)
+	if err != nil || len(expected) != sha256.Size {
+		return errors.Wrap(ErrInvalidChecksum, "storage.verifyObjectChecksum")
+	}
+
+	input := &s3.GetObjectAttributesInput{
+		Bucket:           aws.String(i.bucket),
+		RequestPayer:     i.requestPayer(),
+		Key:              aws.String(trimKey(filepath)),
+		ObjectAttributes: aws.StringSlice([]string{s3.ObjectAttributesChecksum, s3.ObjectAttributesObjectParts}),
+	}
+	if err := input.Validate(); err != nil {
+		return errors.Wrap(err, "storage.verifyObjectChecksum")
+	}
+
+	attrs, err := i.s3.GetObjectAttributes(input)
+	if err != nil && isNotFound(err) {
+		return ErrObjectNotFound
+	}
+	if err == nil && attrs.Checksum != nil && attrs.Checksum.ChecksumSHA256 != nil &&
+		(attrs.ObjectParts == nil || aws.Int64Value(attrs.ObjectParts.TotalPartsCount) == 0) &&
+		!strings.Contains(aws.StringValue(attrs.Checksum.ChecksumSHA256), "-") {
+		if aws.StringValue(attrs.Checksum.ChecksumSHA256) != base64.StdEncoding.EncodeToString(expected) {
+			return ErrChecksumMismatch
+		}
+		return nil
+	}
+
+	// Fallback: download and hash the whole object.
+	body, _, err := i.Download(filepath)
+	if err != nil {
+		if isNotFound(err) {
+			return ErrObjectNotFound
+		}
+		return errors.Wrap(err, "storage.verifyObjectChecksum")
+	}
+	defer body.Close()
+
+	h := sha256.New()
+	if _, err := io.Copy(h, body); err != nil {
+		return errors.Wrap(err, "storage.verifyObjectChecksum")
+	}
+	if hex.EncodeToString(h.Sum(nil)) != strings.ToLower(expectedSHA256) {
+		return ErrChecksumMismatch
+	}
+
+	return nil
+}
+
+// ComputeMultipartETag returns the ETag S3 assigns to the object assembled from the parts
+// with the given raw (16 bytes, not hex-encoded) MD5 hashes, in the part number order:
+// the hex-encoded MD5 of the concatenated part hashes suffixed with "-<number of parts>".
+// The ETag is returned without the quotes S3 surrounds it with.
+// It applies to the multipart uploads without SSE-KMS or SSE-C encryption,
+// which make the ETags unrelated to the content.
+func ComputeMultipartETag(partMD5s [][]byte) string {
+	h := md5.New()
+	for _, sum := range partMD5s {
+		h.Write(sum)
+	}
+
+	return fmt.Sprintf("%s-%d", hex.EncodeToString(h.Sum(nil)), len(partMD5s))
+}
+
+// Additional checksum algorithms of the uploads.
+const (
+	ChecksumCRC32  ChecksumAlgorithm = s3.ChecksumAlgorithmCrc32
+	ChecksumCRC32C ChecksumAlgorithm = s3.ChecksumAlgorithmCrc32c
+	ChecksumSHA1   ChecksumAlgorithm = s3.ChecksumAlgorithmSha1
+	ChecksumSHA256 ChecksumAlgorithm = s3.ChecksumAlgorithmSha256
+)
+
+// ChecksumAlgorithm is the algorithm of the additional checksum the storage validates the uploaded content with
+// and stores along with the object.
+type ChecksumAlgorithm string
+
+// String returns the string representation of the checksum algorithm.
+func (a ChecksumAlgorithm) String() string {
+	return string(a)
+}
+
+// validate checks that the algorithm is known, the empty algorithm means no checksum.
+func (a ChecksumAlgorithm) validate() error {
+	switch a {
+	case "", ChecksumCRC32, ChecksumCRC32C, ChecksumSHA1, ChecksumSHA256:
+		return nil
+	}
+
+	return ErrInvalidChecksumAlgorithm
+}
+
+// sum returns the base64-encoded checksum of the data, as it's sent in the x-amz-checksum-* headers.
+func (a ChecksumAlgorithm) sum(data []byte) string {
+	var h hash.Hash
+	switch a {
+	case ChecksumCRC32:
+		h = crc32.NewIEEE()
+	case ChecksumCRC32C:
+		h = crc32.New(crc32.MakeTable(crc32.Castagnoli))
+	case ChecksumSHA1:
+		h = sha1.New()
+	case ChecksumSHA256:
+		h = sha256.New()
+	default:
+		return ""
+	}
+	_, _ = h.Write(data)
+
+	return base64.StdEncoding.EncodeToString(h.Sum(nil))
+}
+
+// setPut sets the algorithm and the checksum of the upload.
+func (a ChecksumAlgorithm) setPut(input *s3.PutObjectInput, checksum string) {
+	input.ChecksumAlgorithm = aws.String(a.String())
+	switch a {
+	case ChecksumCRC32:
+		input.ChecksumCRC32 = aws.String(checksum)
+	case ChecksumCRC32C:
+		input.ChecksumCRC32C = aws.String(checksum)
+	case ChecksumSHA1:
+		input.ChecksumSHA1 = aws.String(checksum)
+	case ChecksumSHA256:
+		input.ChecksumSHA256 = aws.String(checksum)
+	}
+}
+
+// fromPut returns the checksum of the upload returned by the storage,
+// it's empty if the storage doesn't support the additional checksums.
+func (a ChecksumAlgorithm) fromPut(output *s3.PutObjectOutput) string {
+	switch a {
+	case ChecksumCRC32:
+		return aws.StringValue(output.ChecksumCRC32)
+	case ChecksumCRC32C:
+		return aws.StringValue(output.ChecksumCRC32C)
+	case ChecksumSHA1:
+		return aws.StringValue(output.ChecksumSHA1)
+	case ChecksumSHA256:
+		return aws.StringValue(output.ChecksumSHA256)
+	}
+
+	return ""
+}
@@ -0,0 +1,102 @@
+package storage_test
+
+import (
+	"crypto/md5"
+	"fmt"
+	"net/http"
+	"sync"
+	"testing"
+
+	"github.com/dmitrymomot/gofs/storage"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestComputeMultipartETag(t *testing.T) {
+	first := md5.Sum([]byte("aaaaa"))
+	second := md5.Sum([]byte("b"))
+
+	assert.Equal(t, "d6cf633d4421b23c497ad462505168a1-2", storage.ComputeMultipartETag([][]byte{first[:], second[:]}))
+	assert.Equal(t, "3f39a134e77e08c106b9726a8ae7cc0c-1", storage.ComputeMultipartETag([][]byte{first[:]}))
+	assert.Equal(t, "d41d8cd98f00b204e9800998ecf8427e-0", storage.ComputeMultipartETag(nil))
+}
+
+func TestUploadChecksumAlgorithm(t *testing.T) {
+	var (
+		mu       sync.Mutex
+		headers  []http.Header
+		response = func(w http.ResponseWriter, r *http.Request) {
+			w.Header().Set("X-Amz-Checksum-Sha256", r.Header.Get("X-Amz-Checksum-Sha256"))
+		}
+	)
+	s := newLocalInteractor(t, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		defer mu.Unlock()
+
+		headers = append(headers, r.Header.Clone())
+		response(w, r)
+	}))
+
+	// sha256("Hello, World!")
+	const checksum = "3/1gIbsr1bCvZ2KQgJ7DpTGR3YHH9wpLKGiKNiGCmG8="
+	upload := func(alg storage.ChecksumAlgorithm) (*storage.UploadResult, error) {
+		return s.UploadWithResult([]byte("Hello, World!"), "file.txt", storage.UploadOptions{ContentType: "text/plain", ChecksumAlgorithm: alg})
+	}
+
+	result, err := upload(storage.ChecksumSHA256)
+	require.NoError(t, err)
+	assert.Equal(t, checksum, result.Checksum)
+	assert.Equal(t, "SHA256", headers[0].Get("X-Amz-Sdk-Checksum-Algorithm"))
+	assert.Equal(t, checksum, headers[0].Get("X-Amz-Checksum-Sha256"))
+
+	// The storage returns another checksum.
+	response = func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("X-Amz-Checksum-Sha256", "AAAA")
+	}
+	_, err = upload(storage.ChecksumSHA256)
+	assert.ErrorIs(t, err, storage.ErrChecksumMismatch)
+
+	// The storage ignores the checksum.
+	response = func(w http.ResponseWriter, r *http.Request) {}
+	result, err = upload(storage.ChecksumSHA256)
+	require.NoError(t, err)
+	assert.Empty(t, result.Checksum)
+
+	// The storage rejects the checksum, the upload is repeated without it.
+	headers = nil
+	response = func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("X-Amz-Checksum-Sha256") != "" {
+			w.WriteHeader(http.StatusNotImplemented)
+		}
+	}
+	result, err = upload(storage.ChecksumSHA256)
+	require.NoError(t, err)
+	assert.Empty(t, result.Checksum)
+	require.Len(t, headers, 2)
+	assert.Empty(t, headers[1].Get("X-Amz-Checksum-Sha256"))
+
+	_, err = upload("MD5")
+	assert.ErrorIs(t, err, storage.ErrInvalidChecksumAlgorithm)
+
+	// The multipart upload would be stored without the checksum.
+	headers = nil
+	_, err = s.CreateMultipartUploadWithOptions("file.txt", storage.UploadOptions{ContentType: "text/plain", ChecksumAlgorithm: storage.ChecksumSHA256})
+	assert.ErrorIs(t, err, storage.ErrChecksumUnsupported)
+	assert.Empty(t, headers, "nothing is sent")
+}
+
+func TestUploadContentMD5Rejected(t *testing.T) {
+	code := "BadDigest"
+	s := newLocalInteractor(t, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusBadRequest)
+		fmt.Fprintf(w, `<Error><Code>%s</Code><Message>The Content-MD5 you specified did not match what we received.</Message></Error>`, code)
+	}))
+	opts := storage.UploadOptions{ContentType: "text/plain", ContentMD5: "ZajifYh5KDgxtmS9i38K1A=="}
+
+	_, err := s.UploadWithResult([]byte("Hello, World!"), "file.txt", opts)
+	assert.ErrorIs(t, err, storage.ErrChecksumMismatch)
+
+	code = "InvalidDigest"
+	_, err = s.UploadWithResult([]byte("Hello, World!"), "file.txt", opts)
+	assert.ErrorIs(t, err, storage.ErrInvalidChecksum)
+}
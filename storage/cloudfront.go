@@ -0,0 +1,33 @@
+package storage
+
+import (
+	"crypto/rsa"
+	"time"
+
+	"github.com/aws/aws-sdk-go/service/cloudfront/sign"
+	"github.com/pkg/errors"
+)
+
+// GenerateSignedCookies returns CloudFront signed cookies (name to value)
+// granting access to the private content matching the resource pattern,
+// e.g. "https://d111111abcdef8.cloudfront.net/gallery/*", for the given time.
+// The policy allowing the pattern until the expiry time is always sent in the CloudFront-Policy cookie,
+// along with CloudFront-Signature and CloudFront-Key-Pair-Id, whether the pattern has wildcards or not.
+// keyPairID is the ID of the CloudFront public key matching the private key.
+func GenerateSignedCookies(resourcePattern string, expiry time.Duration, keyPairID string, privateKey *rsa.PrivateKey) (map[string]string, error) {
+	if privateKey == nil || keyPairID == "" {
+		return nil, errors.Wrap(ErrInvalidSigningKey, "storage.GenerateSignedCookies")
+	}
+
+	cookies, err := sign.NewCookieSigner(keyPairID, privateKey).Sign(resourcePattern, time.Now().Add(expiry))
+	if err != nil {
+		return nil, errors.Wrap(err, "storage.GenerateSignedCookies")
+	}
+
+	result := make(map[string]string, len(cookies))
+	for _, c := range cookies {
+		result[c.Name] = c.Value
+	}
+
+	return result, nil
+}
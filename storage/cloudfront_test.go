@@ -0,0 +1,26 @@
+package storage_test
+
+import (
+	"crypto/rand"
+	"crypto/rsa"
+	"testing"
+	"time"
+
+	"github.com/dmitrymomot/gofs/storage"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestGenerateSignedCookies(t *testing.T) {
+	privateKey, err := rsa.GenerateKey(rand.Reader, 2048)
+	require.NoError(t, err)
+
+	cookies, err := storage.GenerateSignedCookies("https://example.cloudfront.net/gallery/*", time.Hour, "KEYPAIRID", privateKey)
+	require.NoError(t, err)
+	assert.NotEmpty(t, cookies["CloudFront-Policy"])
+	assert.NotEmpty(t, cookies["CloudFront-Signature"])
+	assert.Equal(t, "KEYPAIRID", cookies["CloudFront-Key-Pair-Id"])
+
+	_, err = storage.GenerateSignedCookies("https://example.cloudfront.net/gallery/*", time.Hour, "", privateKey)
+	assert.ErrorIs(t, err, storage.ErrInvalidSigningKey)
+}
@@ -0,0 +1,55 @@
+package storage
+
+import (
+	"context"
+
+	"github.com/pkg/errors"
+)
+
+// UploadIfMatch overwrites the file only if its current ETag is the expected one,
+// i.e. the file wasn't changed since it was read, so concurrent writers can coordinate without a lock.
+// Returns ErrPreconditionFailed if the file was changed or deleted.
+// The check is done by the storage with the If-Match header of the upload.
+// Providers which reject the header, e.g. the older MinIO versions, are checked with a HEAD request
+// before the upload instead, which leaves a short window for a concurrent write.
+// Providers which silently ignore the header can't be detected: the file is overwritten unconditionally,
+// check the documentation of the provider before relying on it.
+// The OnConflict strategy of the options is ignored.
+func (i *Interactor) UploadIfMatch(file []byte, filepath, expectedETag string, opts UploadOptions) error {
+	return i.UploadIfMatchWithContext(context.Background(), file, filepath, expectedETag, opts)
+}
+
+// UploadIfMatchWithContext is UploadIfMatch which cancels the requests when ctx is done.
+func (i *Interactor) UploadIfMatchWithContext(ctx context.Context, file []byte, filepath, expectedETag string, opts UploadOptions) error {
+	if expectedETag == "" {
+		return errors.Wrap(ErrEmptyETag, "storage.uploadIfMatch")
+	}
+	opts.OnConflict = ConflictOverwrite
+
+	_, err := i.upload(ctx, file, filepath, opts, headerOptions(map[string]string{
+		"If-Match": quoteETag(expectedETag),
+	})...)
+	switch {
+	case err == nil:
+		return nil
+	case errors.Is(err, ErrPreconditionFailed), hasCode(err, "NoSuchKey"):
+		return ErrPreconditionFailed
+	case !isNotImplemented(err):
+		return err
+	}
+
+	i.logger.Debugf("storage: upload if match %s: conditional write isn't supported, falling back to head", filepath)
+	info, err := i.stat(ctx, filepath)
+	if err != nil {
+		if errors.Is(err, ErrObjectNotFound) {
+			return ErrPreconditionFailed
+		}
+		return errors.Wrap(err, "storage.uploadIfMatch")
+	}
+	if quoteETag(info.ETag) != quoteETag(expectedETag) {
+		return ErrPreconditionFailed
+	}
+
+	_, err = i.upload(ctx, file, filepath, opts)
+	return err
+}
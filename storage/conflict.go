@@ -0,0 +1,100 @@
+package storage
+
+import (
+	"context"
+	"path"
+	"strconv"
+	"strings"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/s3"
+	"github.com/google/uuid"
+)
+
+// maxRenameAttempts is the number of the numeric suffixes tried by ConflictRename
+// before falling back to a uuid suffix.
+const maxRenameAttempts = 10
+
+// Strategies of the upload to the key of an existing object.
+const (
+	ConflictOverwrite ConflictStrategy = "overwrite"
+	ConflictFail      ConflictStrategy = "fail"
+	ConflictRename    ConflictStrategy = "rename"
+)
+
+// ConflictStrategy tells the upload what to do if an object with the same key already exists:
+// overwrite it (the default), fail with ErrObjectAlreadyExists
+// or store the file under the key with a numeric suffix, e.g. "photo-1.jpg",
+// or a uuid suffix if the first numeric suffixes are taken as well.
+type ConflictStrategy string
+
+// String returns the string representation of the conflict strategy.
+func (s ConflictStrategy) String() string {
+	return string(s)
+}
+
+// validate checks that the strategy is known, the empty strategy means ConflictOverwrite.
+func (s ConflictStrategy) validate() error {
+	switch s {
+	case "", ConflictOverwrite, ConflictFail, ConflictRename:
+		return nil
+	}
+
+	return ErrInvalidConflictStrategy
+}
+
+// resolveKey returns the key to upload the file to according to the conflict strategy.
+// Every strategy except ConflictOverwrite costs a HEAD request per checked key.
+// The check isn't atomic with the upload, so concurrent uploads to the same key may still collide.
+func (i *Interactor) resolveKey(ctx context.Context, key string, strategy ConflictStrategy) (string, error) {
+	if strategy == "" || strategy == ConflictOverwrite {
+		return key, nil
+	}
+
+	exists, err := i.existsWithContext(ctx, key)
+	if err != nil || !exists {
+		return key, err
+	}
+	if strategy == ConflictFail {
+		return "", ErrObjectAlreadyExists
+	}
+
+	for n := 1; n <= maxRenameAttempts; n++ {
+		candidate := suffixedKey(key, strconv.Itoa(n))
+		exists, err := i.existsWithContext(ctx, candidate)
+		if err != nil || !exists {
+			return candidate, err
+		}
+	}
+
+	return suffixedKey(key, uuid.New().String()), nil
+}
+
+// existsWithContext reports whether the object exists with a HEAD request.
+func (i *Interactor) existsWithContext(ctx context.Context, key string) (bool, error) {
+	_, err := i.s3.HeadObjectWithContext(ctx, &s3.HeadObjectInput{
+		Bucket:       aws.String(i.bucket),
+		RequestPayer: i.requestPayer(),
+		Key:          aws.String(trimKey(key)),
+	})
+	if err != nil {
+		if isNotFound(err) {
+			return false, nil
+		}
+		return false, err
+	}
+
+	return true, nil
+}
+
+// suffixedKey inserts the suffix between the name and the extension of the key:
+// "dir/photo.jpg" becomes "dir/photo-<suffix>.jpg".
+func suffixedKey(key, suffix string) string {
+	ext := path.Ext(key)
+	if ext == "" || ext == path.Base(key) {
+		// No extension, or a dot file like ".env".
+		return key + "-" + suffix
+	}
+
+	return strings.TrimSuffix(key, ext) + "-" + suffix + ext
+}
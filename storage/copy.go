@@ -0,0 +1,274 @@
+package storage
+
+import (
+	"fmt"
+	"net/url"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/s3"
+	"github.com/pkg/errors"
+)
+
+// copyPartSize is the min part size of the multipart copy;
+// server-side copy is cheap, so bigger parts save requests.
+const copyPartSize int64 = 512 * mib
+
+// Copy copies the file within the bucket on the server side.
+// The source must not be larger than MaxPartSize (5 GiB), use CopyLarge for bigger files.
+func (i *Interactor) Copy(srcPath, dstPath string, acl ACL) error {
+	return i.CopyWithOptions(srcPath, dstPath, CopyOptions{ACL: acl})
+}
+
+// CopyWithOptions copies the file within the bucket on the server side,
+// setting the ACL, the storage class and the tags of the copy in the same request,
+// e.g. to archive the file to another prefix.
+// Returns ErrInvalidTags if the tags exceed the S3 limits
+// and ErrTaggingConflict if they are set along with the TaggingCopy directive.
+func (i *Interactor) CopyWithOptions(srcPath, dstPath string, opts CopyOptions) error {
+	input := &s3.CopyObjectInput{
+		Bucket:       aws.String(i.bucket),
+		RequestPayer: i.requestPayer(),
+		Key:          aws.String(trimKey(dstPath)),
+		CopySource:   aws.String(i.copySource(srcPath)),
+	}
+	if opts.ACL != "" {
+		input.ACL = aws.String(opts.ACL.String())
+	}
+	if opts.StorageClass != "" {
+		input.StorageClass = aws.String(opts.StorageClass)
+	}
+
+	directive := opts.TaggingDirective
+	if directive == "" && len(opts.Tags) > 0 {
+		directive = TaggingReplace
+	}
+	switch directive {
+	case "":
+	case TaggingCopy:
+		if len(opts.Tags) > 0 {
+			return errors.Wrap(ErrTaggingConflict, "storage.copy")
+		}
+		input.TaggingDirective = aws.String(directive.String())
+	case TaggingReplace:
+		if err := validateTags(opts.Tags); err != nil {
+			return err
+		}
+		input.TaggingDirective = aws.String(directive.String())
+		input.Tagging = aws.String(encodeTags(opts.Tags))
+	default:
+		return errors.Wrap(fmt.Errorf("unknown tagging directive %q", directive), "storage.copy")
+	}
+
+	if err := input.Validate(); err != nil {
+		return errors.Wrap(err, "storage.copy")
+	}
+
+	if _, err := i.s3.CopyObject(input); err != nil {
+		i.logError("storage: copy %s to %s: %v", srcPath, dstPath, err)
+		if isNotFound(err) {
+			return ErrObjectNotFound
+		}
+		return errors.Wrap(err, "storage.copy")
+	}
+
+	return nil
+}
+
+// CopyLarge copies the file of any size within the bucket on the server side.
+// Files up to MaxPartSize are copied with Copy, bigger ones with the multipart copy.
+// Content type and encoding of the source are preserved.
+func (i *Interactor) CopyLarge(srcPath, dstPath string, acl ACL) (err error) {
+	info, err := i.Stat(srcPath)
+	if err != nil {
+		return err
+	}
+	if info.ContentLength <= MaxPartSize {
+		return i.Copy(srcPath, dstPath, acl)
+	}
+
+	uploadID, err := i.CreateMultipartUploadWithOptions(dstPath, UploadOptions{
+		ACL:             acl,
+		ContentType:     info.ContentType,
+		ContentEncoding: info.ContentEncoding,
+	})
+	if err != nil {
+		return err
+	}
+	defer func() {
+		if err != nil {
+			_ = i.abortMultipartUpload(dstPath, uploadID)
+		}
+	}()
+
+	parts, err := i.copyParts(srcPath, dstPath, uploadID, info.ContentLength, 0)
+	if err != nil {
+		return err
+	}
+
+	return i.CompleteMultipartUpload(dstPath, uploadID, parts...)
+}
+
+// copyParts copies the source of the given size as the parts of the multipart upload,
+// leaving room for reservedParts more parts. Every part is at least the min part size.
+func (i *Interactor) copyParts(srcPath, dstPath, uploadID string, size, reservedParts int64) ([]CompletedPart, error) {
+	partSize, err := calculatePartSize(size, i.maxParts-reservedParts, i.minPartSize)
+	if err != nil {
+		return nil, errors.Wrap(err, "storage.copyParts")
+	}
+	if partSize < copyPartSize {
+		partSize = copyPartSize
+	}
+
+	var (
+		parts []CompletedPart
+		start int64
+	)
+	for n, partLen := range copyPartSizes(size, partSize, i.minPartSize) {
+		part, err := i.uploadPartCopy(srcPath, dstPath, uploadID, int64(n+1), start, start+partLen-1)
+		if err != nil {
+			return nil, err
+		}
+		start += partLen
+		parts = append(parts, part)
+	}
+
+	return parts, nil
+}
+
+// copyPartSizes splits the source of the given size into the parts of partSize.
+// The tail too small for a separate part is joined to the last part, since only the last part
+// of the upload can be smaller than the min part size. If the joined part would exceed MaxPartSize,
+// the last two parts are rebalanced into halves instead.
+func copyPartSizes(size, partSize, minPartSize int64) []int64 {
+	var sizes []int64
+	for rest := size; rest > 0; rest -= partSize {
+		if rest <= partSize || (rest-partSize < minPartSize && rest <= MaxPartSize) {
+			return append(sizes, rest)
+		}
+		if rest-partSize < minPartSize {
+			return append(sizes, rest/2, rest-rest/2)
+		}
+		sizes = append(sizes, partSize)
+	}
+
+	return sizes
+}
+
+// uploadPartCopy copies the byte range [start, end] of the source as the part of the multipart upload.
+func (i *Interactor) uploadPartCopy(srcPath, dstPath, uploadID string, partNum, start, end int64) (CompletedPart, error) {
+	input := &s3.UploadPartCopyInput{
+		Bucket:          aws.String(i.bucket),
+		RequestPayer:    i.requestPayer(),
+		Key:             aws.String(trimKey(dstPath)),
+		UploadId:        aws.String(uploadID),
+		PartNumber:      aws.Int64(partNum),
+		CopySource:      aws.String(i.copySource(srcPath)),
+		CopySourceRange: aws.String(fmt.Sprintf("bytes=%d-%d", start, end)),
+	}
+	if err := input.Validate(); err != nil {
+		return nil, errors.Wrap(err, "storage.uploadPartCopy")
+	}
+
+	result, err := i.s3.UploadPartCopy(input)
+	if err != nil {
+		i.logError("storage: copy part %d of %s to %s: %v", partNum, srcPath, dstPath, err)
+		return nil, errors.Wrap(err, "storage.uploadPartCopy")
+	}
+	i.logger.Debugf("storage: copied part %d of %s to %s", partNum, srcPath, dstPath)
+
+	return &completedPart{
+		partNumber: partNum,
+		etag:       aws.StringValue(result.CopyPartResult.ETag),
+	}, nil
+}
+
+// copySource returns the URL-encoded copy source of the object in the bucket.
+func (i *Interactor) copySource(key string) string {
+	return (&url.URL{Path: i.bucket + "/" + trimKey(key)}).EscapedPath()
+}
+
+// RefreshMetadata updates the headers and the metadata of the stored object in place
+// by copying the object onto itself, the content isn't transferred.
+// It's the way to apply e.g. a new Cache-Control policy to the existing objects.
+// The storage class and the server-side encryption of the object are preserved,
+// the ACL is reset unless opts.ACL is set, see MetadataOptions.
+// The object must not be larger than MaxPartSize (5 GiB).
+// Returns ErrObjectNotFound if there is no object with the given path.
+func (i *Interactor) RefreshMetadata(filepath string, opts MetadataOptions) error {
+	head, err := i.s3.HeadObject(&s3.HeadObjectInput{
+		Bucket:       aws.String(i.bucket),
+		RequestPayer: i.requestPayer(),
+		Key:          aws.String(trimKey(filepath)),
+	})
+	if err != nil {
+		if isNotFound(err) {
+			return ErrObjectNotFound
+		}
+		return errors.Wrap(err, "storage.refreshMetadata")
+	}
+
+	encryption := opts.Encryption
+	if encryption.Algorithm == "" {
+		encryption.Algorithm = aws.StringValue(head.ServerSideEncryption)
+	}
+	if encryption.KMSKeyID == "" && encryption.Algorithm == aws.StringValue(head.ServerSideEncryption) {
+		encryption.KMSKeyID = aws.StringValue(head.SSEKMSKeyId)
+	}
+	if err := encryption.validate(); err != nil {
+		return errors.Wrap(err, "storage.refreshMetadata")
+	}
+
+	input := &s3.CopyObjectInput{
+		Bucket:             aws.String(i.bucket),
+		RequestPayer:       i.requestPayer(),
+		Key:                aws.String(trimKey(filepath)),
+		CopySource:         aws.String(i.copySource(filepath)),
+		MetadataDirective:  aws.String(s3.MetadataDirectiveReplace),
+		StorageClass:       head.StorageClass,
+		BucketKeyEnabled:   head.BucketKeyEnabled,
+		ContentType:        stringOr(opts.ContentType, head.ContentType),
+		CacheControl:       stringOr(opts.CacheControl, head.CacheControl),
+		ContentDisposition: stringOr(opts.ContentDisposition, head.ContentDisposition),
+		ContentEncoding:    stringOr(opts.ContentEncoding, head.ContentEncoding),
+		ContentLanguage:    stringOr(opts.ContentLanguage, head.ContentLanguage),
+		Metadata:           aws.StringMap(mergeMetadata(userMetadata(head.Metadata), opts.Metadata)),
+	}
+	if opts.ACL != "" {
+		input.ACL = aws.String(opts.ACL.String())
+	}
+	if encryption.Algorithm != "" {
+		input.ServerSideEncryption = aws.String(encryption.Algorithm)
+	}
+	if encryption.KMSKeyID != "" {
+		input.SSEKMSKeyId = aws.String(encryption.KMSKeyID)
+	}
+	if len(encryption.KMSContext) > 0 {
+		input.SSEKMSEncryptionContext = aws.String(encryption.kmsContext())
+	}
+	if expires := parseExpires(head.Expires); !expires.IsZero() {
+		// The replaced headers include Expires.
+		input.Expires = aws.Time(expires)
+	}
+	if err := input.Validate(); err != nil {
+		return errors.Wrap(err, "storage.refreshMetadata")
+	}
+
+	if _, err := i.s3.CopyObject(input); err != nil {
+		i.logError("storage: refresh metadata of %s: %v", filepath, err)
+		if isNotFound(err) {
+			return ErrObjectNotFound
+		}
+		return errors.Wrap(err, "storage.refreshMetadata")
+	}
+
+	return nil
+}
+
+// stringOr returns s if it's not empty and current otherwise.
+func stringOr(s string, current *string) *string {
+	if s != "" {
+		return aws.String(s)
+	}
+
+	return current
+}
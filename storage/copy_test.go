@@ -0,0 +1,112 @@
+package storage_test
+
+import (
+	"encoding/base64"
+	"fmt"
+	"net/http"
+	"testing"
+
+	"github.com/dmitrymomot/gofs/storage"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestCopyTaggingConflict(t *testing.T) {
+	var requests int
+	s := newLocalInteractor(t, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests++
+	}))
+
+	err := s.CopyWithOptions("src.txt", "dst.txt", storage.CopyOptions{
+		TaggingDirective: storage.TaggingCopy,
+		Tags:             map[string]string{"archived": "true"},
+	})
+	assert.ErrorIs(t, err, storage.ErrTaggingConflict)
+	assert.NotErrorIs(t, err, storage.ErrInvalidTags)
+	assert.Zero(t, requests)
+}
+
+func TestRefreshMetadataRequest(t *testing.T) {
+	var copyRequest http.Header
+	s := newLocalInteractor(t, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, "requester", r.Header.Get("X-Amz-Request-Payer"), r.Method)
+		if r.Method == http.MethodHead {
+			w.Header().Set("Content-Type", "text/plain")
+			w.Header().Set("X-Amz-Storage-Class", "STANDARD_IA")
+			w.Header().Set("X-Amz-Server-Side-Encryption", storage.EncryptionKMS)
+			w.Header().Set("X-Amz-Server-Side-Encryption-Aws-Kms-Key-Id", "arn:aws:kms:us-east-1:1:key/key-id")
+			w.Header().Set("X-Amz-Server-Side-Encryption-Bucket-Key-Enabled", "true")
+			return
+		}
+		copyRequest = r.Header.Clone()
+		fmt.Fprint(w, `<CopyObjectResult><ETag>"etag"</ETag></CopyObjectResult>`)
+	}), storage.WithRequesterPays())
+
+	require.NoError(t, s.RefreshMetadata("file.txt", storage.MetadataOptions{
+		CacheControl: "max-age=3600",
+		Encryption:   storage.Encryption{KMSContext: map[string]string{"tenant": "42"}},
+	}))
+	require.NotNil(t, copyRequest)
+	assert.Equal(t, "bucket/file.txt", copyRequest.Get("X-Amz-Copy-Source"))
+	assert.Equal(t, "REPLACE", copyRequest.Get("X-Amz-Metadata-Directive"))
+	assert.Equal(t, "text/plain", copyRequest.Get("Content-Type"))
+	assert.Equal(t, "max-age=3600", copyRequest.Get("Cache-Control"))
+	assert.Equal(t, "STANDARD_IA", copyRequest.Get("X-Amz-Storage-Class"))
+	assert.Equal(t, storage.EncryptionKMS, copyRequest.Get("X-Amz-Server-Side-Encryption"))
+	assert.Equal(t, "arn:aws:kms:us-east-1:1:key/key-id", copyRequest.Get("X-Amz-Server-Side-Encryption-Aws-Kms-Key-Id"))
+	assert.Equal(t, "true", copyRequest.Get("X-Amz-Server-Side-Encryption-Bucket-Key-Enabled"))
+	assert.Equal(t, base64.StdEncoding.EncodeToString([]byte(`{"tenant":"42"}`)), copyRequest.Get("X-Amz-Server-Side-Encryption-Context"))
+	assert.Empty(t, copyRequest.Get("X-Amz-Acl"), "the ACL is reset to the bucket default")
+
+	err := s.RefreshMetadata("file.txt", storage.MetadataOptions{
+		Encryption: storage.Encryption{Algorithm: storage.EncryptionAES256, KMSKeyID: "key-id"},
+	})
+	assert.ErrorIs(t, err, storage.ErrEncryptionMismatch)
+}
+
+func TestCopyPartSizes(t *testing.T) {
+	const mib = int64(1 << 20)
+	nearMax := storage.MaxPartSize - 2*mib
+
+	for name, tc := range map[string]struct {
+		size, partSize int64
+		expected       []int64
+	}{
+		"single part":    {size: 100 * mib, partSize: 512 * mib, expected: []int64{100 * mib}},
+		"exact multiple": {size: 3 * 512 * mib, partSize: 512 * mib, expected: []int64{512 * mib, 512 * mib, 512 * mib}},
+		"separate tail":  {size: 1024*mib + 10*mib, partSize: 512 * mib, expected: []int64{512 * mib, 512 * mib, 10 * mib}},
+		"joined tail":    {size: 10*512*mib + 1, partSize: 512 * mib, expected: append(repeat(512*mib, 9), 512*mib+1)},
+		"joined tail up to the max part size": {
+			size: 2*storage.MaxPartSize - 3*mib, partSize: storage.MaxPartSize - 3*mib,
+			expected: []int64{storage.MaxPartSize - 3*mib, storage.MaxPartSize},
+		},
+		"rebalanced tail over the max part size": {
+			size: 2*nearMax + 3*mib, partSize: nearMax,
+			expected: []int64{nearMax, (nearMax + 3*mib) / 2, (nearMax + 3*mib) / 2},
+		},
+		"rebalanced parts of the max size": {
+			size: 2*storage.MaxPartSize + 1, partSize: storage.MaxPartSize,
+			expected: []int64{storage.MaxPartSize, storage.MaxPartSize / 2, storage.MaxPartSize/2 + 1},
+		},
+	} {
+		t.Run(name, func(t *testing.T) {
+			sizes := storage.CopyPartSizes(tc.size, tc.partSize, storage.MinPartSize)
+			assert.Equal(t, tc.expected, sizes)
+
+			var total int64
+			for _, size := range sizes {
+				assert.LessOrEqual(t, size, storage.MaxPartSize)
+				total += size
+			}
+			assert.Equal(t, tc.size, total)
+		})
+	}
+}
+
+func repeat(size int64, n int) []int64 {
+	sizes := make([]int64, n)
+	for i := range sizes {
+		sizes[i] = size
+	}
+	return sizes
+}
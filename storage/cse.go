@@ -0,0 +1,153 @@
+package storage
+
+import (
+	"bytes"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"encoding/base64"
+	"io"
+	"strings"
+
+	"github.com/pkg/errors"
+)
+
+// Metadata keys of the client-side encrypted objects.
+const (
+	cseAlgorithmMeta = "gofs-cse-alg"
+	cseKeyIDMeta     = "gofs-cse-key-id"
+	cseNonceMeta     = "gofs-cse-nonce"
+	cseAlgorithm     = "AES-GCM"
+)
+
+type (
+	// KeyProvider provides AES keys (16, 24 or 32 bytes long) for the client-side encryption.
+	KeyProvider interface {
+		// EncryptionKey returns the key to encrypt new objects and its ID.
+		EncryptionKey() (keyID string, key []byte, err error)
+		// DecryptionKey returns the key with the given ID to decrypt objects.
+		DecryptionKey(keyID string) ([]byte, error)
+	}
+
+	// staticKey is a KeyProvider with a single key.
+	staticKey []byte
+
+	// decryptingReader decrypts the whole object on the first read,
+	// since AES-GCM authenticates the content as a whole.
+	decryptingReader struct {
+		body      io.ReadCloser
+		aead      cipher.AEAD
+		nonce     []byte
+		plaintext *bytes.Reader
+	}
+)
+
+// StaticKey returns a KeyProvider with a single key.
+func StaticKey(key []byte) KeyProvider {
+	return staticKey(key)
+}
+
+// EncryptionKey returns the static key.
+func (k staticKey) EncryptionKey() (string, []byte, error) {
+	return "static", k, nil
+}
+
+// DecryptionKey returns the static key regardless of the key ID.
+func (k staticKey) DecryptionKey(string) ([]byte, error) {
+	return k, nil
+}
+
+// newAEAD returns AES-GCM cipher with the given key.
+func newAEAD(key []byte) (cipher.AEAD, error) {
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+
+	return cipher.NewGCM(block)
+}
+
+// encrypt encrypts the data with the current key of the provider
+// and returns the ciphertext and the metadata required to decrypt it.
+func encrypt(keys KeyProvider, data []byte) ([]byte, map[string]string, error) {
+	keyID, key, err := keys.EncryptionKey()
+	if err != nil {
+		return nil, nil, err
+	}
+
+	aead, err := newAEAD(key)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	nonce := make([]byte, aead.NonceSize())
+	if _, err := rand.Read(nonce); err != nil {
+		return nil, nil, err
+	}
+
+	return aead.Seal(nil, nonce, data, nil), map[string]string{
+		cseAlgorithmMeta: cseAlgorithm,
+		cseKeyIDMeta:     keyID,
+		cseNonceMeta:     base64.StdEncoding.EncodeToString(nonce),
+	}, nil
+}
+
+// decryptBody wraps the body of the client-side encrypted object in the decrypting reader.
+// Bodies of not encrypted objects are returned as is.
+func decryptBody(keys KeyProvider, body io.ReadCloser, metadata map[string]*string) (io.ReadCloser, error) {
+	if lookupMeta(metadata, cseAlgorithmMeta) != cseAlgorithm {
+		return body, nil
+	}
+
+	key, err := keys.DecryptionKey(lookupMeta(metadata, cseKeyIDMeta))
+	if err != nil {
+		return nil, err
+	}
+
+	aead, err := newAEAD(key)
+	if err != nil {
+		return nil, err
+	}
+
+	nonce, err := base64.StdEncoding.DecodeString(lookupMeta(metadata, cseNonceMeta))
+	if err != nil || len(nonce) != aead.NonceSize() {
+		return nil, ErrInvalidEncryptionMetadata
+	}
+
+	return &decryptingReader{body: body, aead: aead, nonce: nonce}, nil
+}
+
+// lookupMeta returns the metadata value by the case-insensitive key,
+// since S3 returns the metadata keys in the canonical header format.
+func lookupMeta(metadata map[string]*string, key string) string {
+	for k, v := range metadata {
+		if strings.EqualFold(k, key) && v != nil {
+			return *v
+		}
+	}
+
+	return ""
+}
+
+// Read reads the decrypted content.
+func (r *decryptingReader) Read(p []byte) (int, error) {
+	if r.plaintext == nil {
+		ciphertext, err := io.ReadAll(r.body)
+		if err != nil {
+			return 0, err
+		}
+
+		plaintext, err := r.aead.Open(nil, r.nonce, ciphertext, nil)
+		if err != nil {
+			return 0, errors.Wrap(ErrDecryptionFailed, err.Error())
+		}
+		r.plaintext = bytes.NewReader(plaintext)
+	}
+
+	return r.plaintext.Read(p)
+}
+
+// Close closes the encrypted body.
+func (r *decryptingReader) Close() error {
+	return r.body.Close()
+}
@@ -0,0 +1,98 @@
+package storage
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"io"
+	"path"
+
+	"github.com/pkg/errors"
+)
+
+// ContentIndex finds already stored content by its hash,
+// e.g. with a database lookup.
+type ContentIndex interface {
+	// Lookup returns the key of the stored content with the given hex-encoded SHA256 hash.
+	Lookup(hash string) (key string, found bool, err error)
+	// Remember records the key of the uploaded content with the given hash.
+	Remember(hash, key string) error
+}
+
+// Exists reports whether the file exists in the cloud storage.
+func (i *Interactor) Exists(filepath string) (bool, error) {
+	if _, err := i.Stat(filepath); err != nil {
+		if errors.Is(err, ErrObjectNotFound) {
+			return false, nil
+		}
+		return false, err
+	}
+
+	return true, nil
+}
+
+// PutContentAddressed uploads the file under the key derived from its content hash:
+// prefix followed by the hex-encoded SHA256 of the content.
+// If identical content is already stored, the upload is skipped
+// and the existing key is returned, so duplicates don't consume storage.
+// The existence is checked with the content index set by WithContentIndex,
+// or with a HEAD request of the derived key if there is no index.
+// Concurrent uploads of the same content are deduplicated within the process.
+func (i *Interactor) PutContentAddressed(r io.ReadSeeker, prefix, contentType string, acl ACL) (key, url string, err error) {
+	if r == nil {
+		return "", "", errors.Wrap(ErrInvalidReader, "storage.putContentAddressed")
+	}
+
+	h := sha256.New()
+	if _, err := io.Copy(h, r); err != nil {
+		return "", "", errors.Wrap(err, "storage.putContentAddressed")
+	}
+	hash := hex.EncodeToString(h.Sum(nil))
+
+	v, err, _ := i.dedup.Do(path.Join(prefix, hash), func() (interface{}, error) {
+		return i.putContent(r, hash, prefix, contentType, acl)
+	})
+	if err != nil {
+		return "", "", err
+	}
+
+	key = v.(string)
+	return key, i.FileURL(key), nil
+}
+
+// putContent uploads the content with the given hash unless it's already stored.
+func (i *Interactor) putContent(r io.ReadSeeker, hash, prefix, contentType string, acl ACL) (string, error) {
+	key := path.Join(prefix, hash)
+
+	if i.contentIndex != nil {
+		existing, found, err := i.contentIndex.Lookup(hash)
+		if err != nil {
+			return "", errors.Wrap(err, "storage.putContentAddressed: lookup")
+		}
+		if found {
+			return existing, nil
+		}
+	} else {
+		exists, err := i.Exists(key)
+		if err != nil {
+			return "", errors.Wrap(err, "storage.putContentAddressed")
+		}
+		if exists {
+			return key, nil
+		}
+	}
+
+	if _, err := r.Seek(0, io.SeekStart); err != nil {
+		return "", errors.Wrap(err, "storage.putContentAddressed")
+	}
+	if err := i.PutFile(r, key, contentType, acl); err != nil {
+		return "", err
+	}
+
+	if i.contentIndex != nil {
+		if err := i.contentIndex.Remember(hash, key); err != nil {
+			return "", errors.Wrap(err, "storage.putContentAddressed: remember")
+		}
+	}
+
+	return key, nil
+}
@@ -0,0 +1,141 @@
+package storage_test
+
+import (
+	"errors"
+	"net/http"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/dmitrymomot/gofs/storage"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// sha256("Hello, World!")
+const helloHash = "dffd6021bb2bd5b0af676290809ec3a53191dd81c7f70a4b28688a362182986f"
+
+// memContentIndex is the content index kept in memory.
+type memContentIndex struct {
+	keys      map[string]string
+	lookupErr error
+}
+
+func (idx *memContentIndex) Lookup(hash string) (string, bool, error) {
+	key, ok := idx.keys[hash]
+	return key, ok, idx.lookupErr
+}
+
+func (idx *memContentIndex) Remember(hash, key string) error {
+	idx.keys[hash] = key
+	return nil
+}
+
+// newObjectServer returns the handler storing the uploaded objects in memory
+// and the log of the served requests.
+func newObjectServer() (http.Handler, func() []string) {
+	var (
+		mu       sync.Mutex
+		objects  = map[string]bool{}
+		requests []string
+	)
+	handler := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		defer mu.Unlock()
+
+		requests = append(requests, r.Method+" "+r.URL.Path)
+		switch r.Method {
+		case http.MethodPut:
+			objects[r.URL.Path] = true
+			w.Header().Set("ETag", `"etag"`)
+		case http.MethodHead:
+			switch {
+			case strings.HasSuffix(r.URL.Path, "/forbidden.txt"):
+				w.WriteHeader(http.StatusForbidden)
+			case !objects[r.URL.Path]:
+				w.WriteHeader(http.StatusNotFound)
+			}
+		}
+	})
+
+	return handler, func() []string {
+		mu.Lock()
+		defer mu.Unlock()
+
+		log := requests
+		requests = nil
+		return log
+	}
+}
+
+func TestExists(t *testing.T) {
+	handler, _ := newObjectServer()
+	s := newLocalInteractor(t, handler)
+	require.NoError(t, s.Upload([]byte("Hello, World!"), "file.txt", storage.Private, "text/plain"))
+
+	exists, err := s.Exists("file.txt")
+	require.NoError(t, err)
+	assert.True(t, exists)
+
+	exists, err = s.Exists("missed.txt")
+	require.NoError(t, err)
+	assert.False(t, exists)
+
+	_, err = s.Exists("forbidden.txt")
+	assert.Error(t, err)
+}
+
+func TestPutContentAddressed(t *testing.T) {
+	handler, requests := newObjectServer()
+	s := newLocalInteractor(t, handler)
+
+	// The key is derived from the content hash.
+	key, url, err := s.PutContentAddressed(strings.NewReader("Hello, World!"), "blobs", "text/plain", storage.Private)
+	require.NoError(t, err)
+	assert.Equal(t, "blobs/"+helloHash, key)
+	assert.True(t, strings.HasSuffix(url, "/blobs/"+helloHash), url)
+	assert.Equal(t, []string{
+		"HEAD /bucket/blobs/" + helloHash,
+		"PUT /bucket/blobs/" + helloHash,
+	}, requests())
+
+	// The same content isn't uploaded again.
+	key, _, err = s.PutContentAddressed(strings.NewReader("Hello, World!"), "blobs", "text/plain", storage.Private)
+	require.NoError(t, err)
+	assert.Equal(t, "blobs/"+helloHash, key)
+	assert.Equal(t, []string{"HEAD /bucket/blobs/" + helloHash}, requests())
+
+	// Another content gets another key.
+	key, _, err = s.PutContentAddressed(strings.NewReader("Bye, World!"), "blobs", "text/plain", storage.Private)
+	require.NoError(t, err)
+	assert.NotEqual(t, "blobs/"+helloHash, key)
+	assert.Len(t, requests(), 2)
+
+	_, _, err = s.PutContentAddressed(nil, "blobs", "text/plain", storage.Private)
+	assert.ErrorIs(t, err, storage.ErrInvalidReader)
+}
+
+func TestPutContentAddressedWithIndex(t *testing.T) {
+	handler, requests := newObjectServer()
+	idx := &memContentIndex{keys: map[string]string{}}
+	s := newLocalInteractor(t, handler, storage.WithContentIndex(idx))
+
+	// The miss is uploaded without the HEAD request and remembered.
+	key, _, err := s.PutContentAddressed(strings.NewReader("Hello, World!"), "blobs", "text/plain", storage.Private)
+	require.NoError(t, err)
+	assert.Equal(t, "blobs/"+helloHash, key)
+	assert.Equal(t, []string{"PUT /bucket/blobs/" + helloHash}, requests())
+	assert.Equal(t, map[string]string{helloHash: "blobs/" + helloHash}, idx.keys)
+
+	// The hit returns the indexed key, which may be stored under another prefix.
+	idx.keys[helloHash] = "legacy/hello.txt"
+	key, _, err = s.PutContentAddressed(strings.NewReader("Hello, World!"), "blobs", "text/plain", storage.Private)
+	require.NoError(t, err)
+	assert.Equal(t, "legacy/hello.txt", key)
+	assert.Empty(t, requests())
+
+	idx.lookupErr = errors.New("index is down")
+	_, _, err = s.PutContentAddressed(strings.NewReader("Hello, World!"), "blobs", "text/plain", storage.Private)
+	assert.ErrorIs(t, err, idx.lookupErr)
+	assert.Empty(t, requests())
+}
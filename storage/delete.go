@@ -0,0 +1,107 @@
+package storage
+
+import (
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/awserr"
+	"github.com/aws/aws-sdk-go/service/s3"
+	"github.com/pkg/errors"
+)
+
+// maxDeleteObjects is the max number of keys S3 deletes in a single request.
+const maxDeleteObjects = 1000
+
+// DeletePrefixOptions holds optional parameters of DeletePrefixWithOptions.
+type DeletePrefixOptions struct {
+	// AllowEmptyPrefix allows deleting with the empty prefix, i.e. all objects in the bucket.
+	AllowEmptyPrefix bool
+}
+
+// DeleteMany removes the files from the cloud storage in batches.
+// Returns the keys of the deleted files; failed keys are reported by *BatchError.
+// In the dry-run mode returns the keys which would be deleted.
+func (i *Interactor) DeleteMany(keys ...string) ([]string, error) {
+	deleted := make([]string, 0, len(keys))
+	failed := make(map[string]error)
+
+	for start := 0; start < len(keys); start += maxDeleteObjects {
+		end := start + maxDeleteObjects
+		if end > len(keys) {
+			end = len(keys)
+		}
+
+		objects := make([]*s3.ObjectIdentifier, 0, end-start)
+		for _, key := range keys[start:end] {
+			objects = append(objects, &s3.ObjectIdentifier{Key: aws.String(trimKey(key))})
+		}
+
+		input := &s3.DeleteObjectsInput{
+			Bucket:       aws.String(i.bucket),
+			RequestPayer: i.requestPayer(),
+			Delete:       &s3.Delete{Objects: objects, Quiet: aws.Bool(true)},
+		}
+		if err := input.Validate(); err != nil {
+			return deleted, errors.Wrap(err, "storage.deleteMany")
+		}
+
+		if i.dryRun {
+			i.logger.Debugf("storage: dry run: delete %d objects: %v", end-start, keys[start:end])
+			deleted = append(deleted, keys[start:end]...)
+			continue
+		}
+
+		result, err := i.s3.DeleteObjects(input)
+		if err != nil {
+			return deleted, errors.Wrap(err, "storage.deleteMany")
+		}
+
+		// In the quiet mode only failed keys are returned.
+		for _, e := range result.Errors {
+			failed[aws.StringValue(e.Key)] = awserr.New(aws.StringValue(e.Code), aws.StringValue(e.Message), nil)
+		}
+		for _, key := range keys[start:end] {
+			if _, ok := failed[key]; !ok {
+				deleted = append(deleted, key)
+			}
+		}
+	}
+
+	if len(failed) > 0 {
+		i.logError("storage: failed to delete %d objects: %v", len(failed), failed)
+		return deleted, errors.Wrap(batchError(failed), "storage.deleteMany")
+	}
+
+	return deleted, nil
+}
+
+// DeletePrefix recursively removes all files with the given prefix, e.g. "uploads/tenant-a/".
+// Returns the number of deleted files.
+// The empty prefix is rejected with ErrEmptyPrefix, use DeletePrefixWithOptions to allow it.
+func (i *Interactor) DeletePrefix(prefix string) (int, error) {
+	deleted, err := i.DeletePrefixWithOptions(prefix, DeletePrefixOptions{})
+	return len(deleted), err
+}
+
+// DeletePrefixWithOptions recursively removes all files with the given prefix.
+// Returns the keys of the deleted files, or the keys of the files which would be deleted in the dry-run mode.
+func (i *Interactor) DeletePrefixWithOptions(prefix string, opts DeletePrefixOptions) ([]string, error) {
+	if prefix == "" && !opts.AllowEmptyPrefix {
+		return nil, ErrEmptyPrefix
+	}
+
+	var deleted []string
+	err := i.walk(prefix, func(objects []*s3.Object) error {
+		keys := make([]string, 0, len(objects))
+		for _, obj := range objects {
+			keys = append(keys, aws.StringValue(obj.Key))
+		}
+
+		batch, err := i.DeleteMany(keys...)
+		deleted = append(deleted, batch...)
+		return err
+	})
+	if err != nil {
+		return deleted, errors.Wrap(err, "storage.deletePrefix")
+	}
+
+	return deleted, nil
+}
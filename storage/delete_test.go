@@ -0,0 +1,61 @@
+package storage_test
+
+import (
+	"fmt"
+	"net/http"
+	"testing"
+
+	"github.com/dmitrymomot/gofs/storage"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestDeletePrefixDryRun(t *testing.T) {
+	var deletes int
+	s := newLocalInteractor(t, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodGet {
+			deletes++
+			return
+		}
+		fmt.Fprint(w, `<?xml version="1.0" encoding="UTF-8"?>
+<ListBucketResult xmlns="http://s3.amazonaws.com/doc/2006-03-01/">
+	<Name>bucket</Name>
+	<Prefix>tmp/</Prefix>
+	<IsTruncated>false</IsTruncated>
+	<Contents><Key>tmp/a.txt</Key><Size>3</Size></Contents>
+	<Contents><Key>tmp/b.txt</Key><Size>5</Size></Contents>
+</ListBucketResult>`)
+	}), storage.WithDryRun())
+
+	keys, err := s.DeletePrefixWithOptions("tmp/", storage.DeletePrefixOptions{})
+	require.NoError(t, err)
+	assert.Equal(t, []string{"tmp/a.txt", "tmp/b.txt"}, keys)
+
+	n, err := s.DeletePrefix("tmp/")
+	require.NoError(t, err)
+	assert.Equal(t, 2, n)
+	assert.Zero(t, deletes, "nothing is deleted")
+
+	_, err = s.DeletePrefixWithOptions("", storage.DeletePrefixOptions{})
+	assert.ErrorIs(t, err, storage.ErrEmptyPrefix)
+}
+
+func TestDryRunCleanup(t *testing.T) {
+	var requests []string
+	s := newLocalInteractor(t, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests = append(requests, r.Method)
+		switch {
+		case r.Method == http.MethodHead:
+			w.Header().Set("Content-Length", "5")
+		case r.Header.Get("X-Amz-Copy-Source") != "":
+			fmt.Fprint(w, `<CopyObjectResult><ETag>"etag"</ETag></CopyObjectResult>`)
+		}
+	}), storage.WithDryRun())
+
+	require.NoError(t, s.Delete("file.txt"))
+	assert.Empty(t, requests, "nothing is deleted")
+
+	// The temporary object is deleted even in the dry-run mode.
+	require.NoError(t, s.AtomicReplace("file.txt", []byte("Hello"), storage.Private, "text/plain"))
+	assert.Equal(t, []string{http.MethodPut, http.MethodHead, http.MethodPut, http.MethodDelete}, requests)
+}
@@ -0,0 +1,186 @@
+package storage
+
+import (
+	"context"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/s3"
+	"github.com/pkg/errors"
+)
+
+// DownloadMany downloads the objects into destDir in parallel, at most concurrency at a time.
+// The files are named by the object keys relative to destDir, subdirectories are created as needed.
+// A failed download doesn't stop the others: the failed keys are reported by *BatchError.
+// If ctx is canceled, the pending downloads fail with the context error,
+// so the returned error matches it with errors.Is.
+// Keys resolving outside of destDir fail with ErrUnsafePath.
+func (i *Interactor) DownloadMany(ctx context.Context, keys []string, destDir string, concurrency int) error {
+	if concurrency < 1 {
+		concurrency = 1
+	}
+	if err := os.MkdirAll(destDir, 0o755); err != nil {
+		return errors.Wrap(err, "storage.downloadMany")
+	}
+
+	var (
+		mu       sync.Mutex
+		wg       sync.WaitGroup
+		failures = make(map[string]error)
+		sem      = make(chan struct{}, concurrency)
+	)
+	fail := func(key string, err error) {
+		mu.Lock()
+		failures[key] = err
+		mu.Unlock()
+	}
+
+	for _, key := range keys {
+		select {
+		case <-ctx.Done():
+			fail(key, ctx.Err())
+			continue
+		case sem <- struct{}{}:
+		}
+
+		wg.Add(1)
+		go func(key string) {
+			defer func() {
+				<-sem
+				wg.Done()
+			}()
+
+			if err := i.downloadFile(ctx, key, destDir); err != nil {
+				fail(key, err)
+			}
+		}(key)
+	}
+	wg.Wait()
+
+	return batchError(failures)
+}
+
+// downloadFile downloads the object into the file named by its key relative to destDir.
+// The content is written to a temporary file which is renamed on success,
+// so a failed download doesn't leave a partial file.
+func (i *Interactor) downloadFile(ctx context.Context, key, destDir string) (err error) {
+	dest := filepath.Join(destDir, filepath.FromSlash(key))
+	if rel, err := filepath.Rel(destDir, dest); err != nil || rel == "." || strings.HasPrefix(rel, ".."+string(filepath.Separator)) || rel == ".." {
+		return ErrUnsafePath
+	}
+	if err := os.MkdirAll(filepath.Dir(dest), 0o755); err != nil {
+		return errors.Wrap(err, "storage.downloadMany")
+	}
+
+	body, _, err := i.DownloadVersionWithContext(ctx, key, "")
+	if err != nil {
+		return err
+	}
+	defer body.Close()
+
+	tmp, err := os.CreateTemp(filepath.Dir(dest), ".gofs-download-*")
+	if err != nil {
+		return errors.Wrap(err, "storage.downloadMany")
+	}
+	defer func() {
+		if err != nil {
+			_ = tmp.Close()
+			_ = os.Remove(tmp.Name())
+		}
+	}()
+
+	if _, err := io.Copy(tmp, body); err != nil {
+		return errors.Wrap(err, "storage.downloadMany")
+	}
+	if err := tmp.Close(); err != nil {
+		return errors.Wrap(err, "storage.downloadMany")
+	}
+
+	return errors.Wrap(os.Rename(tmp.Name(), dest), "storage.downloadMany")
+}
+
+// teeBody is the download body copying the read content into the cache.
+type teeBody struct {
+	io.Reader
+	body io.Closer
+}
+
+// Close closes the download body.
+func (b *teeBody) Close() error {
+	return b.body.Close()
+}
+
+// DownloadTee downloads the file like Download and returns the body
+// which writes everything read from it into the cache, e.g. a local file,
+// so the content can be served and cached at once.
+// The cache gets the complete copy only if the body is read to the end:
+// a partial read yields a partial copy. A failed cache write fails the read.
+// Returns ErrObjectNotFound if there is no object with the given path.
+func (i *Interactor) DownloadTee(filepath string, cache io.Writer) (io.ReadCloser, *ObjectInfo, error) {
+	if cache == nil {
+		return nil, nil, errors.Wrap(ErrInvalidWriter, "storage.downloadTee")
+	}
+
+	input := &s3.GetObjectInput{
+		Bucket:       aws.String(i.bucket),
+		RequestPayer: i.requestPayer(),
+		Key:          aws.String(trimKey(filepath)),
+	}
+	if err := input.Validate(); err != nil {
+		return nil, nil, errors.Wrap(err, "storage.downloadTee")
+	}
+
+	result, err := i.s3.GetObject(input)
+	if err != nil {
+		if isNotFound(err) {
+			return nil, nil, ErrObjectNotFound
+		}
+		i.logError("storage: download %s: %v", filepath, err)
+		return nil, nil, errors.Wrap(err, "storage.downloadTee")
+	}
+
+	body, err := i.decrypt(result)
+	if err != nil {
+		return nil, nil, errors.Wrap(err, "storage.downloadTee")
+	}
+
+	return &teeBody{Reader: io.TeeReader(body, cache), body: body}, objectInfoFromGet(filepath, result), nil
+}
+
+// DownloadBytes downloads the whole file into memory and returns it with its content type.
+// The size is checked with a HEAD request first: ErrFileTooLarge is returned
+// without downloading if the file is bigger than maxBytes. The body is read up to maxBytes as well,
+// so the file replaced by a bigger one meanwhile fails the same way.
+// Returns ErrObjectNotFound if there is no object with the given path.
+func (i *Interactor) DownloadBytes(filepath string, maxBytes int64) ([]byte, string, error) {
+	info, err := i.Stat(filepath)
+	if err != nil {
+		return nil, "", err
+	}
+	if info.ContentLength > maxBytes {
+		return nil, "", errors.Wrap(ErrFileTooLarge, "storage.downloadBytes")
+	}
+
+	body, contentType, err := i.Download(filepath)
+	if err != nil {
+		if isNotFound(err) {
+			return nil, "", ErrObjectNotFound
+		}
+		return nil, "", err
+	}
+	defer body.Close()
+
+	data, err := io.ReadAll(io.LimitReader(body, maxBytes+1))
+	if err != nil {
+		return nil, "", errors.Wrap(err, "storage.downloadBytes")
+	}
+	if int64(len(data)) > maxBytes {
+		return nil, "", errors.Wrap(ErrFileTooLarge, "storage.downloadBytes")
+	}
+
+	return data, aws.StringValue(contentType), nil
+}
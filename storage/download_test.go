@@ -0,0 +1,49 @@
+package storage_test
+
+import (
+	"io"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestDownloadIfModified(t *testing.T) {
+	modified := time.Date(2024, 6, 15, 12, 0, 0, 0, time.UTC)
+	s := newLocalInteractor(t, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		since, _ := http.ParseTime(r.Header.Get("If-Modified-Since"))
+		if r.Header.Get("If-None-Match") == `"etag"` || !since.Before(modified) {
+			w.WriteHeader(http.StatusNotModified)
+			return
+		}
+
+		w.Header().Set("ETag", `"etag"`)
+		w.Header().Set("Last-Modified", modified.Format(http.TimeFormat))
+		w.Header().Set("Content-Type", "text/plain")
+		_, _ = w.Write([]byte("Hello, World!"))
+	}))
+
+	body, info, notModified, err := s.DownloadIfModified("file.txt", `"old-etag"`, modified.Add(-time.Hour))
+	require.NoError(t, err)
+	require.False(t, notModified)
+	data, err := io.ReadAll(body)
+	require.NoError(t, err)
+	body.Close()
+	assert.Equal(t, "Hello, World!", string(data))
+	assert.Equal(t, `"etag"`, info.ETag)
+	assert.Equal(t, modified, info.LastModified)
+
+	// The unchanged file isn't downloaded.
+	body, info, notModified, err = s.DownloadIfModified("file.txt", `"etag"`, time.Time{})
+	require.NoError(t, err)
+	assert.True(t, notModified)
+	assert.Nil(t, body)
+	assert.Nil(t, info)
+
+	body, _, notModified, err = s.DownloadIfModified("file.txt", "", modified)
+	require.NoError(t, err)
+	assert.True(t, notModified)
+	assert.Nil(t, body)
+}
@@ -0,0 +1,67 @@
+package storage
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"strings"
+
+	"github.com/aws/aws-sdk-go/service/s3"
+)
+
+// Predefined server-side encryption algorithms
+const (
+	EncryptionAES256 = s3.ServerSideEncryptionAes256
+	EncryptionKMS    = s3.ServerSideEncryptionAwsKms
+)
+
+// Encryption holds server-side encryption settings.
+//
+// For SSE-KMS multipart uploads the key and the encryption context are supplied once,
+// at the multipart initialization, and are inherited by every part:
+// UploadPart doesn't need them re-supplied.
+// Use UploadPartWithOptions to check the parts are encrypted as expected.
+type Encryption struct {
+	// Algorithm is EncryptionAES256 or EncryptionKMS.
+	Algorithm string
+
+	// KMSKeyID is the ID, ARN or alias of the KMS key; the default bucket key is used if empty.
+	// KMSContext is the encryption context, it must be supplied to decrypt the object later.
+	// Both are allowed for EncryptionKMS only.
+	KMSKeyID   string
+	KMSContext map[string]string
+}
+
+// validate checks the consistency of the encryption settings.
+func (e Encryption) validate() error {
+	if e.Algorithm != EncryptionKMS && (e.KMSKeyID != "" || len(e.KMSContext) > 0) {
+		return ErrEncryptionMismatch
+	}
+
+	return nil
+}
+
+// kmsContext returns the encryption context encoded as base64 JSON, as S3 expects it.
+func (e Encryption) kmsContext() string {
+	if len(e.KMSContext) == 0 {
+		return ""
+	}
+
+	data, _ := json.Marshal(e.KMSContext) // map of strings is always encodable
+	return base64.StdEncoding.EncodeToString(data)
+}
+
+// matches reports whether the encryption applied by S3 matches the expected settings.
+// The KMS key is compared by the suffix, since S3 returns the key ARN.
+func (e Encryption) matches(algorithm, kmsKeyID string) bool {
+	if e.Algorithm == "" {
+		return true
+	}
+	if e.Algorithm != algorithm {
+		return false
+	}
+	if e.KMSKeyID != "" && kmsKeyID != e.KMSKeyID && !strings.HasSuffix(kmsKeyID, "/"+e.KMSKeyID) {
+		return false
+	}
+
+	return true
+}
@@ -2,15 +2,133 @@ package storage
 
 import (
 	"errors"
+	"fmt"
+	"net/http"
+
+	"github.com/aws/aws-sdk-go/aws/awserr"
+	"github.com/aws/aws-sdk-go/service/s3"
 )
 
 // Predefined paackage errors
 var (
-	ErrMissedUploadID     = errors.New("upload id is missed or empty")
-	ErrNoCompletedParts   = errors.New("no completed parts, nothing to upload")
-	ErrTotalParts         = errors.New("total parts can be between 1 and 10000")
-	ErrPartNum            = errors.New("part number can be between 1 and total parts")
-	ErrFileEmpty          = errors.New("file is empty")
-	ErrInvalidContentType = errors.New("invalid content type")
-	ErrInvalidReader      = errors.New("invalid reader provided or reader is nil")
+	ErrMissedUploadID            = errors.New("upload id is missed or empty")
+	ErrNoCompletedParts          = errors.New("no completed parts, nothing to upload")
+	ErrTotalParts                = errors.New("total parts can be between 1 and the max parts limit (10000 for AWS S3)")
+	ErrPartNum                   = errors.New("part number can be between 1 and total parts")
+	ErrPartTooSmall              = errors.New("part is smaller than the min part size, only the last part can be smaller")
+	ErrFileEmpty                 = errors.New("file is empty")
+	ErrExtensionMismatch         = errors.New("file extension doesn't match the content type")
+	ErrInvalidContentType        = errors.New("invalid content type")
+	ErrInvalidReader             = errors.New("invalid reader provided or reader is nil")
+	ErrObjectNotFound            = errors.New("object not found")
+	ErrFileTooLarge              = errors.New("file is too large")
+	ErrInvalidPart               = errors.New("completed part doesn't match the uploaded one")
+	ErrChecksumMismatch          = errors.New("object checksum mismatch")
+	ErrInvalidEncryptionMetadata = errors.New("invalid client-side encryption metadata")
+	ErrInvalidChecksum           = errors.New("invalid checksum format")
+	ErrDecryptionFailed          = errors.New("failed to decrypt the object")
+	ErrEmptyPrefix               = errors.New("empty prefix matches all objects in the bucket")
+	ErrEncryptionMismatch        = errors.New("encryption settings are inconsistent or don't match the multipart upload")
+	ErrInvalidPostPolicy         = errors.New("invalid post policy content length range")
+	ErrInvalidRetention          = errors.New("object lock mode and retain until date must be set together, the date must be in the future")
+	ErrInvalidSigningKey         = errors.New("signing key or key pair id is missed")
+	ErrInvalidJPEG               = errors.New("invalid JPEG image")
+	ErrUnsupportedArchiveFormat  = errors.New("unsupported archive format")
+	ErrUnsafePath                = errors.New("object key escapes the destination directory")
+	ErrWrongRegion               = errors.New("bucket is in another region")
+	ErrInvalidSelectQuery        = errors.New("invalid select query")
+	ErrIncompleteSelect          = errors.New("select result stream ended unexpectedly")
+	ErrInvalidKey                = errors.New("invalid object key")
+	ErrInvalidTags               = errors.New("tags exceed the limits: up to 10 tags, keys up to 128 and values up to 256 characters")
+	ErrPartTooLarge              = errors.New("part is larger than the max part size (5 GiB for AWS S3)")
+	ErrUnknownProvider           = errors.New("unknown storage provider")
+	ErrMissingEndpoint           = errors.New("endpoint is required for the storage provider")
+	ErrInvalidWriter             = errors.New("invalid writer")
+	ErrObjectAlreadyExists       = errors.New("object already exists")
+	ErrInvalidConflictStrategy   = errors.New("invalid conflict strategy")
+	ErrPreconditionFailed        = errors.New("object was changed: its ETag doesn't match the expected one")
+	ErrEmptyETag                 = errors.New("expected ETag is empty")
+	ErrSizeMismatch              = errors.New("stream size doesn't match the declared size")
+	ErrPartEmpty                 = errors.New("part is empty")
+	ErrInvalidChecksumAlgorithm  = errors.New("invalid checksum algorithm")
+	ErrInvalidRedirectLocation   = errors.New("website redirect location must be an absolute http(s) URL or a path starting with /")
+	ErrEncryptionUnsupported     = errors.New("client-side encryption isn't supported by multipart uploads")
+	ErrChecksumUnsupported       = errors.New("additional checksums aren't supported by multipart uploads")
+	ErrInvalidACL                = errors.New("invalid ACL")
+	ErrTaggingConflict           = errors.New("tags can't be set along with the TaggingCopy directive")
 )
+
+// isNotFound reports whether the error returned by S3 means the object does not exist.
+func isNotFound(err error) bool {
+	var awsErr awserr.Error
+	if errors.As(err, &awsErr) {
+		switch awsErr.Code() {
+		case s3.ErrCodeNoSuchKey, "NotFound":
+			return true
+		case s3.ErrCodeNoSuchBucket:
+			return false
+		}
+	}
+
+	var reqErr awserr.RequestFailure
+	return errors.As(err, &reqErr) && reqErr.StatusCode() == http.StatusNotFound
+}
+
+// InvalidPartError is returned by CompleteMultipartUpload when a part is missed in the storage
+// or its ETag doesn't match, e.g. the part was re-uploaded.
+// PartNumber is 0 if the offending part can't be determined.
+// It matches ErrInvalidPart with errors.Is.
+type InvalidPartError struct {
+	PartNumber int64
+	Err        error
+}
+
+// Error returns the error message.
+func (e *InvalidPartError) Error() string {
+	if e.PartNumber == 0 {
+		return ErrInvalidPart.Error()
+	}
+	return fmt.Sprintf("%s: part number %d", ErrInvalidPart, e.PartNumber)
+}
+
+// Is reports whether the target is ErrInvalidPart.
+func (e *InvalidPartError) Is(target error) bool {
+	return target == ErrInvalidPart
+}
+
+// Unwrap returns the original storage error.
+func (e *InvalidPartError) Unwrap() error {
+	return e.Err
+}
+
+// isNotModified reports whether the error returned by S3 is the 304 Not Modified response
+// to a conditional request.
+func isNotModified(err error) bool {
+	var reqErr awserr.RequestFailure
+	return errors.As(err, &reqErr) && reqErr.StatusCode() == http.StatusNotModified
+}
+
+// hasCode reports whether the error returned by S3 has one of the given codes.
+func hasCode(err error, codes ...string) bool {
+	var awsErr awserr.Error
+	if !errors.As(err, &awsErr) {
+		return false
+	}
+	for _, code := range codes {
+		if awsErr.Code() == code {
+			return true
+		}
+	}
+	return false
+}
+
+// isNotImplemented reports whether the provider rejected the request as not implemented.
+func isNotImplemented(err error) bool {
+	if hasCode(err, "NotImplemented", "XNotImplemented", "MethodNotAllowed") {
+		return true
+	}
+
+	var reqErr awserr.RequestFailure
+	return errors.As(err, &reqErr) &&
+		(reqErr.StatusCode() == http.StatusNotImplemented || reqErr.StatusCode() == http.StatusMethodNotAllowed)
+}
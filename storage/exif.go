@@ -0,0 +1,73 @@
+package storage
+
+import (
+	"bytes"
+	"encoding/binary"
+
+	"github.com/pkg/errors"
+)
+
+// JPEG markers.
+const (
+	jpegMarkerPrefix = 0xFF
+	jpegSOI          = 0xD8 // start of image
+	jpegSOS          = 0xDA // start of scan, followed by the entropy-coded image data
+	jpegAPP1         = 0xE1 // EXIF and XMP metadata
+)
+
+// StripEXIF returns the JPEG image without the APP1 segments,
+// which hold the EXIF and XMP metadata, e.g. the GPS location and the camera details.
+// The image data isn't re-encoded, so the image quality is preserved.
+// Returns ErrInvalidJPEG if data isn't a well-formed JPEG.
+func StripEXIF(data []byte) ([]byte, error) {
+	if len(data) < 2 || data[0] != jpegMarkerPrefix || data[1] != jpegSOI {
+		return nil, errors.Wrap(ErrInvalidJPEG, "storage.StripEXIF")
+	}
+
+	out := bytes.NewBuffer(make([]byte, 0, len(data)))
+	out.Write(data[:2])
+
+	pos := 2
+	for {
+		// Markers may be preceded by any number of fill bytes.
+		for pos < len(data) && data[pos] == jpegMarkerPrefix && pos+1 < len(data) && data[pos+1] == jpegMarkerPrefix {
+			pos++
+		}
+		if pos+4 > len(data) || data[pos] != jpegMarkerPrefix {
+			return nil, errors.Wrap(ErrInvalidJPEG, "storage.StripEXIF")
+		}
+
+		marker := data[pos+1]
+		end := pos + 2 + int(binary.BigEndian.Uint16(data[pos+2:pos+4]))
+		if end > len(data) {
+			return nil, errors.Wrap(ErrInvalidJPEG, "storage.StripEXIF")
+		}
+
+		if marker == jpegSOS {
+			// The rest is the image data, it has no metadata segments.
+			out.Write(data[pos:])
+			return out.Bytes(), nil
+		}
+		if marker != jpegAPP1 {
+			out.Write(data[pos:end])
+		}
+		pos = end
+	}
+}
+
+// stripEXIF strips the metadata from the file if it's a JPEG image,
+// other files are returned untouched.
+func stripEXIF(file []byte) ([]byte, error) {
+	if len(file) == 0 {
+		return file, nil
+	}
+	contentType, err := GetFileContentTypeByBytes(file)
+	if err != nil {
+		return nil, err
+	}
+	if contentType != "image/jpeg" {
+		return file, nil
+	}
+
+	return StripEXIF(file)
+}
@@ -0,0 +1,66 @@
+package storage_test
+
+import (
+	"bytes"
+	"image"
+	"image/jpeg"
+	"io"
+	"net/http"
+	"testing"
+
+	"github.com/dmitrymomot/gofs/storage"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestStripEXIF(t *testing.T) {
+	var buf bytes.Buffer
+	require.NoError(t, jpeg.Encode(&buf, image.NewGray(image.Rect(0, 0, 8, 8)), nil))
+	plain := buf.Bytes()
+
+	// Insert an APP1 segment right after the SOI marker.
+	exif := append([]byte("Exif\x00\x00"), []byte("GPS 52.5200N 13.4050E")...)
+	segment := append([]byte{0xFF, 0xE1, 0, byte(len(exif) + 2)}, exif...)
+	withEXIF := append(append(append([]byte{}, plain[:2]...), segment...), plain[2:]...)
+
+	stripped, err := storage.StripEXIF(withEXIF)
+	require.NoError(t, err)
+	assert.Equal(t, plain, stripped)
+	assert.NotContains(t, string(stripped), "GPS")
+
+	_, err = jpeg.Decode(bytes.NewReader(stripped))
+	assert.NoError(t, err)
+
+	_, err = storage.StripEXIF([]byte("not an image"))
+	assert.ErrorIs(t, err, storage.ErrInvalidJPEG)
+
+	_, err = storage.StripEXIF(withEXIF[:10])
+	assert.ErrorIs(t, err, storage.ErrInvalidJPEG)
+}
+
+func TestUploadStripEXIFContentMD5(t *testing.T) {
+	var buf bytes.Buffer
+	require.NoError(t, jpeg.Encode(&buf, image.NewGray(image.Rect(0, 0, 8, 8)), nil))
+	plain := buf.Bytes()
+	exif := []byte("Exif\x00\x00GPS")
+	withEXIF := append(append([]byte{0xFF, 0xD8, 0xFF, 0xE1, 0, byte(len(exif) + 2)}, exif...), plain[2:]...)
+
+	var requests int
+	s := newLocalInteractor(t, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		requests++
+		assert.Equal(t, plain, body)
+		assert.Equal(t, md5Base64(plain), r.Header.Get("Content-MD5"))
+		w.Header().Set("ETag", `"etag"`)
+	}))
+
+	opts := storage.UploadOptions{ContentType: "image/jpeg", StripEXIF: true, ContentMD5: md5Base64(plain)}
+	_, err := s.UploadWithResult(withEXIF, "photo.jpg", opts)
+	assert.ErrorIs(t, err, storage.ErrChecksumMismatch, "the hash is checked against the original content")
+	assert.Zero(t, requests)
+
+	opts.ContentMD5 = md5Base64(withEXIF)
+	_, err = s.UploadWithResult(withEXIF, "photo.jpg", opts)
+	require.NoError(t, err)
+	assert.Equal(t, 1, requests)
+}
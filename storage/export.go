@@ -0,0 +1,127 @@
+package storage
+
+import (
+	"archive/tar"
+	"archive/zip"
+	"bytes"
+	"io"
+	"path"
+	"strings"
+	"time"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/s3"
+	"github.com/pkg/errors"
+)
+
+// Supported archive formats of ExportPrefix.
+const (
+	ArchiveTar ArchiveFormat = "tar"
+	ArchiveZip ArchiveFormat = "zip"
+)
+
+type (
+	// ArchiveFormat is the format of the exported archive.
+	ArchiveFormat string
+
+	// archiveWriter writes the entries of an archive.
+	archiveWriter interface {
+		add(name string, size int64, modTime time.Time, r io.Reader) error
+		Close() error
+	}
+
+	tarArchive struct{ *tar.Writer }
+	zipArchive struct{ *zip.Writer }
+)
+
+// ExportPrefix writes all the objects with the given prefix into an archive of the given format.
+// The entries are named by the object keys relative to the prefix.
+// The objects are downloaded and written one by one, so the archive is streamed to w
+// without buffering it in memory.
+// Returns ErrUnsupportedArchiveFormat if the format is unknown.
+func (i *Interactor) ExportPrefix(prefix string, w io.Writer, format ArchiveFormat) error {
+	var aw archiveWriter
+	switch format {
+	case ArchiveTar:
+		aw = tarArchive{tar.NewWriter(w)}
+	case ArchiveZip:
+		aw = zipArchive{zip.NewWriter(w)}
+	default:
+		return errors.Wrap(ErrUnsupportedArchiveFormat, "storage.exportPrefix")
+	}
+
+	if err := i.walk(prefix, func(objects []*s3.Object) error {
+		for _, obj := range objects {
+			key := aws.StringValue(obj.Key)
+			if strings.HasSuffix(key, "/") {
+				// Folder placeholder, it has no content.
+				continue
+			}
+			if err := i.exportObject(aw, prefix, key, aws.Int64Value(obj.Size), aws.TimeValue(obj.LastModified)); err != nil {
+				return err
+			}
+		}
+		return nil
+	}); err != nil {
+		return errors.Wrap(err, "storage.exportPrefix")
+	}
+
+	return errors.Wrap(aw.Close(), "storage.exportPrefix")
+}
+
+// exportObject downloads the object and writes it into the archive.
+func (i *Interactor) exportObject(aw archiveWriter, prefix, key string, size int64, modTime time.Time) error {
+	body, _, err := i.Download(key)
+	if err != nil {
+		return err
+	}
+	defer body.Close()
+
+	var r io.Reader = body
+	if i.keys != nil {
+		// The stored size is of the encrypted content, the decrypted one is known after reading it.
+		data, err := io.ReadAll(body)
+		if err != nil {
+			return err
+		}
+		r, size = bytes.NewReader(data), int64(len(data))
+	}
+
+	name := strings.TrimPrefix(strings.TrimPrefix(key, prefix), "/")
+	if name == "" {
+		name = path.Base(key)
+	}
+
+	return aw.add(name, size, modTime, r)
+}
+
+// add writes the tar entry.
+func (a tarArchive) add(name string, size int64, modTime time.Time, r io.Reader) error {
+	if err := a.WriteHeader(&tar.Header{
+		Typeflag: tar.TypeReg,
+		Name:     name,
+		Size:     size,
+		Mode:     0o644,
+		ModTime:  modTime,
+	}); err != nil {
+		return err
+	}
+
+	_, err := io.Copy(a, r)
+	return err
+}
+
+// add writes the zip entry.
+func (a zipArchive) add(name string, _ int64, modTime time.Time, r io.Reader) error {
+	f, err := a.CreateHeader(&zip.FileHeader{
+		Name:     name,
+		Method:   zip.Deflate,
+		Modified: modTime,
+	})
+	if err != nil {
+		return err
+	}
+
+	_, err = io.Copy(f, r)
+	return err
+}
@@ -0,0 +1,4 @@
+package storage
+
+// CopyPartSizes returns the part sizes of the multipart copy.
+var CopyPartSizes = copyPartSizes
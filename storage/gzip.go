@@ -0,0 +1,68 @@
+package storage
+
+import (
+	"compress/gzip"
+	"io"
+	"strings"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/s3"
+	"github.com/pkg/errors"
+)
+
+// gzipBody is the decompressed download body, Close closes both the gzip reader and the body.
+type gzipBody struct {
+	*gzip.Reader
+	body io.ReadCloser
+}
+
+// Close closes the gzip reader and the underlying body.
+func (b *gzipBody) Close() error {
+	zerr := b.Reader.Close()
+	if err := b.body.Close(); err != nil {
+		return err
+	}
+	return zerr
+}
+
+// DownloadDecompressed downloads the file like Download,
+// decompressing it transparently if it's stored with the Content-Encoding: gzip header,
+// e.g. uploaded with UploadGzipped or by other tools. Other files are returned as is.
+// Returns ErrObjectNotFound if there is no object with the given path.
+func (i *Interactor) DownloadDecompressed(filepath string) (io.ReadCloser, *string, error) {
+	input := &s3.GetObjectInput{
+		Bucket:       aws.String(i.bucket),
+		RequestPayer: i.requestPayer(),
+		Key:          aws.String(trimKey(filepath)),
+	}
+	if err := input.Validate(); err != nil {
+		return nil, nil, errors.Wrap(err, "storage.downloadDecompressed")
+	}
+
+	result, err := i.s3.GetObject(input)
+	if err != nil {
+		if isNotFound(err) {
+			return nil, nil, ErrObjectNotFound
+		}
+		i.logError("storage: download %s: %v", filepath, err)
+		return nil, nil, errors.Wrap(err, "storage.downloadDecompressed")
+	}
+
+	body, err := i.decrypt(result)
+	if err != nil {
+		return nil, nil, errors.Wrap(err, "storage.downloadDecompressed")
+	}
+
+	// The HTTP transport may have decompressed the body already, dropping the header.
+	if !strings.EqualFold(aws.StringValue(result.ContentEncoding), "gzip") {
+		return body, result.ContentType, nil
+	}
+
+	zr, err := gzip.NewReader(body)
+	if err != nil {
+		body.Close()
+		return nil, nil, errors.Wrap(err, "storage.downloadDecompressed")
+	}
+
+	return &gzipBody{Reader: zr, body: body}, result.ContentType, nil
+}
@@ -0,0 +1,177 @@
+package storage
+
+import (
+	"io"
+	"net/http"
+	"path"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/s3"
+)
+
+// objectHeaders are the response headers of the served object.
+type objectHeaders struct {
+	contentType     *string
+	contentEncoding *string
+	contentLength   *int64
+	contentRange    *string
+	cacheControl    *string
+	etag            *string
+	lastModified    *time.Time
+}
+
+// Handler returns the http.Handler serving the objects with the given prefix:
+// the request path "/dir/file.txt" is served from the object "<prefix>dir/file.txt".
+// It's a drop-in file server backed by the storage, e.g. to proxy private objects through the application.
+// Only GET and HEAD requests are allowed. The Range and If-None-Match headers are passed to the storage,
+// so partial content and not modified responses are served without downloading the whole object.
+// Missed objects and paths with ".." segments or a trailing slash are responded with 404 Not Found.
+// The client-side encrypted objects are decrypted and always served whole.
+// Mount it with http.StripPrefix to serve it under a path prefix.
+func (i *Interactor) Handler(prefix string) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodGet && r.Method != http.MethodHead {
+			w.Header().Set("Allow", "GET, HEAD")
+			http.Error(w, http.StatusText(http.StatusMethodNotAllowed), http.StatusMethodNotAllowed)
+			return
+		}
+
+		name := strings.TrimPrefix(r.URL.Path, "/")
+		if name == "" || strings.HasSuffix(name, "/") || path.Clean("/"+name) != "/"+name {
+			http.NotFound(w, r)
+			return
+		}
+		key := prefix + name
+
+		var rangeHeader, ifNoneMatch *string
+		if v := r.Header.Get("Range"); v != "" && i.keys == nil {
+			rangeHeader = aws.String(v)
+		}
+		if v := r.Header.Get("If-None-Match"); v != "" {
+			ifNoneMatch = aws.String(v)
+		}
+
+		if r.Method == http.MethodHead {
+			result, err := i.s3.HeadObjectWithContext(r.Context(), &s3.HeadObjectInput{
+				Bucket:       aws.String(i.bucket),
+				RequestPayer: i.requestPayer(),
+				Key:          aws.String(trimKey(key)),
+				IfNoneMatch:  ifNoneMatch,
+			})
+			if err != nil {
+				i.serveError(w, r, key, err)
+				return
+			}
+			headers := objectHeaders{
+				contentType:     result.ContentType,
+				contentEncoding: result.ContentEncoding,
+				cacheControl:    result.CacheControl,
+				etag:            result.ETag,
+				lastModified:    result.LastModified,
+			}
+			if i.keys == nil {
+				// The length of the stored ciphertext isn't the one GET responds with.
+				headers.contentLength = result.ContentLength
+			}
+			// The range is ignored: the response has no content to be partial.
+			i.writeObjectHeaders(w, false, headers)
+			return
+		}
+
+		result, err := i.s3.GetObjectWithContext(r.Context(), &s3.GetObjectInput{
+			Bucket:       aws.String(i.bucket),
+			RequestPayer: i.requestPayer(),
+			Key:          aws.String(trimKey(key)),
+			Range:        rangeHeader,
+			IfNoneMatch:  ifNoneMatch,
+		})
+		if err != nil {
+			i.serveError(w, r, key, err)
+			return
+		}
+
+		body, err := i.decrypt(result)
+		if err != nil {
+			i.logError("storage: serve %s: %v", key, err)
+			http.Error(w, http.StatusText(http.StatusBadGateway), http.StatusBadGateway)
+			return
+		}
+		defer body.Close()
+
+		headers := objectHeaders{
+			contentType:     result.ContentType,
+			contentEncoding: result.ContentEncoding,
+			contentRange:    result.ContentRange,
+			cacheControl:    result.CacheControl,
+			etag:            result.ETag,
+			lastModified:    result.LastModified,
+		}
+		if i.keys == nil {
+			// The length of the decrypted content isn't known in advance.
+			headers.contentLength = result.ContentLength
+		}
+		i.writeObjectHeaders(w, result.ContentRange != nil, headers)
+
+		if _, err := io.Copy(w, body); err != nil {
+			// The response is already started, the client gets the truncated body.
+			i.logError("storage: serve %s: %v", key, err)
+		}
+	})
+}
+
+// writeObjectHeaders writes the response headers and the status code:
+// 206 Partial Content for the range requests and 200 OK otherwise.
+func (i *Interactor) writeObjectHeaders(w http.ResponseWriter, partial bool, headers objectHeaders) {
+	h := w.Header()
+	if headers.contentType != nil {
+		h.Set("Content-Type", *headers.contentType)
+	}
+	if headers.contentEncoding != nil {
+		h.Set("Content-Encoding", *headers.contentEncoding)
+	}
+	if headers.contentLength != nil {
+		h.Set("Content-Length", strconv.FormatInt(*headers.contentLength, 10))
+	}
+	if headers.contentRange != nil {
+		h.Set("Content-Range", *headers.contentRange)
+	}
+	if headers.cacheControl != nil {
+		h.Set("Cache-Control", *headers.cacheControl)
+	}
+	if headers.etag != nil {
+		h.Set("ETag", quoteETag(*headers.etag))
+	}
+	if headers.lastModified != nil {
+		h.Set("Last-Modified", headers.lastModified.UTC().Format(http.TimeFormat))
+	}
+	if i.keys == nil {
+		h.Set("Accept-Ranges", "bytes")
+	}
+
+	if partial {
+		w.WriteHeader(http.StatusPartialContent)
+		return
+	}
+	w.WriteHeader(http.StatusOK)
+}
+
+// serveError responds with the status matching the storage error.
+func (i *Interactor) serveError(w http.ResponseWriter, r *http.Request, key string, err error) {
+	switch {
+	case isNotModified(err):
+		if etag := r.Header.Get("If-None-Match"); etag != "" {
+			w.Header().Set("ETag", etag)
+		}
+		w.WriteHeader(http.StatusNotModified)
+	case isNotFound(err):
+		http.NotFound(w, r)
+	case hasCode(err, "InvalidRange"):
+		http.Error(w, http.StatusText(http.StatusRequestedRangeNotSatisfiable), http.StatusRequestedRangeNotSatisfiable)
+	default:
+		i.logError("storage: serve %s: %v", key, err)
+		http.Error(w, http.StatusText(http.StatusBadGateway), http.StatusBadGateway)
+	}
+}
@@ -0,0 +1,124 @@
+package storage_test
+
+import (
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/dmitrymomot/gofs/storage"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestHandler(t *testing.T) {
+	const content = "Hello, World!"
+	handler := newLocalInteractor(t, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/bucket/public/docs/hello.txt" {
+			w.WriteHeader(http.StatusNotFound)
+			fmt.Fprint(w, `<Error><Code>NoSuchKey</Code></Error>`)
+			return
+		}
+		if r.Header.Get("If-None-Match") == `"etag"` {
+			w.WriteHeader(http.StatusNotModified)
+			return
+		}
+
+		w.Header().Set("Content-Type", "text/plain")
+		w.Header().Set("ETag", `"etag"`)
+		if r.Header.Get("Range") == "bytes=0-4" {
+			w.Header().Set("Content-Range", fmt.Sprintf("bytes 0-4/%d", len(content)))
+			w.Header().Set("Content-Length", "5")
+			w.WriteHeader(http.StatusPartialContent)
+			fmt.Fprint(w, content[:5])
+			return
+		}
+		w.Header().Set("Content-Length", fmt.Sprint(len(content)))
+		if r.Method != http.MethodHead {
+			fmt.Fprint(w, content)
+		}
+	})).Handler("public/")
+
+	serve := func(method, path string, header http.Header) *httptest.ResponseRecorder {
+		r := httptest.NewRequest(method, path, nil)
+		for k, v := range header {
+			r.Header[k] = v
+		}
+		w := httptest.NewRecorder()
+		handler.ServeHTTP(w, r)
+		return w
+	}
+
+	w := serve(http.MethodGet, "/docs/hello.txt", nil)
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Equal(t, content, w.Body.String())
+	assert.Equal(t, "text/plain", w.Header().Get("Content-Type"))
+	assert.Equal(t, "13", w.Header().Get("Content-Length"))
+	assert.Equal(t, `"etag"`, w.Header().Get("ETag"))
+
+	w = serve(http.MethodHead, "/docs/hello.txt", nil)
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Empty(t, w.Body.String())
+	assert.Equal(t, "13", w.Header().Get("Content-Length"))
+
+	w = serve(http.MethodGet, "/docs/hello.txt", http.Header{"Range": {"bytes=0-4"}})
+	assert.Equal(t, http.StatusPartialContent, w.Code)
+	assert.Equal(t, "Hello", w.Body.String())
+	assert.Equal(t, "bytes 0-4/13", w.Header().Get("Content-Range"))
+
+	w = serve(http.MethodGet, "/docs/hello.txt", http.Header{"If-None-Match": {`"etag"`}})
+	assert.Equal(t, http.StatusNotModified, w.Code)
+	assert.Empty(t, w.Body.String())
+
+	assert.Equal(t, http.StatusNotFound, serve(http.MethodGet, "/docs/missed.txt", nil).Code)
+	assert.Equal(t, http.StatusNotFound, serve(http.MethodGet, "/docs/../hello.txt", nil).Code)
+	assert.Equal(t, http.StatusNotFound, serve(http.MethodGet, "/docs/", nil).Code)
+	assert.Equal(t, http.StatusMethodNotAllowed, serve(http.MethodPost, "/docs/hello.txt", nil).Code)
+}
+
+func TestHandlerClientEncryption(t *testing.T) {
+	var (
+		stored   []byte
+		metadata = http.Header{}
+	)
+	s := newLocalInteractor(t, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method == http.MethodPut {
+			stored, _ = io.ReadAll(r.Body)
+			for name, values := range r.Header {
+				if strings.HasPrefix(strings.ToLower(name), "x-amz-meta-") {
+					metadata[name] = values
+				}
+			}
+			w.Header().Set("ETag", `"etag"`)
+			return
+		}
+
+		for name, values := range metadata {
+			w.Header()[name] = values
+		}
+		w.Header().Set("Content-Length", fmt.Sprint(len(stored)))
+		if r.Method != http.MethodHead {
+			_, _ = w.Write(stored)
+		}
+	}), storage.WithClientEncryption(storage.StaticKey([]byte("0123456789abcdef0123456789abcdef"))))
+	require.NoError(t, s.Upload([]byte("Hello, World!"), "hello.txt", storage.Private, "text/plain"))
+	handler := s.Handler("")
+
+	serve := func(method string) *httptest.ResponseRecorder {
+		w := httptest.NewRecorder()
+		handler.ServeHTTP(w, httptest.NewRequest(method, "/hello.txt", nil))
+		return w
+	}
+
+	// The length of the stored ciphertext differs from the served content.
+	w := serve(http.MethodHead)
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Empty(t, w.Header().Get("Content-Length"))
+
+	w = serve(http.MethodGet)
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Equal(t, "Hello, World!", w.Body.String())
+	assert.Empty(t, w.Header().Get("Content-Length"))
+}
@@ -2,13 +2,22 @@ package storage
 
 import (
 	"bytes"
+	"compress/gzip"
+	"context"
 	"fmt"
+	"hash/fnv"
 	"io"
+	"net/http"
 	"sort"
+	"strings"
+	"sync"
+	"time"
 
 	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/request"
 	"github.com/aws/aws-sdk-go/service/s3"
 	"github.com/pkg/errors"
+	"golang.org/x/sync/singleflight"
 )
 
 type (
@@ -17,7 +26,29 @@ type (
 		s3             *s3.S3
 		bucket         string
 		fileEndpoint   string
+		fileEndpoints  []string // sharded endpoints, see WithFileEndpoints
 		forcePathStyle bool
+
+		multipartThreshold int64
+		maxParts           int64
+		minPartSize        int64
+		maxPartSize        int64
+		logger             Logger
+		stats              stats
+		keys               KeyProvider
+		dryRun             bool
+		contentIndex       ContentIndex
+		requesterPays      bool
+		keyStrategy        KeyStrategy
+		keyGenerator       KeyGenerator
+		validateKeys       bool
+		defaultTimeout     time.Duration
+		defaultACL         ACL
+		defaultACLErr      error // the invalid ACL passed to WithDefaultACL
+		dedup              singleflight.Group
+
+		capsMu sync.Mutex
+		caps   *ProviderCapabilities
 	}
 
 	// CompletedPart represents a part of a multipart upload.
@@ -30,6 +61,37 @@ type (
 		partNumber int64
 		etag       string
 	}
+
+	// UploadResult describes the uploaded object.
+	// VersionID is empty if the bucket versioning is disabled.
+	// Location is the url of the object returned by the storage,
+	// it's set for the completed multipart uploads only.
+	// Checksum is the base64-encoded additional checksum of UploadOptions.ChecksumAlgorithm
+	// confirmed by the storage, it's empty if the storage doesn't support it.
+	UploadResult struct {
+		Key       string
+		ETag      string
+		VersionID string
+		Location  string
+		Checksum  string
+	}
+
+	// ObjectInfo describes a stored object.
+	// ETag is returned as is, including the surrounding quotes.
+	// Metadata is the user-defined object metadata with lower-case keys.
+	// StorageClass is empty for the STANDARD class.
+	// Expires is zero if the object has no valid Expires header.
+	ObjectInfo struct {
+		Key             string
+		ContentType     string
+		ContentEncoding string
+		ContentLength   int64
+		ETag            string
+		LastModified    time.Time
+		StorageClass    string
+		Expires         time.Time
+		Metadata        map[string]string
+	}
 )
 
 // PartNumber returns the part number.
@@ -44,98 +106,562 @@ func (p *completedPart) ETag() string {
 
 // New is a factory function,
 // returns a new instance of the storage interactor
-func New(s3Client *s3.S3, bucket, fileEndpoint string) *Interactor {
-	return &Interactor{
-		s3:             s3Client,
-		bucket:         bucket,
-		fileEndpoint:   fileEndpoint,
-		forcePathStyle: *s3Client.Config.S3ForcePathStyle,
+func New(s3Client *s3.S3, bucket, fileEndpoint string, opts ...Option) *Interactor {
+	i := &Interactor{
+		s3:                 withRegionErrors(s3Client),
+		bucket:             bucket,
+		fileEndpoint:       strings.TrimRight(fileEndpoint, "/"),
+		forcePathStyle:     *s3Client.Config.S3ForcePathStyle,
+		multipartThreshold: DefaultMultipartThreshold,
+		maxParts:           MaxParts,
+		minPartSize:        MinPartSize,
+		maxPartSize:        MaxPartSize,
+		logger:             noopLogger{},
+		keyGenerator:       UUIDGenerator,
+		defaultACL:         Private,
+	}
+
+	for _, opt := range opts {
+		opt(i)
+	}
+	if i.keyStrategy == nil {
+		i.keyStrategy = UniqueKeyWith(i.keyGenerator)
+	}
+	if i.defaultTimeout > 0 {
+		i.s3.Handlers.Validate.PushFrontNamed(timeoutHandler(i.defaultTimeout))
+	}
+
+	return i
+}
+
+// requestPayer returns the RequestPayer parameter of the bucket requests, see WithRequesterPays.
+func (i *Interactor) requestPayer() *string {
+	if !i.requesterPays {
+		return nil
+	}
+
+	return aws.String(s3.RequestPayerRequester)
+}
+
+// requestPayerHeader returns the request option sending the requester-pays header
+// with the requests whose input doesn't model it, e.g. SelectObjectContent.
+func (i *Interactor) requestPayerHeader() []request.Option {
+	if !i.requesterPays {
+		return nil
 	}
+
+	return headerOptions(map[string]string{"X-Amz-Request-Payer": s3.RequestPayerRequester})
 }
 
-// Upload file to the cloud storage
+// Close releases the resources of the interactor.
+// The S3 client is passed to New by the caller and is not owned by the interactor,
+// so there is nothing to release now; call Close on shutdown to stay forward compatible.
+func (i *Interactor) Close() error {
+	return nil
+}
+
+// Bucket returns the name of the bucket the interactor works with.
+func (i *Interactor) Bucket() string {
+	return i.bucket
+}
+
+// Endpoint returns the public file endpoint used to build file urls.
+func (i *Interactor) Endpoint() string {
+	return i.fileEndpoint
+}
+
+// ForcePathStyle reports whether the bucket name is a part of the file url path.
+func (i *Interactor) ForcePathStyle() bool {
+	return i.forcePathStyle
+}
+
+// DryRun reports whether the destructive operations run in the dry-run mode, see WithDryRun.
+func (i *Interactor) DryRun() bool {
+	return i.dryRun
+}
+
+// Upload file to the cloud storage.
+// Returns ErrFileEmpty if the file is empty,
+// use UploadWithOptions with AllowEmpty to upload empty files.
 func (i *Interactor) Upload(file []byte, filepath string, acl ACL, contentType string) error {
-	input := s3.PutObjectInput{
-		Bucket:      aws.String(i.bucket),
-		Key:         aws.String(filepath),
-		Body:        bytes.NewReader(file),
-		ACL:         aws.String(acl.String()),
-		ContentType: aws.String(contentType),
+	return i.UploadWithOptions(file, filepath, UploadOptions{
+		ACL:         acl,
+		ContentType: contentType,
+	})
+}
+
+// UploadWithOptions uploads file to the cloud storage with the given object options.
+func (i *Interactor) UploadWithOptions(file []byte, filepath string, opts UploadOptions) error {
+	_, err := i.UploadWithResult(file, filepath, opts)
+	return err
+}
+
+// UploadWithResult uploads file to the cloud storage with the given object options
+// and returns the ETag and the version ID of the stored object.
+func (i *Interactor) UploadWithResult(file []byte, filepath string, opts UploadOptions) (*UploadResult, error) {
+	return i.UploadWithContext(context.Background(), file, filepath, opts)
+}
+
+// UploadWithContext is UploadWithResult which cancels the request when ctx is done.
+func (i *Interactor) UploadWithContext(ctx context.Context, file []byte, filepath string, opts UploadOptions) (*UploadResult, error) {
+	return i.upload(ctx, file, filepath, opts)
+}
+
+// upload uploads the file applying the request options to the PUT request.
+func (i *Interactor) upload(ctx context.Context, file []byte, filepath string, opts UploadOptions, reqOpts ...request.Option) (*UploadResult, error) {
+	if len(file) == 0 && !opts.AllowEmpty {
+		return nil, errors.Wrap(ErrFileEmpty, "storage.upload")
+	}
+	if err := i.validateKey(filepath); err != nil {
+		return nil, errors.Wrap(err, "storage.upload")
+	}
+	if err := opts.validate(); err != nil {
+		return nil, errors.Wrap(err, "storage.upload")
+	}
+	filepath = trimKey(filepath)
+
+	filepath, err := i.resolveKey(ctx, filepath, opts.OnConflict)
+	if err != nil {
+		return nil, errors.Wrap(err, "storage.upload")
+	}
+
+	if opts.StripEXIF {
+		// The precomputed hash is of the original content, the storage gets the stripped one
+		// and can't check it, so it's checked here.
+		if opts.ContentMD5 != "" && contentMD5(file) != opts.ContentMD5 {
+			return nil, errors.Wrap(ErrChecksumMismatch, "storage.upload")
+		}
+		stripped, err := stripEXIF(file)
+		if err != nil {
+			return nil, errors.Wrap(err, "storage.upload: strip exif")
+		}
+		file = stripped
+		if opts.ContentMD5 != "" {
+			opts.ContentMD5 = contentMD5(file)
+		}
+	}
+
+	if i.keys != nil {
+		// The precomputed hash is of the plaintext, the storage gets the ciphertext
+		// and can't check it, so it's checked here.
+		if opts.ContentMD5 != "" && contentMD5(file) != opts.ContentMD5 {
+			return nil, errors.Wrap(ErrChecksumMismatch, "storage.upload")
+		}
+		ciphertext, meta, err := encrypt(i.keys, file)
+		if err != nil {
+			return nil, errors.Wrap(err, "storage.upload: encrypt")
+		}
+		file = ciphertext
+		opts.Metadata = mergeMetadata(opts.Metadata, meta)
+		if opts.ContentMD5 != "" {
+			opts.ContentMD5 = contentMD5(file)
+		}
+	}
+
+	input := opts.putObjectInput(i.bucket, filepath, bytes.NewReader(file))
+	input.RequestPayer = i.requestPayer()
+	if opts.hasObjectLock() && input.ContentMD5 == nil {
+		// Content-MD5 is required for uploads with object lock settings.
+		input.ContentMD5 = aws.String(contentMD5(file))
+	}
+	var checksum string
+	if opts.ChecksumAlgorithm != "" {
+		checksum = opts.ChecksumAlgorithm.sum(file)
+		opts.ChecksumAlgorithm.setPut(input, checksum)
 	}
 	if err := input.Validate(); err != nil {
-		return errors.Wrap(err, "storage.upload")
+		return nil, errors.Wrap(err, "storage.upload")
 	}
+	// The headers of the upload itself, e.g. If-Match, are set by headerOptions as well,
+	// after opts.Headers, so they take precedence.
+	reqOpts = append(headerOptions(opts.Headers), reqOpts...)
 
-	if _, err := i.s3.PutObject(&input); err != nil {
-		return errors.Wrap(err, "storage.upload")
+	result, err := i.s3.PutObjectWithContext(ctx, input, reqOpts...)
+	if err != nil && checksum != "" && isNotImplemented(err) {
+		i.logger.Debugf("storage: upload %s: additional checksums aren't supported, uploading without it", filepath)
+		checksum = ""
+		input.ChecksumAlgorithm, input.ChecksumCRC32, input.ChecksumCRC32C, input.ChecksumSHA1, input.ChecksumSHA256 = nil, nil, nil, nil, nil
+		input.Body = bytes.NewReader(file)
+		result, err = i.s3.PutObjectWithContext(ctx, input, reqOpts...)
+	}
+	if err != nil {
+		i.logError("storage: upload %s: %v", filepath, err)
+		switch {
+		case hasCode(err, "PreconditionFailed"):
+			return nil, ErrPreconditionFailed
+		case hasCode(err, "BadDigest", "XAmzContentChecksumMismatch"):
+			return nil, ErrChecksumMismatch
+		case hasCode(err, "InvalidDigest"):
+			return nil, ErrInvalidChecksum
+		}
+		return nil, errors.Wrap(err, "storage.upload")
+	}
+	i.stats.uploads.Add(1)
+	i.stats.bytesUploaded.Add(int64(len(file)))
+
+	if checksum != "" {
+		if stored := opts.ChecksumAlgorithm.fromPut(result); stored == "" {
+			// The provider ignored the checksum.
+			checksum = ""
+		} else if stored != checksum {
+			i.logError("storage: upload %s: stored checksum %s doesn't match %s", filepath, stored, checksum)
+			return nil, ErrChecksumMismatch
+		}
 	}
 
-	return nil
+	return &UploadResult{
+		Key:       filepath,
+		ETag:      aws.StringValue(result.ETag),
+		VersionID: aws.StringValue(result.VersionId),
+		Checksum:  checksum,
+	}, nil
+}
+
+// Put uploads file to the cloud storage and returns its public url.
+func (i *Interactor) Put(file []byte, filepath string, acl ACL, contentType string) (string, error) {
+	if err := i.Upload(file, filepath, acl, contentType); err != nil {
+		return "", err
+	}
+
+	return i.FileURL(filepath), nil
+}
+
+// UploadGzipped compresses file with gzip and uploads it with the Content-Encoding: gzip header,
+// so browsers decompress it transparently.
+// contentType is the type of the original (uncompressed) content.
+func (i *Interactor) UploadGzipped(file []byte, filepath string, acl ACL, contentType string) error {
+	if len(file) == 0 {
+		// The compressed empty file isn't empty.
+		return errors.Wrap(ErrFileEmpty, "storage.uploadGzipped")
+	}
+
+	var buf bytes.Buffer
+	zw := gzip.NewWriter(&buf)
+	if _, err := zw.Write(file); err != nil {
+		return errors.Wrap(err, "storage.uploadGzipped")
+	}
+	if err := zw.Close(); err != nil {
+		return errors.Wrap(err, "storage.uploadGzipped")
+	}
+
+	return i.UploadWithOptions(buf.Bytes(), filepath, UploadOptions{
+		ACL:             acl,
+		ContentType:     contentType,
+		ContentEncoding: "gzip",
+	})
 }
 
 // Download file from the cloud storage
 func (i *Interactor) Download(filepath string) (io.ReadCloser, *string, error) {
+	return i.DownloadVersion(filepath, "")
+}
+
+// DownloadVersion downloads the given version of the file from the cloud storage.
+// Empty versionID means the latest version.
+func (i *Interactor) DownloadVersion(filepath, versionID string) (io.ReadCloser, *string, error) {
+	return i.DownloadVersionWithContext(context.Background(), filepath, versionID)
+}
+
+// DownloadVersionWithContext is DownloadVersion which cancels the request when ctx is done.
+func (i *Interactor) DownloadVersionWithContext(ctx context.Context, filepath, versionID string) (io.ReadCloser, *string, error) {
+	return i.DownloadWithContext(ctx, filepath, DownloadOptions{VersionID: versionID})
+}
+
+// DownloadWithContext downloads the file from the cloud storage with the given options,
+// the request and the reading of the body are canceled when ctx is done.
+// If opts.MaxRetries is set, the body resumes reading from the last read offset
+// when the connection drops in the middle of the download.
+func (i *Interactor) DownloadWithContext(ctx context.Context, filepath string, opts DownloadOptions) (io.ReadCloser, *string, error) {
 	input := &s3.GetObjectInput{
-		Bucket: aws.String(i.bucket),
-		Key:    aws.String(filepath),
+		Bucket:       aws.String(i.bucket),
+		RequestPayer: i.requestPayer(),
+		Key:          aws.String(trimKey(filepath)),
+	}
+	if opts.VersionID != "" {
+		input.VersionId = aws.String(opts.VersionID)
 	}
 	if err := input.Validate(); err != nil {
 		return nil, nil, errors.Wrap(err, "storage.download")
 	}
 
-	result, err := i.s3.GetObject(input)
+	result, err := i.s3.GetObjectWithContext(ctx, input, headerOptions(opts.Headers)...)
 	if err != nil {
+		i.logError("storage: download %s: %v", filepath, err)
 		return nil, nil, errors.Wrap(err, "storage.download")
 	}
+	if opts.MaxRetries > 0 {
+		result.Body = i.resumableBody(ctx, input, result, opts)
+	}
 
-	return result.Body, result.ContentType, nil
+	body, err := i.decrypt(result)
+	if err != nil {
+		return nil, nil, errors.Wrap(err, "storage.download")
+	}
+
+	return body, result.ContentType, nil
+}
+
+// DownloadIfModified downloads the file only if it was changed:
+// its ETag differs from the given one or it was modified after the given time.
+// Empty etag and zero time are ignored.
+// If the file wasn't changed, returns notModified equal to true and nil body.
+func (i *Interactor) DownloadIfModified(filepath, etag string, since time.Time) (io.ReadCloser, *ObjectInfo, bool, error) {
+	input := &s3.GetObjectInput{
+		Bucket:       aws.String(i.bucket),
+		RequestPayer: i.requestPayer(),
+		Key:          aws.String(trimKey(filepath)),
+	}
+	if etag != "" {
+		input.IfNoneMatch = aws.String(etag)
+	}
+	if !since.IsZero() {
+		input.IfModifiedSince = aws.Time(since)
+	}
+	if err := input.Validate(); err != nil {
+		return nil, nil, false, errors.Wrap(err, "storage.downloadIfModified")
+	}
+
+	result, err := i.s3.GetObject(input)
+	if err != nil {
+		if isNotModified(err) {
+			return nil, nil, true, nil
+		}
+		if isNotFound(err) {
+			return nil, nil, false, ErrObjectNotFound
+		}
+		i.logError("storage: download %s: %v", filepath, err)
+		return nil, nil, false, errors.Wrap(err, "storage.downloadIfModified")
+	}
+
+	body, err := i.decrypt(result)
+	if err != nil {
+		return nil, nil, false, errors.Wrap(err, "storage.downloadIfModified")
+	}
+
+	return body, objectInfoFromGet(filepath, result), false, nil
+}
+
+// decrypt returns the counted download body, decrypted if the client-side encryption is enabled.
+func (i *Interactor) decrypt(result *s3.GetObjectOutput) (io.ReadCloser, error) {
+	body := i.countDownload(result.Body)
+	if i.keys == nil {
+		return body, nil
+	}
+
+	decrypted, err := decryptBody(i.keys, body, result.Metadata)
+	if err != nil {
+		body.Close()
+		return nil, err
+	}
+
+	return decrypted, nil
+}
+
+// objectInfoFromGet returns the object info from the download response.
+func objectInfoFromGet(filepath string, result *s3.GetObjectOutput) *ObjectInfo {
+	return &ObjectInfo{
+		Key:             filepath,
+		ContentType:     aws.StringValue(result.ContentType),
+		ContentEncoding: aws.StringValue(result.ContentEncoding),
+		ContentLength:   aws.Int64Value(result.ContentLength),
+		ETag:            aws.StringValue(result.ETag),
+		LastModified:    aws.TimeValue(result.LastModified),
+		StorageClass:    aws.StringValue(result.StorageClass),
+		Expires:         parseExpires(result.Expires),
+		Metadata:        userMetadata(result.Metadata),
+	}
+}
+
+// parseExpires parses the Expires header, returns zero time if it's missed or invalid,
+// e.g. "0" which means already expired.
+func parseExpires(expires *string) time.Time {
+	t, err := http.ParseTime(aws.StringValue(expires))
+	if err != nil {
+		return time.Time{}
+	}
+	return t
+}
+
+// userMetadata returns the user-defined metadata with lower-case keys,
+// since S3 returns the metadata keys in the canonical header format.
+func userMetadata(metadata map[string]*string) map[string]string {
+	result := make(map[string]string, len(metadata))
+	for k, v := range metadata {
+		result[strings.ToLower(k)] = aws.StringValue(v)
+	}
+
+	return result
+}
+
+// Stat returns the object info without downloading its content.
+// All the fields of the info are populated with a single HEAD request.
+// Returns ErrObjectNotFound if there is no object with the given path.
+func (i *Interactor) Stat(filepath string) (*ObjectInfo, error) {
+	return i.stat(context.Background(), filepath)
+}
+
+// stat is Stat which cancels the request when ctx is done.
+func (i *Interactor) stat(ctx context.Context, filepath string) (*ObjectInfo, error) {
+	input := &s3.HeadObjectInput{
+		Bucket:       aws.String(i.bucket),
+		RequestPayer: i.requestPayer(),
+		Key:          aws.String(trimKey(filepath)),
+	}
+	if err := input.Validate(); err != nil {
+		return nil, errors.Wrap(err, "storage.stat")
+	}
+
+	result, err := i.s3.HeadObjectWithContext(ctx, input)
+	if err != nil {
+		if isNotFound(err) {
+			return nil, ErrObjectNotFound
+		}
+		return nil, errors.Wrap(err, "storage.stat")
+	}
+
+	return &ObjectInfo{
+		Key:             filepath,
+		ContentType:     aws.StringValue(result.ContentType),
+		ContentEncoding: aws.StringValue(result.ContentEncoding),
+		ContentLength:   aws.Int64Value(result.ContentLength),
+		ETag:            aws.StringValue(result.ETag),
+		LastModified:    aws.TimeValue(result.LastModified),
+		StorageClass:    aws.StringValue(result.StorageClass),
+		Expires:         parseExpires(result.Expires),
+		Metadata:        userMetadata(result.Metadata),
+	}, nil
+}
+
+// CacheKey returns the ETag and the Last-Modified time of the file for HTTP caching,
+// e.g. to serve conditional requests. The ETag is always quoted, as required by the ETag header,
+// even if the storage returns it without quotes; the time is in UTC.
+// Returns ErrObjectNotFound if there is no object with the given path.
+func (i *Interactor) CacheKey(filepath string) (string, time.Time, error) {
+	info, err := i.Stat(filepath)
+	if err != nil {
+		return "", time.Time{}, err
+	}
+
+	return quoteETag(info.ETag), info.LastModified.UTC(), nil
+}
+
+// quoteETag returns the ETag enclosed in double quotes.
+func quoteETag(etag string) string {
+	return `"` + strings.Trim(etag, `"`) + `"`
 }
 
 // Delete file from the cloud storage
 func (i *Interactor) Delete(filepath string) error {
+	return i.DeleteVersion(filepath, "")
+}
+
+// DeleteVersion removes the given version of the file from the cloud storage.
+// Empty versionID means the latest version: for versioned buckets
+// it creates a delete marker instead of removing the data.
+func (i *Interactor) DeleteVersion(filepath, versionID string) error {
+	if i.dryRun {
+		i.logger.Debugf("storage: dry run: delete %s (version: %q)", filepath, versionID)
+		return nil
+	}
+
+	return i.deleteVersion(filepath, versionID)
+}
+
+// deleteVersion is DeleteVersion ignoring the dry-run mode,
+// it removes the temporary objects of the interactor's own operations.
+func (i *Interactor) deleteVersion(filepath, versionID string) error {
 	input := &s3.DeleteObjectInput{
-		Bucket: aws.String(i.bucket),
-		Key:    aws.String(filepath),
+		Bucket:       aws.String(i.bucket),
+		RequestPayer: i.requestPayer(),
+		Key:          aws.String(trimKey(filepath)),
+	}
+	if versionID != "" {
+		input.VersionId = aws.String(versionID)
 	}
 	if err := input.Validate(); err != nil {
 		return errors.Wrap(err, "storage.delete")
 	}
 
 	if _, err := i.s3.DeleteObject(input); err != nil {
+		i.logError("storage: delete %s: %v", filepath, err)
 		return errors.Wrap(err, "storage.delete")
 	}
 
 	return nil
 }
 
-// FileURL return public url for a file
+// FileURL return public url for a file.
+// The leading slashes of the path are trimmed, as well as the trailing slashes of the endpoint by New,
+// so the url never contains double slashes between them.
+// With several endpoints set by WithFileEndpoints, the endpoint is picked by the hash of the key.
 func (i *Interactor) FileURL(filepath string) string {
+	key := trimKey(filepath)
 	if i.forcePathStyle {
-		return fmt.Sprintf("%s/%s/%s", i.fileEndpoint, i.bucket, filepath)
+		return fmt.Sprintf("%s/%s/%s", i.endpointFor(key), i.bucket, key)
+	}
+
+	return fmt.Sprintf("%s/%s", i.endpointFor(key), key)
+}
+
+// endpointFor returns the file endpoint of the key.
+// The same key is always mapped to the same endpoint, so it's cached once.
+func (i *Interactor) endpointFor(key string) string {
+	if len(i.fileEndpoints) < 2 {
+		return i.fileEndpoint
 	}
 
-	return fmt.Sprintf("%s/%s", i.fileEndpoint, filepath)
+	h := fnv.New32a()
+	_, _ = h.Write([]byte(key))
+	return i.fileEndpoints[h.Sum32()%uint32(len(i.fileEndpoints))]
 }
 
 // Create multipart upload
 func (i *Interactor) CreateMultipartUpload(filename, contentType string, acl ACL) (string, error) {
-	input := &s3.CreateMultipartUploadInput{
-		ACL:         aws.String(acl.String()),
-		Bucket:      aws.String(i.bucket),
-		Key:         aws.String(filename),
-		ContentType: aws.String(contentType),
+	return i.CreateMultipartUploadWithOptions(filename, UploadOptions{
+		ACL:         acl,
+		ContentType: contentType,
+	})
+}
+
+// CreateMultipartUploadWithOptions creates multipart upload with the given object options.
+// The options are applied to the assembled object, parts don't need to carry them.
+func (i *Interactor) CreateMultipartUploadWithOptions(filename string, opts UploadOptions) (string, error) {
+	return i.CreateMultipartUploadWithContext(context.Background(), filename, opts)
+}
+
+// CreateMultipartUploadWithContext is CreateMultipartUploadWithOptions which cancels the request when ctx is done.
+func (i *Interactor) CreateMultipartUploadWithContext(ctx context.Context, filename string, opts UploadOptions) (string, error) {
+	if i.keys != nil {
+		// The parts can't be encrypted as a whole, the object would be stored in plaintext.
+		return "", errors.Wrap(ErrEncryptionUnsupported, "storage.createMultipartUpload")
 	}
+	if err := i.validateKey(filename); err != nil {
+		return "", errors.Wrap(err, "storage.createMultipartUpload")
+	}
+	filename = trimKey(filename)
+	if err := opts.validate(); err != nil {
+		return "", errors.Wrap(err, "storage.createMultipartUpload: invalid params")
+	}
+	if opts.ChecksumAlgorithm != "" {
+		// The parts would be uploaded without the checksum.
+		return "", errors.Wrap(ErrChecksumUnsupported, "storage.createMultipartUpload")
+	}
+
+	input := opts.createMultipartUploadInput(i.bucket, filename)
+	input.RequestPayer = i.requestPayer()
 	if err := input.Validate(); err != nil {
 		return "", errors.Wrap(err, "storage.createMultipartUpload: invalid params")
 	}
 
-	result, err := i.s3.CreateMultipartUpload(input)
+	result, err := i.s3.CreateMultipartUploadWithContext(ctx, input, headerOptions(opts.Headers)...)
 	if err != nil {
+		i.logError("storage: create multipart upload %s: %v", filename, err)
 		return "", errors.Wrap(err, "storage.createMultipartUpload")
 	}
 	if result.UploadId == nil {
 		return "", ErrMissedUploadID
 	}
+	i.logger.Debugf("storage: multipart upload %s created: %s", filename, *result.UploadId)
 
 	return *result.UploadId, nil
 }
@@ -145,36 +671,84 @@ func (i *Interactor) AbortMultipartUpload(filename, uploadID string) error {
 	if uploadID == "" {
 		return ErrMissedUploadID
 	}
+	if i.dryRun {
+		i.logger.Debugf("storage: dry run: abort multipart upload %s: %s", filename, uploadID)
+		return nil
+	}
+
+	return i.abortMultipartUpload(filename, uploadID)
+}
 
+// AbortFailedUpload aborts the multipart upload the caller has just created
+// and failed to complete. Unlike AbortMultipartUpload it ignores the dry-run mode:
+// it's the cleanup after a failed operation, not the removal of the stored data.
+func (i *Interactor) AbortFailedUpload(filename, uploadID string) error {
+	if uploadID == "" {
+		return ErrMissedUploadID
+	}
+
+	return i.abortMultipartUpload(filename, uploadID)
+}
+
+// abortMultipartUpload aborts a multipart upload regardless of the dry-run mode.
+func (i *Interactor) abortMultipartUpload(filename, uploadID string) error {
 	params := &s3.AbortMultipartUploadInput{
-		Bucket:   aws.String(i.bucket),
-		Key:      aws.String(filename),
-		UploadId: aws.String(uploadID),
+		Bucket:       aws.String(i.bucket),
+		RequestPayer: i.requestPayer(),
+		Key:          aws.String(trimKey(filename)),
+		UploadId:     aws.String(uploadID),
 	}
 	if err := params.Validate(); err != nil {
 		return errors.Wrap(err, "storage.abortMultipartUpload: invalid params")
 	}
 
 	if _, err := i.s3.AbortMultipartUpload(params); err != nil {
+		i.logError("storage: abort multipart upload %s (%s): %v", filename, uploadID, err)
 		return errors.Wrap(err, "storage.abortMultipartUpload")
 	}
+	i.logger.Debugf("storage: multipart upload %s aborted: %s", filename, uploadID)
 
 	return nil
 }
 
 // CompleteMultipartUpload completes a multipart upload.
 func (i *Interactor) CompleteMultipartUpload(filename, uploadID string, completedParts ...CompletedPart) error {
+	_, err := i.CompleteMultipartUploadWithResult(filename, uploadID, completedParts...)
+	return err
+}
+
+// CompleteMultipartUploadWithResult completes a multipart upload
+// and returns the ETag, the version ID and the location of the assembled object.
+// The ETag of a multipart object is the composite one:
+// the MD5 of the parts' MD5s followed by a dash and the number of parts.
+func (i *Interactor) CompleteMultipartUploadWithResult(filename, uploadID string, completedParts ...CompletedPart) (*UploadResult, error) {
+	return i.CompleteMultipartUploadWithContext(context.Background(), filename, uploadID, completedParts...)
+}
+
+// CompleteMultipartUploadWithContext is CompleteMultipartUploadWithResult which cancels the request when ctx is done.
+func (i *Interactor) CompleteMultipartUploadWithContext(ctx context.Context, filename, uploadID string, completedParts ...CompletedPart) (*UploadResult, error) {
+	return i.CompleteMultipartUploadWithOptions(ctx, filename, uploadID, CompleteOptions{}, completedParts...)
+}
+
+// CompleteMultipartUploadWithOptions is CompleteMultipartUploadWithContext with the given completion options.
+// The parts are sorted by the part number unless opts.PreSorted is set, the passed slice is never modified.
+func (i *Interactor) CompleteMultipartUploadWithOptions(ctx context.Context, filename, uploadID string, opts CompleteOptions, completedParts ...CompletedPart) (*UploadResult, error) {
 	if uploadID == "" {
-		return ErrMissedUploadID
+		return nil, ErrMissedUploadID
 	}
+	// The result reports the key the object is stored under.
+	filename = trimKey(filename)
 	if len(completedParts) == 0 {
-		return ErrNoCompletedParts
+		return nil, ErrNoCompletedParts
 	}
 
-	// Ordering the array based on the PartNumber as each parts could be uploaded in different order!
-	sort.Slice(completedParts, func(i, j int) bool {
-		return completedParts[i].PartNumber() < completedParts[j].PartNumber()
-	})
+	if !opts.PreSorted {
+		// Ordering a copy based on the PartNumber as each parts could be uploaded in different order!
+		completedParts = append([]CompletedPart(nil), completedParts...)
+		sort.Slice(completedParts, func(i, j int) bool {
+			return completedParts[i].PartNumber() < completedParts[j].PartNumber()
+		})
+	}
 
 	// Converting the CompletedPart to s3.CompletedPart
 	parts := make([]*s3.CompletedPart, len(completedParts))
@@ -187,51 +761,131 @@ func (i *Interactor) CompleteMultipartUpload(filename, uploadID string, complete
 
 	params := &s3.CompleteMultipartUploadInput{
 		Bucket:          aws.String(i.bucket),
-		Key:             aws.String(filename),
+		RequestPayer:    i.requestPayer(),
+		Key:             aws.String(trimKey(filename)),
 		UploadId:        aws.String(uploadID),
 		MultipartUpload: &s3.CompletedMultipartUpload{Parts: parts},
 	}
 	if err := params.Validate(); err != nil {
-		return errors.Wrap(err, "storage.completeMultipartUpload: invalid params")
+		return nil, errors.Wrap(err, "storage.completeMultipartUpload: invalid params")
 	}
 
-	if _, err := i.s3.CompleteMultipartUpload(params); err != nil {
-		return errors.Wrap(err, "storage.completeMultipartUpload")
+	result, err := i.s3.CompleteMultipartUploadWithContext(ctx, params)
+	if err != nil {
+		i.logError("storage: complete multipart upload %s (%s): %v", filename, uploadID, err)
+		if hasCode(err, "InvalidPart") {
+			return nil, &InvalidPartError{
+				PartNumber: i.findInvalidPart(filename, uploadID, completedParts),
+				Err:        err,
+			}
+		}
+		return nil, errors.Wrap(err, "storage.completeMultipartUpload")
 	}
+	i.logger.Debugf("storage: multipart upload %s completed: %s", filename, uploadID)
+	i.stats.uploads.Add(1)
 
-	return nil
+	return &UploadResult{
+		Key:       filename,
+		ETag:      aws.StringValue(result.ETag),
+		VersionID: aws.StringValue(result.VersionId),
+		Location:  aws.StringValue(result.Location),
+	}, nil
+}
+
+// findInvalidPart returns the number of the first completed part
+// which is missed in the storage or has another ETag.
+// Returns 0 if there is no such part or the parts can't be listed.
+func (i *Interactor) findInvalidPart(filename, uploadID string, completedParts []CompletedPart) int64 {
+	stored := make(map[int64]string)
+	if err := i.s3.ListPartsPages(&s3.ListPartsInput{
+		Bucket:       aws.String(i.bucket),
+		RequestPayer: i.requestPayer(),
+		Key:          aws.String(trimKey(filename)),
+		UploadId:     aws.String(uploadID),
+	}, func(page *s3.ListPartsOutput, _ bool) bool {
+		for _, p := range page.Parts {
+			stored[aws.Int64Value(p.PartNumber)] = aws.StringValue(p.ETag)
+		}
+		return true
+	}); err != nil {
+		return 0
+	}
+
+	for _, part := range completedParts {
+		etag, ok := stored[part.PartNumber()]
+		if !ok || strings.Trim(etag, `"`) != strings.Trim(part.ETag(), `"`) {
+			return part.PartNumber()
+		}
+	}
+
+	return 0
 }
 
 // Upload uploads a file to S3.
 // If partNum is equal to totalParts, the file is considered complete and the
 // multipart upload is completed.
 func (i *Interactor) UploadPart(filename, uploadID string, data []byte, partNum, totalParts int64) (CompletedPart, error) {
+	return i.UploadPartWithOptions(filename, uploadID, data, partNum, totalParts, UploadPartOptions{})
+}
+
+// UploadPartWithOptions uploads a part of the multipart upload.
+// Returns ErrEncryptionMismatch if the part isn't encrypted as opts.Encryption expects.
+func (i *Interactor) UploadPartWithOptions(filename, uploadID string, data []byte, partNum, totalParts int64, opts UploadPartOptions) (CompletedPart, error) {
+	return i.UploadPartWithContext(context.Background(), filename, uploadID, data, partNum, totalParts, opts)
+}
+
+// UploadPartWithContext is UploadPartWithOptions which cancels the request when ctx is done.
+func (i *Interactor) UploadPartWithContext(ctx context.Context, filename, uploadID string, data []byte, partNum, totalParts int64, opts UploadPartOptions) (CompletedPart, error) {
 	if uploadID == "" {
 		return nil, ErrMissedUploadID
 	}
+	if i.keys != nil {
+		return nil, errors.Wrap(ErrEncryptionUnsupported, "storage.uploadPart")
+	}
 
-	if totalParts == 0 || totalParts > 10000 {
+	if totalParts <= 0 || totalParts > i.maxParts {
 		return nil, ErrTotalParts
 	}
 	if partNum < 1 || partNum > totalParts {
 		return nil, ErrPartNum
 	}
+	if len(data) == 0 {
+		// An empty part breaks the completion of the upload.
+		return nil, ErrPartEmpty
+	}
+	if partNum < totalParts && int64(len(data)) < i.minPartSize {
+		// Only the last part can be smaller than the min part size.
+		return nil, ErrPartTooSmall
+	}
+	if int64(len(data)) > i.maxPartSize {
+		return nil, ErrPartTooLarge
+	}
 
 	params := &s3.UploadPartInput{
-		Bucket:     aws.String(i.bucket),
-		Key:        aws.String(filename),
-		UploadId:   aws.String(uploadID),
-		PartNumber: aws.Int64(partNum),
-		Body:       bytes.NewReader(data),
+		Bucket:       aws.String(i.bucket),
+		RequestPayer: i.requestPayer(),
+		Key:          aws.String(trimKey(filename)),
+		UploadId:     aws.String(uploadID),
+		PartNumber:   aws.Int64(partNum),
+		Body:         bytes.NewReader(data),
+		// Content-MD5 is required for parts of the objects with object lock settings,
+		// and lets the storage reject corrupted parts in any case.
+		ContentMD5: aws.String(contentMD5(data)),
 	}
 	if err := params.Validate(); err != nil {
 		return nil, errors.Wrap(err, "storage.uploadPart: invalid params")
 	}
 
-	partResp, err := i.s3.UploadPart(params)
+	partResp, err := i.s3.UploadPartWithContext(ctx, params, headerOptions(opts.Headers)...)
 	if err != nil {
+		i.logError("storage: upload part %d/%d of %s: %v", partNum, totalParts, filename, err)
 		return nil, errors.Wrap(err, "storage.uploadPart")
 	}
+	i.logger.Debugf("storage: uploaded part %d/%d of %s", partNum, totalParts, filename)
+	i.stats.bytesUploaded.Add(int64(len(data)))
+	if !opts.Encryption.matches(aws.StringValue(partResp.ServerSideEncryption), aws.StringValue(partResp.SSEKMSKeyId)) {
+		return nil, ErrEncryptionMismatch
+	}
 
 	return &completedPart{
 		etag:       *partResp.ETag,
@@ -1,11 +1,21 @@
 package storage_test
 
 import (
+	"archive/tar"
+	"archive/zip"
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
 	"io"
 	"os"
+	"path/filepath"
 	"strings"
 	"testing"
+	"time"
 
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/s3"
 	"github.com/dmitrymomot/go-env"
 	"github.com/dmitrymomot/gofs/storage"
 	"github.com/google/uuid"
@@ -202,3 +212,749 @@ func TestMultipartUpload(t *testing.T) {
 		assert.Error(t, err)
 	})
 }
+
+// Test gzipped upload to S3-compatible storage.
+func TestGzippedUpload(t *testing.T) {
+	filepath := strings.Join([]string{
+		"testing",
+		uuid.New().String(),
+		"data.json",
+	}, "/")
+
+	require.NoError(t, interactor.UploadGzipped([]byte(`{"hello":"world"}`), filepath, storage.Private, "application/json"))
+	defer interactor.Delete(filepath)
+
+	info, err := interactor.Stat(filepath)
+	require.NoError(t, err)
+	assert.Equal(t, "gzip", info.ContentEncoding)
+	assert.Equal(t, "application/json", info.ContentType)
+
+	_, err = interactor.Stat(filepath + ".missed")
+	assert.ErrorIs(t, err, storage.ErrObjectNotFound)
+
+	body, contentType, err := interactor.DownloadDecompressed(filepath)
+	require.NoError(t, err)
+	defer body.Close()
+	data, err := io.ReadAll(body)
+	require.NoError(t, err)
+	assert.Equal(t, `{"hello":"world"}`, string(data))
+	assert.Equal(t, "application/json", *contentType)
+}
+
+// Test streaming upload with hashing.
+func TestUploadAndHash(t *testing.T) {
+	filepath := strings.Join([]string{
+		"testing",
+		uuid.New().String(),
+		"data.bin",
+	}, "/")
+
+	data := bytes.Repeat([]byte("gofs"), 3*1024*1024) // 12 MiB, uploaded in parts
+	sum := sha256.Sum256(data)
+
+	hash, err := interactor.UploadAndHash(bytes.NewReader(data), filepath, "application/octet-stream", storage.Private)
+	require.NoError(t, err)
+	defer interactor.Delete(filepath)
+	assert.Equal(t, hex.EncodeToString(sum[:]), hash)
+
+	info, err := interactor.Stat(filepath)
+	require.NoError(t, err)
+	assert.Equal(t, int64(len(data)), info.ContentLength)
+}
+
+// Test uploading the opened file.
+func TestUploadFileHandle(t *testing.T) {
+	file, err := os.Open("testdata/image.png")
+	require.NoError(t, err)
+	defer file.Close()
+
+	stat, err := file.Stat()
+	require.NoError(t, err)
+
+	filepath := strings.Join([]string{
+		"testing",
+		uuid.New().String(),
+		"image.png",
+	}, "/")
+
+	// The file is uploaded from the beginning regardless of the current offset.
+	_, err = file.Seek(10, io.SeekStart)
+	require.NoError(t, err)
+
+	require.NoError(t, interactor.UploadFileHandle(file, filepath, storage.Private))
+	defer interactor.Delete(filepath)
+
+	info, err := interactor.Stat(filepath)
+	require.NoError(t, err)
+	assert.Equal(t, "image/png", info.ContentType)
+	assert.Equal(t, stat.Size(), info.ContentLength)
+
+	assert.ErrorIs(t, interactor.UploadFileHandle(nil, filepath, storage.Private), storage.ErrInvalidReader)
+}
+
+// Test uploading empty files.
+func TestUploadEmpty(t *testing.T) {
+	filepath := strings.Join([]string{
+		"testing",
+		uuid.New().String(),
+		"empty.txt",
+	}, "/")
+
+	assert.ErrorIs(t, interactor.Upload(nil, filepath, storage.Private, "text/plain"), storage.ErrFileEmpty)
+	assert.ErrorIs(t, interactor.UploadGzipped([]byte{}, filepath, storage.Private, "text/plain"), storage.ErrFileEmpty)
+
+	_, err := interactor.UploadAndHash(bytes.NewReader(nil), filepath, "text/plain", storage.Private)
+	assert.ErrorIs(t, err, storage.ErrFileEmpty)
+
+	require.NoError(t, interactor.UploadWithOptions(nil, filepath, storage.UploadOptions{
+		ACL:         storage.Private,
+		ContentType: "text/plain",
+		AllowEmpty:  true,
+	}))
+	defer interactor.Delete(filepath)
+
+	info, err := interactor.Stat(filepath)
+	require.NoError(t, err)
+	assert.Zero(t, info.ContentLength)
+}
+
+// Test reading the object info with the user metadata.
+func TestStat(t *testing.T) {
+	filepath := strings.Join([]string{
+		"testing",
+		uuid.New().String(),
+		"text.txt",
+	}, "/")
+
+	expires := time.Now().Add(time.Hour).UTC().Truncate(time.Second)
+	require.NoError(t, interactor.UploadWithOptions([]byte("Hello, World!"), filepath, storage.UploadOptions{
+		ACL:         storage.Private,
+		ContentType: "text/plain",
+		Metadata:    map[string]string{"owner-id": "42"},
+		Expires:     expires,
+	}))
+	defer interactor.Delete(filepath)
+
+	info, err := interactor.Stat(filepath)
+	require.NoError(t, err)
+	assert.Equal(t, filepath, info.Key)
+	assert.Equal(t, "text/plain", info.ContentType)
+	assert.Equal(t, int64(len("Hello, World!")), info.ContentLength)
+	assert.NotEmpty(t, info.ETag)
+	assert.False(t, info.LastModified.IsZero())
+	assert.Equal(t, map[string]string{"owner-id": "42"}, info.Metadata)
+	assert.True(t, expires.Equal(info.Expires))
+}
+
+// Test exporting objects with the prefix into an archive.
+func TestExportPrefix(t *testing.T) {
+	prefix := strings.Join([]string{"testing", uuid.New().String()}, "/")
+	files := map[string]string{
+		"a.txt":     "Hello, World!",
+		"dir/b.txt": "Hello, gofs!",
+	}
+	for name, content := range files {
+		require.NoError(t, interactor.Upload([]byte(content), prefix+"/"+name, storage.Private, "text/plain"))
+	}
+	defer interactor.DeletePrefix(prefix)
+
+	t.Run("tar", func(t *testing.T) {
+		var buf bytes.Buffer
+		require.NoError(t, interactor.ExportPrefix(prefix, &buf, storage.ArchiveTar))
+
+		exported := make(map[string]string)
+		tr := tar.NewReader(&buf)
+		for {
+			hdr, err := tr.Next()
+			if err == io.EOF {
+				break
+			}
+			require.NoError(t, err)
+			data, err := io.ReadAll(tr)
+			require.NoError(t, err)
+			exported[hdr.Name] = string(data)
+		}
+		assert.Equal(t, files, exported)
+	})
+
+	t.Run("zip", func(t *testing.T) {
+		var buf bytes.Buffer
+		require.NoError(t, interactor.ExportPrefix(prefix, &buf, storage.ArchiveZip))
+
+		zr, err := zip.NewReader(bytes.NewReader(buf.Bytes()), int64(buf.Len()))
+		require.NoError(t, err)
+
+		exported := make(map[string]string)
+		for _, f := range zr.File {
+			rc, err := f.Open()
+			require.NoError(t, err)
+			data, err := io.ReadAll(rc)
+			rc.Close()
+			require.NoError(t, err)
+			exported[f.Name] = string(data)
+		}
+		assert.Equal(t, files, exported)
+	})
+
+	assert.ErrorIs(t, interactor.ExportPrefix(prefix, io.Discard, "rar"), storage.ErrUnsupportedArchiveFormat)
+}
+
+// Test downloading objects into a local directory.
+func TestDownloadMany(t *testing.T) {
+	prefix := strings.Join([]string{"testing", uuid.New().String()}, "/")
+	files := map[string]string{
+		prefix + "/a.txt":     "Hello, World!",
+		prefix + "/dir/b.txt": "Hello, gofs!",
+	}
+	keys := make([]string, 0, len(files))
+	for key, content := range files {
+		require.NoError(t, interactor.Upload([]byte(content), key, storage.Private, "text/plain"))
+		keys = append(keys, key)
+	}
+	defer interactor.DeletePrefix(prefix)
+
+	dir := t.TempDir()
+	err := interactor.DownloadMany(context.Background(), append(keys, prefix+"/missed.txt", "../escape.txt"), dir, 2)
+	var batchErr *storage.BatchError
+	require.ErrorAs(t, err, &batchErr)
+	assert.Equal(t, []string{"../escape.txt", prefix + "/missed.txt"}, batchErr.Failed())
+	assert.ErrorIs(t, batchErr.Errors["../escape.txt"], storage.ErrUnsafePath)
+
+	for key, content := range files {
+		data, err := os.ReadFile(filepath.Join(dir, filepath.FromSlash(key)))
+		require.NoError(t, err)
+		assert.Equal(t, content, string(data))
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	err = interactor.DownloadMany(ctx, keys, t.TempDir(), 2)
+	assert.ErrorIs(t, err, context.Canceled)
+	require.ErrorAs(t, err, &batchErr)
+	assert.Len(t, batchErr.Failed(), len(keys))
+}
+
+// Test downloading with a copy into the cache.
+func TestDownloadTee(t *testing.T) {
+	filepath := strings.Join([]string{
+		"testing",
+		uuid.New().String(),
+		"text.txt",
+	}, "/")
+
+	require.NoError(t, interactor.Upload([]byte("Hello, World!"), filepath, storage.Private, "text/plain"))
+	defer interactor.Delete(filepath)
+
+	var cache bytes.Buffer
+	body, info, err := interactor.DownloadTee(filepath, &cache)
+	require.NoError(t, err)
+	assert.Equal(t, "text/plain", info.ContentType)
+	assert.Equal(t, int64(13), info.ContentLength)
+
+	data, err := io.ReadAll(body)
+	require.NoError(t, err)
+	require.NoError(t, body.Close())
+	assert.Equal(t, "Hello, World!", string(data))
+	assert.Equal(t, "Hello, World!", cache.String())
+
+	_, _, err = interactor.DownloadTee(filepath+".missed", &cache)
+	assert.ErrorIs(t, err, storage.ErrObjectNotFound)
+}
+
+func TestDownloadBytes(t *testing.T) {
+	filepath := strings.Join([]string{
+		"testing",
+		uuid.New().String(),
+		"text.txt",
+	}, "/")
+
+	require.NoError(t, interactor.Upload([]byte("Hello, World!"), filepath, storage.Private, "text/plain"))
+	defer interactor.Delete(filepath)
+
+	data, contentType, err := interactor.DownloadBytes(filepath, 13)
+	require.NoError(t, err)
+	assert.Equal(t, "Hello, World!", string(data))
+	assert.Equal(t, "text/plain", contentType)
+
+	_, _, err = interactor.DownloadBytes(filepath, 12)
+	assert.ErrorIs(t, err, storage.ErrFileTooLarge)
+
+	_, _, err = interactor.DownloadBytes(filepath+".missed", 13)
+	assert.ErrorIs(t, err, storage.ErrObjectNotFound)
+}
+
+// Test updating the object metadata in place.
+func TestRefreshMetadata(t *testing.T) {
+	filepath := strings.Join([]string{
+		"testing",
+		uuid.New().String(),
+		"text.txt",
+	}, "/")
+
+	require.NoError(t, interactor.UploadWithOptions([]byte("Hello, World!"), filepath, storage.UploadOptions{
+		ACL:         storage.Private,
+		ContentType: "text/plain",
+		Metadata:    map[string]string{"owner-id": "42"},
+	}))
+	defer interactor.Delete(filepath)
+
+	require.NoError(t, interactor.RefreshMetadata(filepath, storage.MetadataOptions{
+		CacheControl: "max-age=3600",
+		Metadata:     map[string]string{"reviewed": "true"},
+	}))
+
+	info, err := interactor.Stat(filepath)
+	require.NoError(t, err)
+	assert.Equal(t, "text/plain", info.ContentType)
+	assert.Equal(t, int64(len("Hello, World!")), info.ContentLength)
+	assert.Equal(t, map[string]string{"owner-id": "42", "reviewed": "true"}, info.Metadata)
+
+	assert.ErrorIs(t, interactor.RefreshMetadata(filepath+".missed", storage.MetadataOptions{}), storage.ErrObjectNotFound)
+}
+
+// Test querying CSV object content.
+func TestSelectContent(t *testing.T) {
+	filepath := strings.Join([]string{
+		"testing",
+		uuid.New().String(),
+		"users.csv",
+	}, "/")
+
+	csv := "name,age\nalice,31\nbob,25\ncarol,42\n"
+	require.NoError(t, interactor.Upload([]byte(csv), filepath, storage.Private, "text/csv"))
+	defer interactor.Delete(filepath)
+
+	rows, err := interactor.SelectContent(filepath, storage.SelectQuery{
+		Expression:  "SELECT s.name FROM S3Object s WHERE CAST(s.age AS INT) > 30",
+		InputFormat: storage.SelectCSV,
+		CSVHeader:   true,
+	})
+	require.NoError(t, err)
+	defer rows.Close()
+
+	data, err := io.ReadAll(rows)
+	require.NoError(t, err)
+	assert.Equal(t, "alice\ncarol\n", string(data))
+
+	_, err = interactor.SelectContent(filepath, storage.SelectQuery{
+		Expression:  "SELEC name FROM",
+		InputFormat: storage.SelectCSV,
+	})
+	assert.ErrorIs(t, err, storage.ErrInvalidSelectQuery)
+
+	_, err = interactor.SelectContent(filepath, storage.SelectQuery{InputFormat: storage.SelectCSV})
+	assert.ErrorIs(t, err, storage.ErrInvalidSelectQuery)
+}
+
+// Test appending data to the stored objects.
+func TestAppendData(t *testing.T) {
+	prefix := strings.Join([]string{"testing", uuid.New().String()}, "/")
+	defer interactor.DeletePrefix(prefix)
+
+	t.Run("small object", func(t *testing.T) {
+		filepath := prefix + "/small.log"
+		require.NoError(t, interactor.AppendData(filepath, []byte("line 1\n")))
+		require.NoError(t, interactor.AppendData(filepath, []byte("line 2\n")))
+
+		body, _, err := interactor.Download(filepath)
+		require.NoError(t, err)
+		defer body.Close()
+		data, err := io.ReadAll(body)
+		require.NoError(t, err)
+		assert.Equal(t, "line 1\nline 2\n", string(data))
+	})
+
+	t.Run("large object", func(t *testing.T) {
+		filepath := prefix + "/large.log"
+		content := bytes.Repeat([]byte("log line\n"), 700*1024) // 6.3 MB, larger than the min part size
+		require.NoError(t, interactor.Upload(content, filepath, storage.Private, "text/plain"))
+		require.NoError(t, interactor.AppendData(filepath, []byte("last line\n")))
+
+		info, err := interactor.Stat(filepath)
+		require.NoError(t, err)
+		assert.Equal(t, "text/plain", info.ContentType)
+		assert.Equal(t, int64(len(content)+len("last line\n")), info.ContentLength)
+	})
+
+	assert.ErrorIs(t, interactor.AppendData(prefix+"/empty.log", nil), storage.ErrFileEmpty)
+}
+
+// Test listing in-progress multipart uploads.
+func TestListMultipartUploads(t *testing.T) {
+	prefix := strings.Join([]string{"testing", uuid.New().String()}, "/")
+	filepath := prefix + "/text.txt"
+
+	uploadID, err := interactor.CreateMultipartUpload(filepath, "text/plain", storage.Private)
+	require.NoError(t, err)
+	defer interactor.AbortMultipartUpload(filepath, uploadID)
+
+	uploads, err := interactor.ListMultipartUploads(prefix)
+	require.NoError(t, err)
+	require.Len(t, uploads, 1)
+	assert.Equal(t, filepath, uploads[0].Key)
+	assert.Equal(t, uploadID, uploads[0].UploadID)
+	assert.False(t, uploads[0].Initiated.IsZero())
+}
+
+// Test copying with the storage class and the tags.
+func TestCopyWithOptions(t *testing.T) {
+	prefix := strings.Join([]string{"testing", uuid.New().String()}, "/")
+	src, dst := prefix+"/text.txt", prefix+"/archive/text.txt"
+
+	require.NoError(t, interactor.Upload([]byte("Hello, World!"), src, storage.Private, "text/plain"))
+	defer interactor.DeletePrefix(prefix)
+
+	require.NoError(t, interactor.CopyWithOptions(src, dst, storage.CopyOptions{
+		ACL:          storage.Private,
+		StorageClass: "STANDARD_IA",
+		Tags:         map[string]string{"archived": "true"},
+	}))
+
+	info, err := interactor.Stat(dst)
+	require.NoError(t, err)
+	assert.Equal(t, "STANDARD_IA", info.StorageClass)
+	assert.Equal(t, "text/plain", info.ContentType)
+
+	tagging, err := s3Client.GetObjectTagging(&s3.GetObjectTaggingInput{
+		Bucket: aws.String(fileStorageBucket),
+		Key:    aws.String(dst),
+	})
+	require.NoError(t, err)
+	require.Len(t, tagging.TagSet, 1)
+	assert.Equal(t, "archived", aws.StringValue(tagging.TagSet[0].Key))
+	assert.Equal(t, "true", aws.StringValue(tagging.TagSet[0].Value))
+
+	assert.ErrorIs(t, interactor.CopyWithOptions(src, dst, storage.CopyOptions{
+		TaggingDirective: storage.TaggingCopy,
+		Tags:             map[string]string{"archived": "true"},
+	}), storage.ErrTaggingConflict)
+	assert.ErrorIs(t, interactor.CopyWithOptions(src, dst, storage.CopyOptions{
+		Tags: map[string]string{"archived": strings.Repeat("x", 257)},
+	}), storage.ErrInvalidTags)
+	assert.ErrorIs(t, interactor.CopyWithOptions(src, dst, storage.CopyOptions{
+		Tags: map[string]string{"archived?": "true"},
+	}), storage.ErrInvalidTags)
+}
+
+// Test uploading the small file with the lightweight upload.
+func TestLightweightUpload(t *testing.T) {
+	filepath := strings.Join([]string{
+		"testing",
+		uuid.New().String(),
+		"text.txt",
+	}, "/")
+
+	require.NoError(t, interactor.LightweightUpload([]byte("Hello, World!"), filepath, "", storage.Private))
+	defer interactor.Delete(filepath)
+
+	info, err := interactor.Stat(filepath)
+	require.NoError(t, err)
+	assert.Equal(t, "text/plain", info.ContentType)
+	assert.Equal(t, int64(13), info.ContentLength)
+
+	assert.ErrorIs(t, interactor.LightweightUpload(nil, filepath, "text/plain", storage.Private), storage.ErrFileEmpty)
+}
+
+// Test reading the first bytes of the stored object.
+func TestPeek(t *testing.T) {
+	filepath := strings.Join([]string{
+		"testing",
+		uuid.New().String(),
+		"text.txt",
+	}, "/")
+
+	require.NoError(t, interactor.Upload([]byte("Hello, World!"), filepath, storage.Private, "text/plain"))
+	defer interactor.Delete(filepath)
+
+	data, err := interactor.Peek(filepath, 5)
+	require.NoError(t, err)
+	assert.Equal(t, "Hello", string(data))
+
+	data, err = interactor.Peek(filepath, 100)
+	require.NoError(t, err)
+	assert.Equal(t, "Hello, World!", string(data))
+
+	_, err = interactor.Peek(filepath+".missed", 5)
+	assert.ErrorIs(t, err, storage.ErrObjectNotFound)
+}
+
+// Test getting the HTTP cache key of the stored object.
+func TestCacheKey(t *testing.T) {
+	filepath := strings.Join([]string{
+		"testing",
+		uuid.New().String(),
+		"text.txt",
+	}, "/")
+
+	result, err := interactor.UploadWithResult([]byte("Hello, World!"), filepath, storage.UploadOptions{ContentType: "text/plain"})
+	require.NoError(t, err)
+	defer interactor.Delete(filepath)
+
+	etag, lastModified, err := interactor.CacheKey(filepath)
+	require.NoError(t, err)
+	assert.Equal(t, `"`+strings.Trim(result.ETag, `"`)+`"`, etag)
+	assert.WithinDuration(t, time.Now(), lastModified, time.Hour)
+	assert.Equal(t, time.UTC, lastModified.Location())
+
+	_, _, err = interactor.CacheKey(filepath + ".missed")
+	assert.ErrorIs(t, err, storage.ErrObjectNotFound)
+}
+
+// Test reporting the byte ranges of the uploaded parts.
+func TestPartsReport(t *testing.T) {
+	filepath := strings.Join([]string{
+		"testing",
+		uuid.New().String(),
+		"data.bin",
+	}, "/")
+
+	uploadID, err := interactor.CreateMultipartUpload(filepath, "application/octet-stream", storage.Private)
+	require.NoError(t, err)
+	defer interactor.AbortMultipartUpload(filepath, uploadID)
+
+	first := bytes.Repeat([]byte("a"), int(storage.MinPartSize))
+	_, err = interactor.UploadPart(filepath, uploadID, first, 1, 2)
+	require.NoError(t, err)
+	_, err = interactor.UploadPart(filepath, uploadID, []byte("tail"), 2, 2)
+	require.NoError(t, err)
+
+	report, err := interactor.PartsReport(filepath, uploadID)
+	require.NoError(t, err)
+	require.Len(t, report, 2)
+	assert.Equal(t, int64(1), report[0].PartNumber)
+	assert.Equal(t, int64(0), report[0].StartOffset)
+	assert.Equal(t, storage.MinPartSize-1, report[0].EndOffset)
+	assert.Equal(t, int64(2), report[1].PartNumber)
+	assert.Equal(t, int64(4), report[1].Size)
+	assert.Equal(t, storage.MinPartSize, report[1].StartOffset)
+	assert.Equal(t, storage.MinPartSize+3, report[1].EndOffset)
+	assert.NotEmpty(t, report[1].ETag)
+}
+
+// Test changing ACL of the stored object.
+func TestSetACL(t *testing.T) {
+	filepath := strings.Join([]string{
+		"testing",
+		uuid.New().String(),
+		"text.txt",
+	}, "/")
+
+	require.NoError(t, interactor.Upload([]byte("Hello, World!"), filepath, storage.Private, "text/plain"))
+	defer interactor.Delete(filepath)
+
+	require.NoError(t, interactor.SetACL(filepath, storage.Public))
+
+	info, err := interactor.Stat(filepath)
+	require.NoError(t, err)
+	assert.Equal(t, "text/plain", info.ContentType)
+
+	assert.ErrorIs(t, interactor.SetACL(filepath+".missed", storage.Public), storage.ErrObjectNotFound)
+}
+
+// Test completing multipart upload with a wrong part ETag.
+func TestCompleteMultipartUploadInvalidPart(t *testing.T) {
+	filepath := strings.Join([]string{
+		"testing",
+		uuid.New().String(),
+		"text.txt",
+	}, "/")
+
+	uploadID, err := interactor.CreateMultipartUpload(filepath, "text/plain", storage.Private)
+	require.NoError(t, err)
+	defer interactor.AbortMultipartUpload(filepath, uploadID)
+
+	_, err = interactor.UploadPart(filepath, uploadID, []byte("Hello, World!"), 1, 1)
+	require.NoError(t, err)
+
+	wrongPart := &testPart{partNumber: 1, etag: `"00000000000000000000000000000000"`}
+	err = interactor.CompleteMultipartUpload(filepath, uploadID, wrongPart)
+	require.ErrorIs(t, err, storage.ErrInvalidPart)
+
+	var partErr *storage.InvalidPartError
+	require.ErrorAs(t, err, &partErr)
+	assert.Equal(t, int64(1), partErr.PartNumber)
+}
+
+// testPart implements storage.CompletedPart.
+type testPart struct {
+	partNumber int64
+	etag       string
+}
+
+func (p *testPart) PartNumber() int64 { return p.partNumber }
+func (p *testPart) ETag() string      { return p.etag }
+
+// Test object lock options validation.
+func TestUploadInvalidRetention(t *testing.T) {
+	err := interactor.UploadWithOptions([]byte("Hello, World!"), "testing/locked.txt", storage.UploadOptions{
+		ObjectLockMode:  storage.Compliance,
+		RetainUntilDate: time.Now().Add(-time.Hour),
+	})
+	assert.ErrorIs(t, err, storage.ErrInvalidRetention)
+
+	_, err = interactor.CreateMultipartUploadWithOptions("testing/locked.txt", storage.UploadOptions{
+		ObjectLockMode: storage.Governance,
+	})
+	assert.ErrorIs(t, err, storage.ErrInvalidRetention)
+}
+
+// Test object checksum verification.
+func TestVerifyObjectChecksum(t *testing.T) {
+	filepath := strings.Join([]string{
+		"testing",
+		uuid.New().String(),
+		"text.txt",
+	}, "/")
+
+	require.NoError(t, interactor.Upload([]byte("Hello, World!"), filepath, storage.Private, "text/plain"))
+	defer interactor.Delete(filepath)
+
+	// sha256 of "Hello, World!"
+	assert.NoError(t, interactor.VerifyObjectChecksum(filepath, "dffd6021bb2bd5b0af676290809ec3a53191dd81c7f70a4b28688a362182986f"))
+	assert.ErrorIs(t, interactor.VerifyObjectChecksum(filepath, strings.Repeat("0", 64)), storage.ErrChecksumMismatch)
+	assert.ErrorIs(t, interactor.VerifyObjectChecksum(filepath, "invalid"), storage.ErrInvalidChecksum)
+}
+
+// Test server-side encryption options validation.
+func TestUploadEncryptionMismatch(t *testing.T) {
+	_, err := interactor.CreateMultipartUploadWithOptions("testing/encrypted.txt", storage.UploadOptions{
+		Encryption: storage.Encryption{
+			Algorithm:  storage.EncryptionAES256,
+			KMSContext: map[string]string{"tenant": "a"},
+		},
+	})
+	assert.ErrorIs(t, err, storage.ErrEncryptionMismatch)
+}
+
+// Test recursive folder removal.
+func TestDeletePrefix(t *testing.T) {
+	_, err := interactor.DeletePrefix("")
+	require.ErrorIs(t, err, storage.ErrEmptyPrefix)
+
+	prefix := "testing/" + uuid.New().String() + "/"
+	for _, name := range []string{"a.txt", "b.txt", "nested/c.txt"} {
+		require.NoError(t, interactor.Upload([]byte("Hello, World!"), prefix+name, storage.Private, "text/plain"))
+	}
+
+	deleted, err := interactor.DeletePrefix(prefix)
+	require.NoError(t, err)
+	assert.Equal(t, 3, deleted)
+
+	_, err = interactor.Stat(prefix + "nested/c.txt")
+	assert.ErrorIs(t, err, storage.ErrObjectNotFound)
+}
+
+// Test seekable reader of the remote object.
+func TestOpenReader(t *testing.T) {
+	filepath := strings.Join([]string{
+		"testing",
+		uuid.New().String(),
+		"text.txt",
+	}, "/")
+
+	require.NoError(t, interactor.Upload([]byte("Hello, World!"), filepath, storage.Private, "text/plain"))
+	defer interactor.Delete(filepath)
+
+	r, err := interactor.OpenReader(filepath)
+	require.NoError(t, err)
+	defer r.Close()
+
+	_, err = r.Seek(7, io.SeekStart)
+	require.NoError(t, err)
+
+	data, err := io.ReadAll(r)
+	require.NoError(t, err)
+	assert.Equal(t, "World!", string(data))
+}
+
+// Test multipart limits configured for S3-compatible storages.
+func TestPartLimits(t *testing.T) {
+	limited := storage.New(s3Client, fileStorageBucket, fileStorageUrl,
+		storage.WithMaxParts(2),
+		storage.WithMinPartSize(4),
+	)
+
+	_, err := limited.UploadPart("testing/limits.txt", "upload-id", []byte("data"), 1, 3)
+	assert.ErrorIs(t, err, storage.ErrTotalParts)
+
+	_, err = limited.UploadPart("testing/limits.txt", "upload-id", []byte("abc"), 1, 2)
+	assert.ErrorIs(t, err, storage.ErrPartTooSmall)
+
+	file, err := os.Open("testdata/image.png")
+	require.NoError(t, err)
+	defer file.Close()
+
+	_, err = limited.MaxFileParts(file, 3)
+	assert.ErrorIs(t, err, storage.ErrPartTooSmall)
+
+	_, err = limited.MaxFileParts(file, 1024)
+	assert.ErrorIs(t, err, storage.ErrTotalParts)
+}
+
+// Test client-side encryption.
+func TestClientEncryption(t *testing.T) {
+	encrypted := storage.New(s3Client, fileStorageBucket, fileStorageUrl,
+		storage.WithClientEncryption(storage.StaticKey([]byte("0123456789abcdef0123456789abcdef"))),
+	)
+
+	filepath := strings.Join([]string{
+		"testing",
+		uuid.New().String(),
+		"secret.txt",
+	}, "/")
+
+	require.NoError(t, encrypted.Upload([]byte("Hello, World!"), filepath, storage.Private, "text/plain"))
+	defer encrypted.Delete(filepath)
+
+	// Raw content is encrypted.
+	raw, _, err := interactor.Download(filepath)
+	require.NoError(t, err)
+	data, err := io.ReadAll(raw)
+	raw.Close()
+	require.NoError(t, err)
+	assert.NotEqual(t, "Hello, World!", string(data))
+
+	// Content is decrypted transparently.
+	body, _, err := encrypted.Download(filepath)
+	require.NoError(t, err)
+	defer body.Close()
+	data, err = io.ReadAll(body)
+	require.NoError(t, err)
+	assert.Equal(t, "Hello, World!", string(data))
+}
+
+// Test dry-run mode of destructive operations.
+func TestDryRun(t *testing.T) {
+	dryRun := storage.New(s3Client, fileStorageBucket, fileStorageUrl, storage.WithDryRun())
+
+	deleted, err := dryRun.DeleteMany("testing/a.txt", "testing/b.txt")
+	require.NoError(t, err)
+	assert.Equal(t, []string{"testing/a.txt", "testing/b.txt"}, deleted)
+
+	assert.NoError(t, dryRun.Delete("testing/a.txt"))
+	assert.NoError(t, dryRun.AbortMultipartUpload("testing/a.txt", "upload-id"))
+}
+
+// Test uploading with the default ACL.
+func TestUploadWithDefaultACL(t *testing.T) {
+	i := storage.New(s3Client, fileStorageBucket, fileStorageUrl, storage.WithDefaultACL(storage.Public))
+	assert.Equal(t, storage.Public, i.DefaultACL())
+
+	filepath := strings.Join([]string{
+		"testing",
+		uuid.New().String(),
+		"text.txt",
+	}, "/")
+
+	require.NoError(t, i.UploadWithDefaultACL([]byte("Hello, World!"), filepath, "text/plain"))
+	defer i.Delete(filepath)
+
+	info, err := i.Stat(filepath)
+	require.NoError(t, err)
+	assert.Equal(t, "text/plain", info.ContentType)
+
+	// Invalid ACL fails the uploads.
+	i = storage.New(s3Client, fileStorageBucket, fileStorageUrl, storage.WithDefaultACL("public"))
+	assert.Equal(t, storage.Private, i.DefaultACL())
+	assert.ErrorIs(t, i.UploadWithDefaultACL([]byte("Hello, World!"), filepath, "text/plain"), storage.ErrInvalidACL)
+}
@@ -0,0 +1,132 @@
+package storage
+
+import (
+	"path"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"github.com/google/uuid"
+	"github.com/pkg/errors"
+)
+
+// maxKeyLen is the max length of the object key in bytes.
+const maxKeyLen = 1024
+
+// KeyStrategy builds the object key from the original file name.
+type KeyStrategy func(originalName string) string
+
+// KeyGenerator generates the unique part of the object keys, e.g. a UUID, ULID or KSUID.
+type KeyGenerator interface {
+	NewKey() string
+}
+
+// KeyGeneratorFunc is a function implementing KeyGenerator.
+type KeyGeneratorFunc func() string
+
+// NewKey returns the result of the function.
+func (f KeyGeneratorFunc) NewKey() string {
+	return f()
+}
+
+// UUIDGenerator generates random UUIDs, it's the default key generator.
+var UUIDGenerator KeyGenerator = KeyGeneratorFunc(func() string {
+	return uuid.New().String()
+})
+
+// UniqueKey returns the key "<uuid>-<name>",
+// where name is the base name of the original file.
+func UniqueKey(originalName string) string {
+	return UniqueKeyWith(UUIDGenerator)(originalName)
+}
+
+// UniqueKeyWith returns the strategy of UniqueKey with the unique part generated by gen.
+func UniqueKeyWith(gen KeyGenerator) KeyStrategy {
+	return func(originalName string) string {
+		return gen.NewKey() + "-" + baseName(originalName)
+	}
+}
+
+// DatePartitioned returns the key "YYYY/MM/DD/<uuid>-<name>" partitioned by the current UTC date,
+// where name is the base name of the original file.
+// It keeps the number of objects under a single prefix small.
+func DatePartitioned(originalName string) string {
+	return DatePartitionedWith(UUIDGenerator)(originalName)
+}
+
+// DatePartitionedWith returns the strategy of DatePartitioned with the unique part generated by gen.
+func DatePartitionedWith(gen KeyGenerator) KeyStrategy {
+	unique := UniqueKeyWith(gen)
+	return func(originalName string) string {
+		return path.Join(time.Now().UTC().Format("2006/01/02"), unique(originalName))
+	}
+}
+
+// GenerateKey returns the key of the new object with the strategy set by WithKeyStrategy.
+func (i *Interactor) GenerateKey(originalName string) string {
+	return i.keyStrategy(originalName)
+}
+
+// baseName returns the last element of the file path,
+// so directories of the original name don't leak into the key.
+// Both slash and backslash are treated as separators, since browsers of some platforms send full paths.
+func baseName(name string) string {
+	name = path.Base("/" + strings.ReplaceAll(name, "\\", "/"))
+	if name == "/" {
+		return "file"
+	}
+	return name
+}
+
+// NormalizeKey returns the key without the leading slashes and with the duplicate slashes collapsed.
+// Returns ErrInvalidKey if the key is empty, longer than 1024 bytes,
+// isn't valid UTF-8 or contains control characters.
+func NormalizeKey(key string) (string, error) {
+	if !utf8.ValidString(key) {
+		return "", errors.Wrap(ErrInvalidKey, "storage.NormalizeKey: invalid utf-8")
+	}
+	for _, r := range key {
+		if r < 0x20 || r == 0x7f {
+			return "", errors.Wrap(ErrInvalidKey, "storage.NormalizeKey: control character")
+		}
+	}
+
+	for strings.Contains(key, "//") {
+		key = strings.ReplaceAll(key, "//", "/")
+	}
+	key = strings.TrimPrefix(key, "/")
+
+	if key == "" {
+		return "", errors.Wrap(ErrInvalidKey, "storage.NormalizeKey: empty key")
+	}
+	if len(key) > maxKeyLen {
+		return "", errors.Wrap(ErrInvalidKey, "storage.NormalizeKey: key is too long")
+	}
+
+	return key, nil
+}
+
+// trimKey returns the key without the leading slashes.
+// S3 keeps them as a part of the key, while the SDK cleans them from the request paths
+// and the file urls drop them, so every request trims them: "/file.txt" and "file.txt" are the same object.
+func trimKey(key string) string {
+	return strings.TrimLeft(key, "/")
+}
+
+// validateKey returns ErrInvalidKey if the key validation is enabled with WithKeyValidation
+// and the key isn't normalized.
+func (i *Interactor) validateKey(key string) error {
+	if !i.validateKeys {
+		return nil
+	}
+
+	normalized, err := NormalizeKey(key)
+	if err != nil {
+		return err
+	}
+	if normalized != key {
+		return errors.Wrapf(ErrInvalidKey, "storage: key %q isn't normalized, use %q", key, normalized)
+	}
+
+	return nil
+}
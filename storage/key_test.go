@@ -0,0 +1,236 @@
+package storage_test
+
+import (
+	"fmt"
+	"io"
+	"net/http"
+	"regexp"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/dmitrymomot/gofs/storage"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestKeyStrategy(t *testing.T) {
+	uuidPattern := `[0-9a-f]{8}-[0-9a-f]{4}-[0-9a-f]{4}-[0-9a-f]{4}-[0-9a-f]{12}`
+
+	assert.Regexp(t, regexp.MustCompile(`^`+uuidPattern+`-photo\.jpg$`), interactor.GenerateKey("photo.jpg"))
+	assert.Regexp(t, regexp.MustCompile(`^`+uuidPattern+`-photo\.jpg$`), storage.UniqueKey(`C:\Users\me\photo.jpg`))
+	assert.Regexp(t, regexp.MustCompile(`^`+uuidPattern+`-passwd$`), storage.UniqueKey("../../etc/passwd"))
+	assert.Regexp(t, regexp.MustCompile(`^\d{4}/\d{2}/\d{2}/`+uuidPattern+`-photo\.jpg$`), storage.DatePartitioned("photo.jpg"))
+
+	partitioned := storage.New(s3Client, fileStorageBucket, fileStorageUrl, storage.WithKeyStrategy(storage.DatePartitioned))
+	assert.Regexp(t, regexp.MustCompile(`^\d{4}/\d{2}/\d{2}/`+uuidPattern+`-photo\.jpg$`), partitioned.GenerateKey("photo.jpg"))
+
+	custom := storage.New(s3Client, fileStorageBucket, fileStorageUrl, storage.WithKeyStrategy(func(name string) string {
+		return "avatars/" + name
+	}))
+	assert.Equal(t, "avatars/photo.jpg", custom.GenerateKey("photo.jpg"))
+}
+
+func TestKeyGenerator(t *testing.T) {
+	n := 0
+	gen := storage.KeyGeneratorFunc(func() string {
+		n++
+		return fmt.Sprintf("key%d", n)
+	})
+
+	s := storage.New(s3Client, fileStorageBucket, fileStorageUrl, storage.WithKeyGenerator(gen))
+	assert.Equal(t, "key1-photo.jpg", s.GenerateKey("photo.jpg"))
+	assert.Equal(t, "key2-photo.jpg", s.GenerateKey("/tmp/photo.jpg"))
+
+	assert.Equal(t, "key3-photo.jpg", storage.UniqueKeyWith(gen)("photo.jpg"))
+	assert.Regexp(t, regexp.MustCompile(`^\d{4}/\d{2}/\d{2}/key4-photo\.jpg$`), storage.DatePartitionedWith(gen)("photo.jpg"))
+}
+
+func TestNormalizeKey(t *testing.T) {
+	for key, expected := range map[string]string{
+		"images/photo.jpg":       "images/photo.jpg",
+		"/images/photo.jpg":      "images/photo.jpg",
+		"//images//2024///a.jpg": "images/2024/a.jpg",
+		"images/":                "images/",
+		"фото/картинка.jpg":      "фото/картинка.jpg",
+	} {
+		normalized, err := storage.NormalizeKey(key)
+		require.NoError(t, err, key)
+		assert.Equal(t, expected, normalized, key)
+	}
+
+	for _, key := range []string{
+		"",
+		"/",
+		"images/photo\n.jpg",
+		"images/\x7fphoto.jpg",
+		"images/\xff.jpg",
+		strings.Repeat("a", 1025),
+	} {
+		_, err := storage.NormalizeKey(key)
+		assert.ErrorIs(t, err, storage.ErrInvalidKey, key)
+	}
+
+	validating := storage.New(s3Client, fileStorageBucket, fileStorageUrl, storage.WithKeyValidation())
+	assert.ErrorIs(t, validating.Upload([]byte("Hello"), "/testing//hello.txt", storage.Private, "text/plain"), storage.ErrInvalidKey)
+	_, err := validating.CreateMultipartUpload("/testing/hello.txt", "text/plain", storage.Private)
+	assert.ErrorIs(t, err, storage.ErrInvalidKey)
+}
+
+func TestFileURL(t *testing.T) {
+	client, err := storage.NewS3Client(storage.Options{
+		Key:            "key",
+		Secret:         "secret",
+		Endpoint:       "http://127.0.0.1:1",
+		Region:         "us-east-1",
+		ForcePathStyle: true,
+		DisableSSL:     true,
+	})
+	require.NoError(t, err)
+
+	for _, endpoint := range []string{"https://cdn.example.com", "https://cdn.example.com/"} {
+		s := storage.New(client, "bucket", endpoint)
+		for _, key := range []string{"dir/file.txt", "/dir/file.txt", "//dir/file.txt"} {
+			assert.Equal(t, "https://cdn.example.com/bucket/dir/file.txt", s.FileURL(key), "%s + %s", endpoint, key)
+		}
+	}
+}
+
+func TestFileURLShardedEndpoints(t *testing.T) {
+	client, err := storage.NewS3Client(storage.Options{
+		Key:            "key",
+		Secret:         "secret",
+		Endpoint:       "http://127.0.0.1:1",
+		Region:         "us-east-1",
+		ForcePathStyle: true,
+		DisableSSL:     true,
+	})
+	require.NoError(t, err)
+
+	s := storage.New(client, "bucket", "https://cdn.example.com",
+		storage.WithFileEndpoints("https://img1.cdn.com/", "", "https://img2.cdn.com"))
+	assert.Equal(t, "https://img1.cdn.com", s.Endpoint())
+
+	used := make(map[string]bool)
+	for n := 0; n < 20; n++ {
+		key := fmt.Sprintf("images/%d.jpg", n)
+		url := s.FileURL(key)
+		assert.Equal(t, url, s.FileURL("/"+key), "the same key maps to the same endpoint")
+
+		endpoint := strings.TrimSuffix(url, "/bucket/"+key)
+		assert.Contains(t, []string{"https://img1.cdn.com", "https://img2.cdn.com"}, endpoint)
+		used[endpoint] = true
+	}
+	assert.Len(t, used, 2)
+
+	// A single endpoint is used for every key.
+	s = storage.New(client, "bucket", "https://cdn.example.com", storage.WithFileEndpoints("https://img1.cdn.com"))
+	assert.Equal(t, "https://img1.cdn.com/bucket/dir/file.txt", s.FileURL("dir/file.txt"))
+}
+
+func TestKeySlashes(t *testing.T) {
+	var (
+		mu    sync.Mutex
+		paths []string
+	)
+	s := newLocalInteractor(t, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		paths = append(paths, r.Method+" "+r.URL.Path)
+		mu.Unlock()
+		w.Header().Set("ETag", `"etag"`)
+		if r.Method == http.MethodPost && r.URL.Query().Has("uploads") {
+			fmt.Fprint(w, `<InitiateMultipartUploadResult><UploadId>upload-id</UploadId></InitiateMultipartUploadResult>`)
+		}
+		if r.Method == http.MethodPost && r.URL.Query().Has("uploadId") {
+			fmt.Fprint(w, `<CompleteMultipartUploadResult><ETag>"etag"</ETag></CompleteMultipartUploadResult>`)
+		}
+	}))
+
+	// The keys are requested without the leading slashes, as FileURL links to them.
+	key := "/dir/file.txt"
+	require.NoError(t, s.Upload([]byte("Hello, World!"), key, storage.Private, "text/plain"))
+	assert.Equal(t, "/dir/file.txt", key)
+
+	uploadID, err := s.CreateMultipartUpload("/dir/big.bin", "application/octet-stream", storage.Private)
+	require.NoError(t, err)
+	part, err := s.UploadPart("/dir/big.bin", uploadID, []byte("content"), 1, 1)
+	require.NoError(t, err)
+	require.NoError(t, s.CompleteMultipartUpload("/dir/big.bin", uploadID, part))
+
+	assert.Equal(t, []string{
+		"PUT /bucket/dir/file.txt",
+		"POST /bucket/dir/big.bin",
+		"PUT /bucket/dir/big.bin",
+		"POST /bucket/dir/big.bin",
+	}, paths)
+}
+
+func TestKeySlashesRoundTrip(t *testing.T) {
+	var (
+		mu          sync.Mutex
+		objects     = map[string][]byte{}
+		sizes       = map[string]int64{"/bucket/large.bin": storage.MaxPartSize + 1}
+		copySources []string
+		completed   []string
+	)
+	s := newLocalInteractor(t, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		defer mu.Unlock()
+
+		query := r.URL.Query()
+		switch {
+		case r.Method == http.MethodPost && query.Has("uploads"):
+			fmt.Fprint(w, `<InitiateMultipartUploadResult><UploadId>upload-id</UploadId></InitiateMultipartUploadResult>`)
+		case r.Method == http.MethodPost && query.Has("uploadId"):
+			completed = append(completed, r.URL.Path)
+			fmt.Fprint(w, `<CompleteMultipartUploadResult><ETag>"etag"</ETag></CompleteMultipartUploadResult>`)
+		case r.Method == http.MethodPut && r.Header.Get("X-Amz-Copy-Source") != "":
+			copySources = append(copySources, r.Header.Get("X-Amz-Copy-Source"))
+			fmt.Fprint(w, `<CopyPartResult><ETag>"etag"</ETag></CopyPartResult>`)
+		case r.Method == http.MethodPut:
+			body, _ := io.ReadAll(r.Body)
+			objects[r.URL.Path] = body
+			w.Header().Set("ETag", `"etag"`)
+		case r.Method == http.MethodHead:
+			size, ok := sizes[r.URL.Path]
+			if content, exists := objects[r.URL.Path]; exists {
+				size, ok = int64(len(content)), true
+			}
+			if !ok {
+				w.WriteHeader(http.StatusNotFound)
+				return
+			}
+			w.Header().Set("Content-Length", strconv.FormatInt(size, 10))
+		case r.Method == http.MethodGet:
+			content, ok := objects[r.URL.Path]
+			if !ok {
+				w.WriteHeader(http.StatusNotFound)
+				return
+			}
+			w.Write(content)
+		}
+	}))
+
+	require.NoError(t, s.Upload([]byte("Hello"), "/key", storage.Private, "text/plain"))
+
+	info, err := s.Stat("/key")
+	require.NoError(t, err)
+	assert.EqualValues(t, 5, info.ContentLength)
+
+	require.NoError(t, s.AppendData("/key", []byte(", World!")))
+
+	body, _, err := s.Download("/key")
+	require.NoError(t, err)
+	defer body.Close()
+	content, err := io.ReadAll(body)
+	require.NoError(t, err)
+	assert.Equal(t, "Hello, World!", string(content))
+
+	require.NoError(t, s.CopyLarge("/large.bin", "/dst.bin", storage.Private))
+	assert.Equal(t, []string{"/bucket/dst.bin"}, completed)
+	require.NotEmpty(t, copySources)
+	for _, source := range copySources {
+		assert.Equal(t, "bucket/large.bin", source)
+	}
+}
@@ -0,0 +1,158 @@
+package storage
+
+import (
+	"context"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/s3"
+	"github.com/pkg/errors"
+	"golang.org/x/sync/errgroup"
+)
+
+// defaultHydrateConcurrency is the number of concurrent HEAD requests
+// of the metadata hydration if ListOptions.Concurrency isn't set.
+const defaultHydrateConcurrency = 8
+
+// ListOptions are the options of List.
+type ListOptions struct {
+	// HydrateMetadata fills the content type, the content encoding, the expiration
+	// and the user metadata of the listed objects, which the listing doesn't return.
+	// It costs a HEAD request per object.
+	HydrateMetadata bool
+
+	// Concurrency limits the number of concurrent HEAD requests of the hydration, 8 by default.
+	Concurrency int
+
+	// StartAfter lists the objects with the keys after the given one in the lexicographical order,
+	// e.g. the last key of the previous page of a key-based cursor.
+	// It applies to the first request only: the following requests of the listing
+	// are made with the continuation tokens, which take precedence over it, so they are mutually exclusive.
+	StartAfter string
+}
+
+// List returns the info of the objects with the given prefix.
+// The listing provides the key, the size, the ETag, the last modification time
+// and the storage class of the objects, set HydrateMetadata to get the rest of the info.
+func (i *Interactor) List(prefix string, opts ListOptions) ([]ObjectInfo, error) {
+	return i.ListWithContext(context.Background(), prefix, opts)
+}
+
+// ListWithContext is List which cancels the requests when ctx is done.
+// Objects deleted between the listing and the hydration are returned as listed.
+func (i *Interactor) ListWithContext(ctx context.Context, prefix string, opts ListOptions) ([]ObjectInfo, error) {
+	var infos []ObjectInfo
+	if err := i.walkWithContext(ctx, prefix, opts.StartAfter, func(objects []*s3.Object) error {
+		for _, obj := range objects {
+			storageClass := aws.StringValue(obj.StorageClass)
+			if storageClass == s3.StorageClassStandard {
+				storageClass = ""
+			}
+			infos = append(infos, ObjectInfo{
+				Key:           aws.StringValue(obj.Key),
+				ContentLength: aws.Int64Value(obj.Size),
+				ETag:          aws.StringValue(obj.ETag),
+				LastModified:  aws.TimeValue(obj.LastModified),
+				StorageClass:  storageClass,
+			})
+		}
+		return nil
+	}); err != nil {
+		return nil, errors.Wrap(err, "storage.list")
+	}
+
+	if opts.HydrateMetadata {
+		if err := i.hydrate(ctx, infos, opts.Concurrency); err != nil {
+			return nil, errors.Wrap(err, "storage.list")
+		}
+	}
+
+	return infos, nil
+}
+
+// StorageUsage returns the total size in bytes and the number of the objects with the given prefix,
+// e.g. to enforce the per-tenant quota. The objects are listed page by page,
+// so it takes a request per 1000 objects; use StorageUsageWithContext to limit the time.
+func (i *Interactor) StorageUsage(prefix string) (totalBytes, objectCount int64, err error) {
+	return i.StorageUsageWithContext(context.Background(), prefix)
+}
+
+// StorageUsageWithContext is StorageUsage which cancels the listing when ctx is done.
+func (i *Interactor) StorageUsageWithContext(ctx context.Context, prefix string) (totalBytes, objectCount int64, err error) {
+	if err := i.walkWithContext(ctx, prefix, "", func(objects []*s3.Object) error {
+		for _, obj := range objects {
+			totalBytes += aws.Int64Value(obj.Size)
+		}
+		objectCount += int64(len(objects))
+		return nil
+	}); err != nil {
+		return 0, 0, errors.Wrap(err, "storage.storageUsage")
+	}
+
+	return totalBytes, objectCount, nil
+}
+
+// hydrate fills the infos with the metadata returned by HEAD requests,
+// at most concurrency at a time.
+func (i *Interactor) hydrate(ctx context.Context, infos []ObjectInfo, concurrency int) error {
+	if concurrency < 1 {
+		concurrency = defaultHydrateConcurrency
+	}
+
+	g, gctx := errgroup.WithContext(ctx)
+	g.SetLimit(concurrency)
+	for n := range infos {
+		info := &infos[n]
+		g.Go(func() error {
+			head, err := i.stat(gctx, info.Key)
+			if err != nil {
+				if errors.Is(err, ErrObjectNotFound) {
+					return nil
+				}
+				return err
+			}
+
+			info.ContentType = head.ContentType
+			info.ContentEncoding = head.ContentEncoding
+			info.Expires = head.Expires
+			info.Metadata = head.Metadata
+			return nil
+		})
+	}
+
+	return g.Wait()
+}
+
+// walk calls fn for every page of the objects with the given prefix.
+// Walking stops at the first error returned by fn.
+func (i *Interactor) walk(prefix string, fn func(objects []*s3.Object) error) error {
+	return i.walkWithContext(context.Background(), prefix, "", fn)
+}
+
+// walkWithContext is walk which cancels the requests when ctx is done
+// and starts after the given key if it isn't empty.
+func (i *Interactor) walkWithContext(ctx context.Context, prefix, startAfter string, fn func(objects []*s3.Object) error) error {
+	input := &s3.ListObjectsV2Input{
+		Bucket:       aws.String(i.bucket),
+		RequestPayer: i.requestPayer(),
+		Prefix:       aws.String(prefix),
+	}
+	if startAfter != "" {
+		input.StartAfter = aws.String(startAfter)
+	}
+	if err := input.Validate(); err != nil {
+		return err
+	}
+
+	var fnErr error
+	if err := i.s3.ListObjectsV2PagesWithContext(ctx, input, func(page *s3.ListObjectsV2Output, _ bool) bool {
+		if len(page.Contents) == 0 {
+			return true
+		}
+		fnErr = fn(page.Contents)
+		return fnErr == nil
+	}); err != nil {
+		return err
+	}
+
+	return fnErr
+}
@@ -0,0 +1,79 @@
+package storage_test
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"strings"
+	"sync/atomic"
+	"testing"
+
+	"github.com/dmitrymomot/gofs/storage"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestList(t *testing.T) {
+	var heads atomic.Int32
+	s := newLocalInteractor(t, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.Method {
+		case http.MethodGet:
+			contents := `<Contents><Key>docs/a.txt</Key><Size>3</Size><ETag>"etag-a"</ETag><StorageClass>STANDARD</StorageClass></Contents>`
+			if r.URL.Query().Get("start-after") == "docs/a.txt" {
+				contents = ""
+			}
+			fmt.Fprint(w, `<?xml version="1.0" encoding="UTF-8"?>
+<ListBucketResult xmlns="http://s3.amazonaws.com/doc/2006-03-01/">
+	<Name>bucket</Name>
+	<Prefix>docs/</Prefix>
+	<IsTruncated>false</IsTruncated>
+	`+contents+`
+	<Contents><Key>docs/b.txt</Key><Size>5</Size><ETag>"etag-b"</ETag><StorageClass>GLACIER</StorageClass></Contents>
+</ListBucketResult>`)
+		case http.MethodHead:
+			heads.Add(1)
+			if strings.HasSuffix(r.URL.Path, "/b.txt") {
+				// Deleted after the listing.
+				w.WriteHeader(http.StatusNotFound)
+				return
+			}
+			w.Header().Set("Content-Type", "text/plain")
+			w.Header().Set("X-Amz-Meta-Owner", "alice")
+		}
+	}))
+
+	infos, err := s.List("docs/", storage.ListOptions{})
+	require.NoError(t, err)
+	require.Len(t, infos, 2)
+	assert.Equal(t, "docs/a.txt", infos[0].Key)
+	assert.Equal(t, int64(3), infos[0].ContentLength)
+	assert.Equal(t, `"etag-a"`, infos[0].ETag)
+	assert.Empty(t, infos[0].StorageClass)
+	assert.Empty(t, infos[0].ContentType)
+	assert.Equal(t, "GLACIER", infos[1].StorageClass)
+	assert.Zero(t, heads.Load())
+
+	infos, err = s.List("docs/", storage.ListOptions{StartAfter: "docs/a.txt"})
+	require.NoError(t, err)
+	require.Len(t, infos, 1)
+	assert.Equal(t, "docs/b.txt", infos[0].Key)
+
+	totalBytes, objectCount, err := s.StorageUsage("docs/")
+	require.NoError(t, err)
+	assert.Equal(t, int64(8), totalBytes)
+	assert.Equal(t, int64(2), objectCount)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	_, _, err = s.StorageUsageWithContext(ctx, "docs/")
+	assert.Error(t, err)
+
+	infos, err = s.List("docs/", storage.ListOptions{HydrateMetadata: true, Concurrency: 2})
+	require.NoError(t, err)
+	require.Len(t, infos, 2)
+	assert.Equal(t, "text/plain", infos[0].ContentType)
+	assert.Equal(t, map[string]string{"owner": "alice"}, infos[0].Metadata)
+	assert.Empty(t, infos[1].ContentType)
+	assert.Equal(t, int64(5), infos[1].ContentLength)
+	assert.Equal(t, int32(2), heads.Load())
+}
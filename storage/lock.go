@@ -0,0 +1,87 @@
+package storage
+
+import (
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/s3"
+	"github.com/pkg/errors"
+)
+
+// Predefined object lock retention modes
+const (
+	Governance ObjectLockMode = s3.ObjectLockModeGovernance
+	Compliance ObjectLockMode = s3.ObjectLockModeCompliance
+)
+
+// ObjectLockMode is the retention mode of the locked object.
+// Objects in the governance mode can be deleted by users with a special permission,
+// objects in the compliance mode can't be deleted by anyone until the retention date.
+type ObjectLockMode string
+
+// String returns the string representation of the object lock mode.
+func (m ObjectLockMode) String() string {
+	return string(m)
+}
+
+// SetLegalHold turns the legal hold of the existing object on or off.
+// The object under the legal hold can't be deleted or overwritten regardless of its retention,
+// until the hold is turned off.
+// Object lock must be enabled for the bucket, otherwise the storage rejects the request.
+// Returns ErrObjectNotFound if there is no object with the given path.
+func (i *Interactor) SetLegalHold(filepath string, on bool) error {
+	status := s3.ObjectLockLegalHoldStatusOff
+	if on {
+		status = s3.ObjectLockLegalHoldStatusOn
+	}
+
+	input := &s3.PutObjectLegalHoldInput{
+		Bucket:       aws.String(i.bucket),
+		RequestPayer: i.requestPayer(),
+		Key:          aws.String(trimKey(filepath)),
+		LegalHold:    &s3.ObjectLockLegalHold{Status: aws.String(status)},
+	}
+	if err := input.Validate(); err != nil {
+		return errors.Wrap(err, "storage.setLegalHold")
+	}
+
+	if _, err := i.s3.PutObjectLegalHold(input); err != nil {
+		i.logError("storage: set legal hold of %s: %v", filepath, err)
+		if isNotFound(err) {
+			return ErrObjectNotFound
+		}
+		return errors.Wrap(err, "storage.setLegalHold")
+	}
+
+	return nil
+}
+
+// GetLegalHold reports whether the object is under the legal hold.
+// Object lock must be enabled for the bucket, otherwise the storage rejects the request.
+// Returns ErrObjectNotFound if there is no object with the given path.
+func (i *Interactor) GetLegalHold(filepath string) (bool, error) {
+	input := &s3.GetObjectLegalHoldInput{
+		Bucket:       aws.String(i.bucket),
+		RequestPayer: i.requestPayer(),
+		Key:          aws.String(trimKey(filepath)),
+	}
+	if err := input.Validate(); err != nil {
+		return false, errors.Wrap(err, "storage.getLegalHold")
+	}
+
+	output, err := i.s3.GetObjectLegalHold(input)
+	if err != nil {
+		// The hold was never set on the object, the storage responds with 404 as well.
+		if hasCode(err, "NoSuchObjectLockConfiguration") {
+			return false, nil
+		}
+		if isNotFound(err) {
+			return false, ErrObjectNotFound
+		}
+		i.logError("storage: get legal hold of %s: %v", filepath, err)
+		return false, errors.Wrap(err, "storage.getLegalHold")
+	}
+	if output.LegalHold == nil {
+		return false, nil
+	}
+
+	return aws.StringValue(output.LegalHold.Status) == s3.ObjectLockLegalHoldStatusOn, nil
+}
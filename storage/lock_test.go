@@ -0,0 +1,66 @@
+package storage_test
+
+import (
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/dmitrymomot/gofs/storage"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestLegalHold(t *testing.T) {
+	var (
+		mu    sync.Mutex
+		holds = map[string]string{}
+	)
+	s := newLocalInteractor(t, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		defer mu.Unlock()
+
+		if r.URL.Path == "/bucket/missing.pdf" {
+			w.WriteHeader(http.StatusNotFound)
+			fmt.Fprint(w, `<Error><Code>NoSuchKey</Code></Error>`)
+			return
+		}
+
+		switch r.Method {
+		case http.MethodPut:
+			body, _ := io.ReadAll(r.Body)
+			holds[r.URL.Path] = "OFF"
+			if strings.Contains(string(body), "<Status>ON</Status>") {
+				holds[r.URL.Path] = "ON"
+			}
+		case http.MethodGet:
+			status, ok := holds[r.URL.Path]
+			if !ok {
+				w.WriteHeader(http.StatusNotFound)
+				fmt.Fprint(w, `<Error><Code>NoSuchObjectLockConfiguration</Code></Error>`)
+				return
+			}
+			fmt.Fprintf(w, `<LegalHold><Status>%s</Status></LegalHold>`, status)
+		}
+	}))
+
+	on, err := s.GetLegalHold("contract.pdf")
+	require.NoError(t, err)
+	assert.False(t, on, "the hold was never set")
+
+	require.NoError(t, s.SetLegalHold("contract.pdf", true))
+	on, err = s.GetLegalHold("contract.pdf")
+	require.NoError(t, err)
+	assert.True(t, on)
+
+	require.NoError(t, s.SetLegalHold("contract.pdf", false))
+	on, err = s.GetLegalHold("contract.pdf")
+	require.NoError(t, err)
+	assert.False(t, on)
+
+	assert.ErrorIs(t, s.SetLegalHold("missing.pdf", true), storage.ErrObjectNotFound)
+	_, err = s.GetLegalHold("missing.pdf")
+	assert.ErrorIs(t, err, storage.ErrObjectNotFound)
+}
@@ -0,0 +1,14 @@
+package storage
+
+// Logger is the interface of the interactor logger.
+// It's compatible with most of the logging libraries, e.g. logrus.
+type Logger interface {
+	Debugf(format string, args ...interface{})
+	Errorf(format string, args ...interface{})
+}
+
+// noopLogger discards all messages, it's the default logger.
+type noopLogger struct{}
+
+func (noopLogger) Debugf(string, ...interface{}) {}
+func (noopLogger) Errorf(string, ...interface{}) {}
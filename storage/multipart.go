@@ -0,0 +1,107 @@
+package storage
+
+import (
+	"sort"
+	"time"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/s3"
+	"github.com/pkg/errors"
+)
+
+// MultipartUpload describes an in-progress multipart upload.
+type MultipartUpload struct {
+	Key       string
+	UploadID  string
+	Initiated time.Time
+}
+
+// PartReport describes an uploaded part of the multipart upload and its position in the assembled object.
+// The byte range is inclusive, like the one of the Content-Range header.
+type PartReport struct {
+	PartNumber  int64
+	Size        int64
+	ETag        string
+	StartOffset int64
+	EndOffset   int64
+}
+
+// PartsReport returns the uploaded parts of the multipart upload sorted by the part number,
+// with the byte ranges they take in the assembled object, e.g. to verify the structure
+// of the upload before completing it. S3 discards the parts list once the upload is completed.
+// The ranges assume the parts are completed as listed, a missed part shifts the following ones.
+func (i *Interactor) PartsReport(filename, uploadID string) ([]PartReport, error) {
+	if uploadID == "" {
+		return nil, ErrMissedUploadID
+	}
+
+	input := &s3.ListPartsInput{
+		Bucket:       aws.String(i.bucket),
+		RequestPayer: i.requestPayer(),
+		Key:          aws.String(trimKey(filename)),
+		UploadId:     aws.String(uploadID),
+	}
+	if err := input.Validate(); err != nil {
+		return nil, errors.Wrap(err, "storage.partsReport")
+	}
+
+	var parts []PartReport
+	if err := i.s3.ListPartsPages(input, func(page *s3.ListPartsOutput, _ bool) bool {
+		for _, p := range page.Parts {
+			parts = append(parts, PartReport{
+				PartNumber: aws.Int64Value(p.PartNumber),
+				Size:       aws.Int64Value(p.Size),
+				ETag:       aws.StringValue(p.ETag),
+			})
+		}
+		return true
+	}); err != nil {
+		i.logError("storage: list parts of %s (%s): %v", filename, uploadID, err)
+		return nil, errors.Wrap(err, "storage.partsReport")
+	}
+
+	sort.Slice(parts, func(a, b int) bool {
+		return parts[a].PartNumber < parts[b].PartNumber
+	})
+	var offset int64
+	for n := range parts {
+		parts[n].StartOffset = offset
+		offset += parts[n].Size
+		parts[n].EndOffset = offset - 1
+	}
+
+	return parts, nil
+}
+
+// ListMultipartUploads returns the in-progress multipart uploads of the keys with the given prefix,
+// the empty prefix matches all the uploads in the bucket.
+// The uploads are never completed or aborted by S3 itself (unless a lifecycle rule is set),
+// and their parts are billed as the stored data.
+func (i *Interactor) ListMultipartUploads(prefix string) ([]MultipartUpload, error) {
+	input := &s3.ListMultipartUploadsInput{
+		Bucket: aws.String(i.bucket),
+	}
+	if prefix != "" {
+		input.Prefix = aws.String(prefix)
+	}
+	if err := input.Validate(); err != nil {
+		return nil, errors.Wrap(err, "storage.listMultipartUploads")
+	}
+
+	var uploads []MultipartUpload
+	if err := i.s3.ListMultipartUploadsPagesWithContext(aws.BackgroundContext(), input, func(page *s3.ListMultipartUploadsOutput, _ bool) bool {
+		for _, u := range page.Uploads {
+			uploads = append(uploads, MultipartUpload{
+				Key:       aws.StringValue(u.Key),
+				UploadID:  aws.StringValue(u.UploadId),
+				Initiated: aws.TimeValue(u.Initiated),
+			})
+		}
+		return true
+	}, i.requestPayerHeader()...); err != nil {
+		i.logError("storage: list multipart uploads %s: %v", prefix, err)
+		return nil, errors.Wrap(err, "storage.listMultipartUploads")
+	}
+
+	return uploads, nil
+}
@@ -1,5 +1,16 @@
 package storage
 
+import (
+	"io"
+	"strings"
+	"time"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/request"
+	"github.com/aws/aws-sdk-go/service/s3"
+	"github.com/pkg/errors"
+)
+
 // Options struct
 type Options struct {
 	Key            string
@@ -8,4 +19,500 @@ type Options struct {
 	Region         string
 	ForcePathStyle bool
 	DisableSSL     bool
+
+	// Provider fills in the defaults of the storage provider,
+	// the fields set explicitly take precedence over them.
+	// Empty means no defaults.
+	Provider Provider
+
+	// Anonymous makes the requests unsigned, so the public buckets can be read
+	// without credentials; Key and Secret are ignored.
+	// Requests to the private objects fail with access denied.
+	Anonymous bool
+}
+
+// Option is a functional option of the storage interactor.
+type Option func(*Interactor)
+
+// WithMultipartThreshold sets the file size starting from which PutFile switches to the multipart upload.
+// Default is DefaultMultipartThreshold.
+func WithMultipartThreshold(size int64) Option {
+	return func(i *Interactor) {
+		if size > 0 {
+			i.multipartThreshold = size
+		}
+	}
+}
+
+// WithMaxParts sets the max number of parts of the multipart upload.
+// Default is MaxParts, the AWS S3 limit; some S3-compatible storages have other limits.
+func WithMaxParts(n int64) Option {
+	return func(i *Interactor) {
+		if n > 0 {
+			i.maxParts = n
+		}
+	}
+}
+
+// WithMinPartSize sets the min size of the multipart upload part, except the last one.
+// Default is MinPartSize, the AWS S3 limit; some S3-compatible storages have other limits.
+func WithMinPartSize(size int64) Option {
+	return func(i *Interactor) {
+		if size > 0 {
+			i.minPartSize = size
+		}
+	}
+}
+
+// WithMaxPartSize sets the max size of the multipart upload part.
+// Default is MaxPartSize, the AWS S3 limit; some S3-compatible storages have other limits.
+func WithMaxPartSize(size int64) Option {
+	return func(i *Interactor) {
+		if size > 0 {
+			i.maxPartSize = size
+		}
+	}
+}
+
+// WithClientEncryption enables the client-side encryption with AES-GCM:
+// Upload encrypts the content before sending it and Download decrypts it transparently.
+// The key ID and the nonce are stored in the object metadata.
+// Since AES-GCM authenticates the content as a whole, it's applied to single request uploads only:
+// the multipart uploads fail with ErrEncryptionUnsupported, so do PutFile, UploadFileHandle
+// and UploadAndHash once the content exceeds the multipart threshold.
+// Ranged reads (OpenReader) and presigned urls work with the raw content.
+func WithClientEncryption(keys KeyProvider) Option {
+	return func(i *Interactor) {
+		i.keys = keys
+	}
+}
+
+// WithDryRun enables the dry-run mode of the destructive operations:
+// Delete, DeleteVersion, DeleteMany, DeletePrefix and AbortMultipartUpload
+// log what would be removed (with the Debugf level) and report it as removed
+// without issuing the requests. The cleanup of the interactor's own operations,
+// e.g. aborting the failed uploads or deleting the temporary objects, isn't affected.
+func WithDryRun() Option {
+	return func(i *Interactor) {
+		i.dryRun = true
+	}
+}
+
+// WithContentIndex sets the index used by PutContentAddressed to find already stored content.
+// By default the existence is checked with a HEAD request of the hash-derived key.
+func WithContentIndex(idx ContentIndex) Option {
+	return func(i *Interactor) {
+		i.contentIndex = idx
+	}
+}
+
+// WithRequesterPays makes the requester pay for the requests to requester-pays buckets,
+// which reject anonymous-payer requests with 403.
+// It applies to every request to the objects of the bucket: the uploads, the multipart uploads,
+// the downloads, the copies, the deletes and the selects, as well as to the listings.
+// The bucket configuration requests of Capabilities are sent without it, only the owner can make them.
+func WithRequesterPays() Option {
+	return func(i *Interactor) {
+		i.requesterPays = true
+	}
+}
+
+// WithKeyStrategy sets the strategy GenerateKey uses to name the objects.
+// Default is UniqueKey with the generator set by WithKeyGenerator.
+func WithKeyStrategy(s KeyStrategy) Option {
+	return func(i *Interactor) {
+		if s != nil {
+			i.keyStrategy = s
+		}
+	}
+}
+
+// WithKeyGenerator sets the generator of the unique part of the keys built by the default strategy,
+// e.g. a deterministic one in tests. A strategy set by WithKeyStrategy is used as is,
+// pass it the generator explicitly, e.g. DatePartitionedWith(gen).
+// Default is UUIDGenerator.
+func WithKeyGenerator(gen KeyGenerator) Option {
+	return func(i *Interactor) {
+		if gen != nil {
+			i.keyGenerator = gen
+		}
+	}
+}
+
+// WithKeyValidation makes the uploads fail early with ErrInvalidKey
+// if the key isn't valid or isn't normalized by NormalizeKey,
+// e.g. it has a leading slash or duplicate slashes.
+// It applies to Upload and its variants, PutFile and the multipart uploads.
+func WithKeyValidation() Option {
+	return func(i *Interactor) {
+		i.validateKeys = true
+	}
+}
+
+// WithDefaultTimeout limits the time every request to the storage waits for the response,
+// including the methods without a context. If the caller's context has a sooner deadline, it wins.
+// Reading a downloaded object isn't limited once the response arrived.
+// The timed out requests fail with the canceled request error.
+// By default there is no timeout.
+func WithDefaultTimeout(timeout time.Duration) Option {
+	return func(i *Interactor) {
+		i.defaultTimeout = timeout
+	}
+}
+
+// WithDefaultACL sets the ACL of the objects uploaded with the methods which don't take it,
+// e.g. UploadWithDefaultACL. If the ACL is invalid, these methods fail with ErrInvalidACL.
+// Default is Private.
+func WithDefaultACL(acl ACL) Option {
+	return func(i *Interactor) {
+		if !acl.IsValid() {
+			i.defaultACLErr = errors.Wrapf(ErrInvalidACL, "default acl %q", acl)
+			return
+		}
+		i.defaultACL = acl
+		i.defaultACLErr = nil
+	}
+}
+
+// WithFileEndpoints sets the public file endpoints used to build file urls instead of the one passed to New,
+// e.g. the sharded CDN domains "https://img1.cdn.com" and "https://img2.cdn.com"
+// to increase the number of parallel browser downloads.
+// FileURL picks the endpoint by the hash of the key, so the url of the key is always the same.
+// The order of the endpoints matters: changing it or the number of endpoints remaps the keys.
+// Endpoint returns the first one. Empty endpoints are ignored.
+func WithFileEndpoints(endpoints ...string) Option {
+	return func(i *Interactor) {
+		var trimmed []string
+		for _, endpoint := range endpoints {
+			if endpoint = strings.TrimRight(endpoint, "/"); endpoint != "" {
+				trimmed = append(trimmed, endpoint)
+			}
+		}
+		if len(trimmed) > 0 {
+			i.fileEndpoint = trimmed[0]
+			i.fileEndpoints = trimmed
+		}
+	}
+}
+
+// WithLogger sets the logger to report failed operations and multipart upload progress.
+// By default nothing is logged.
+func WithLogger(l Logger) Option {
+	return func(i *Interactor) {
+		if l != nil {
+			i.logger = l
+		}
+	}
+}
+
+// CompleteOptions holds optional parameters of the multipart upload completion.
+type CompleteOptions struct {
+	// PreSorted skips sorting the parts by the part number,
+	// e.g. if they are listed with ListParts. The storage rejects the unsorted parts.
+	PreSorted bool
+}
+
+// UploadOptions holds optional parameters of the object being uploaded.
+// Empty fields are not sent to the storage.
+type UploadOptions struct {
+	ACL         ACL
+	ContentType string
+
+	// ContentEncoding is served as the Content-Encoding header,
+	// e.g. "gzip" for pre-compressed objects.
+	ContentEncoding string
+
+	// ContentLanguage is served as the Content-Language header, e.g. "en-US".
+	ContentLanguage string
+
+	// Expires is served as the Expires header, the time the cached object becomes stale.
+	// It's a caching hint for the clients, the object isn't deleted.
+	Expires time.Time
+
+	// Object lock (WORM) settings.
+	// They are applied only if object lock is enabled for the bucket,
+	// otherwise the storage rejects the upload.
+	// ObjectLockMode and RetainUntilDate must be set together, the date must be in the future.
+	ObjectLockMode  ObjectLockMode
+	RetainUntilDate time.Time
+	LegalHold       bool
+
+	// Encryption is the server-side encryption of the object.
+	Encryption Encryption
+
+	// Metadata is the user-defined object metadata.
+	Metadata map[string]string
+
+	// ContentMD5 is the precomputed base64-encoded MD5 of the content,
+	// the storage rejects the upload if the received content doesn't match it.
+	// It's applied to single request uploads only.
+	ContentMD5 string
+
+	// StripEXIF removes the EXIF and XMP metadata, e.g. the GPS location,
+	// from the file before storing it if the file is a JPEG image.
+	// Other files are stored untouched.
+	// It's applied to single request uploads only.
+	StripEXIF bool
+
+	// AllowEmpty allows uploading empty files, e.g. markers,
+	// otherwise the upload of an empty file fails with ErrFileEmpty.
+	AllowEmpty bool
+
+	// OnConflict is the strategy of the upload to the key of an existing object,
+	// the existing object is overwritten by default. Other strategies cost an extra HEAD request,
+	// ConflictRename may cost a few of them. The final key is returned in the UploadResult.
+	// It's applied to single request uploads only.
+	OnConflict ConflictStrategy
+
+	// ChecksumAlgorithm is the algorithm of the additional checksum computed before the upload:
+	// the storage rejects the content which doesn't match it with ErrChecksumMismatch
+	// and stores it along with the object. The checksum returned by the storage is verified as well.
+	// Providers which don't support the additional checksums get the upload without it.
+	// The multipart uploads fail with ErrChecksumUnsupported.
+	ChecksumAlgorithm ChecksumAlgorithm
+
+	// WebsiteRedirectLocation makes the object redirect the requests to the bucket website endpoint
+	// to the given absolute URL or path of another object in the bucket, e.g. for short links.
+	// The redirect is ignored by the REST API and the presigned urls.
+	WebsiteRedirectLocation string
+
+	// Headers are the extra request headers, e.g. the provider-specific x-amz-* or x-goog-* headers
+	// not modeled by the options. They are sent as is and override the headers set by the options.
+	// Multipart uploads send them with the initiating request only, the parts have their own.
+	// Whether they are honored or rejected depends on the provider.
+	Headers map[string]string
+}
+
+// validate checks the consistency of the options.
+func (o UploadOptions) validate() error {
+	if o.ObjectLockMode != "" || !o.RetainUntilDate.IsZero() {
+		if o.ObjectLockMode == "" || !o.RetainUntilDate.After(time.Now()) {
+			return ErrInvalidRetention
+		}
+	}
+
+	if err := o.OnConflict.validate(); err != nil {
+		return err
+	}
+	if err := o.ChecksumAlgorithm.validate(); err != nil {
+		return err
+	}
+	if o.WebsiteRedirectLocation != "" {
+		if err := validateRedirectLocation(o.WebsiteRedirectLocation); err != nil {
+			return err
+		}
+	}
+
+	return o.Encryption.validate()
+}
+
+// hasObjectLock reports whether the options contain object lock settings.
+func (o UploadOptions) hasObjectLock() bool {
+	return o.ObjectLockMode != "" || o.LegalHold
+}
+
+// putObjectInput builds the s3.PutObjectInput for the given object.
+func (o UploadOptions) putObjectInput(bucket, key string, body io.ReadSeeker) *s3.PutObjectInput {
+	input := &s3.PutObjectInput{
+		Bucket: aws.String(bucket),
+		Key:    aws.String(trimKey(key)),
+		Body:   body,
+	}
+	if o.ContentMD5 != "" {
+		input.ContentMD5 = aws.String(o.ContentMD5)
+	}
+	if len(o.Metadata) > 0 {
+		input.Metadata = aws.StringMap(o.Metadata)
+	}
+	if o.ACL != "" {
+		input.ACL = aws.String(o.ACL.String())
+	}
+	if o.ContentType != "" {
+		input.ContentType = aws.String(o.ContentType)
+	}
+	if o.ContentEncoding != "" {
+		input.ContentEncoding = aws.String(o.ContentEncoding)
+	}
+	if o.ContentLanguage != "" {
+		input.ContentLanguage = aws.String(o.ContentLanguage)
+	}
+	if !o.Expires.IsZero() {
+		input.Expires = aws.Time(o.Expires)
+	}
+	if o.ObjectLockMode != "" {
+		input.ObjectLockMode = aws.String(o.ObjectLockMode.String())
+		input.ObjectLockRetainUntilDate = aws.Time(o.RetainUntilDate)
+	}
+	if o.LegalHold {
+		input.ObjectLockLegalHoldStatus = aws.String(s3.ObjectLockLegalHoldStatusOn)
+	}
+	if o.Encryption.Algorithm != "" {
+		input.ServerSideEncryption = aws.String(o.Encryption.Algorithm)
+	}
+	if o.Encryption.KMSKeyID != "" {
+		input.SSEKMSKeyId = aws.String(o.Encryption.KMSKeyID)
+	}
+	if len(o.Encryption.KMSContext) > 0 {
+		input.SSEKMSEncryptionContext = aws.String(o.Encryption.kmsContext())
+	}
+	if o.WebsiteRedirectLocation != "" {
+		input.WebsiteRedirectLocation = aws.String(o.WebsiteRedirectLocation)
+	}
+
+	return input
+}
+
+// createMultipartUploadInput builds the s3.CreateMultipartUploadInput for the given object.
+// The headers are stored once at the multipart initialization and apply to the assembled object.
+func (o UploadOptions) createMultipartUploadInput(bucket, key string) *s3.CreateMultipartUploadInput {
+	input := &s3.CreateMultipartUploadInput{
+		Bucket: aws.String(bucket),
+		Key:    aws.String(trimKey(key)),
+	}
+	if len(o.Metadata) > 0 {
+		input.Metadata = aws.StringMap(o.Metadata)
+	}
+	if o.ACL != "" {
+		input.ACL = aws.String(o.ACL.String())
+	}
+	if o.ContentType != "" {
+		input.ContentType = aws.String(o.ContentType)
+	}
+	if o.ContentEncoding != "" {
+		input.ContentEncoding = aws.String(o.ContentEncoding)
+	}
+	if o.ContentLanguage != "" {
+		input.ContentLanguage = aws.String(o.ContentLanguage)
+	}
+	if !o.Expires.IsZero() {
+		input.Expires = aws.Time(o.Expires)
+	}
+	if o.ObjectLockMode != "" {
+		input.ObjectLockMode = aws.String(o.ObjectLockMode.String())
+		input.ObjectLockRetainUntilDate = aws.Time(o.RetainUntilDate)
+	}
+	if o.LegalHold {
+		input.ObjectLockLegalHoldStatus = aws.String(s3.ObjectLockLegalHoldStatusOn)
+	}
+	if o.Encryption.Algorithm != "" {
+		input.ServerSideEncryption = aws.String(o.Encryption.Algorithm)
+	}
+	if o.Encryption.KMSKeyID != "" {
+		input.SSEKMSKeyId = aws.String(o.Encryption.KMSKeyID)
+	}
+	if len(o.Encryption.KMSContext) > 0 {
+		input.SSEKMSEncryptionContext = aws.String(o.Encryption.kmsContext())
+	}
+	if o.WebsiteRedirectLocation != "" {
+		input.WebsiteRedirectLocation = aws.String(o.WebsiteRedirectLocation)
+	}
+
+	return input
+}
+
+// CopyOptions holds optional parameters of the object copy.
+// The content, the headers and the metadata of the source are preserved.
+type CopyOptions struct {
+	// ACL of the copy, S3 doesn't preserve it:
+	// if it's empty, the copy gets the default ACL of the bucket.
+	ACL ACL
+
+	// StorageClass of the copy, e.g. "GLACIER". Empty means the STANDARD class.
+	StorageClass string
+
+	// TaggingDirective tells whether the copy keeps the tags of the source (TaggingCopy, default)
+	// or gets Tags instead (TaggingReplace). Tags imply TaggingReplace if the directive is empty.
+	TaggingDirective TaggingDirective
+	Tags             map[string]string
+}
+
+// MetadataOptions holds the headers and the metadata RefreshMetadata sets on the stored object.
+// Empty fields keep the current values.
+type MetadataOptions struct {
+	// ACL of the object, S3 doesn't preserve it on copy:
+	// if it's empty, the object gets the default ACL of the bucket.
+	ACL ACL
+
+	ContentType        string
+	CacheControl       string
+	ContentDisposition string
+	ContentEncoding    string
+	ContentLanguage    string
+
+	// Metadata is merged into the current user-defined metadata, its values take precedence.
+	Metadata map[string]string
+
+	// Encryption overrides the current server-side encryption of the object.
+	// S3 doesn't return the KMS encryption context, so it's kept only if it's set here.
+	Encryption Encryption
+}
+
+// DownloadOptions holds optional parameters of the download.
+type DownloadOptions struct {
+	// VersionID is the version of the file, empty means the latest version.
+	VersionID string
+
+	// MaxRetries is the number of attempts to resume the download after a read error,
+	// e.g. a dropped connection, with a ranged request from the last read offset.
+	// The counter is reset once the reading succeeds. Zero disables resuming.
+	MaxRetries int
+
+	// RetryBackoff is the delay before the first resume attempt, it's doubled for every next attempt.
+	RetryBackoff time.Duration
+
+	// Headers are the extra headers of the download requests, the resumed ones included,
+	// e.g. the provider-specific headers not modeled by the options.
+	// Whether they are honored depends on the provider.
+	Headers map[string]string
+}
+
+// UploadPartOptions holds optional parameters of the uploaded part.
+type UploadPartOptions struct {
+	// Encryption is the encryption the multipart upload was initialized with.
+	// If set, the encryption applied to the part by S3 is checked against it.
+	Encryption Encryption
+
+	// Headers are the extra headers of the part upload request, see UploadOptions.Headers.
+	Headers map[string]string
+}
+
+// headerOptions returns the request option setting the given headers, if any.
+// The headers are set after the request is built, so they override the ones set from the input.
+func headerOptions(headers map[string]string) []request.Option {
+	if len(headers) == 0 {
+		return nil
+	}
+
+	return []request.Option{func(r *request.Request) {
+		r.Handlers.Build.PushBack(func(r *request.Request) {
+			for name, value := range headers {
+				r.HTTPRequest.Header.Set(name, value)
+			}
+		})
+	}}
+}
+
+// PresignOptions holds optional response header overrides of the presigned download url.
+// They let a single stored object be served differently per link,
+// e.g. with a download filename chosen at the link generation time.
+type PresignOptions struct {
+	ResponseContentDisposition string
+	ResponseContentType        string
+}
+
+// PostPolicyOptions holds the conditions of the presigned POST policy.
+type PostPolicyOptions struct {
+	// Expires is the policy lifetime. Default is 15 minutes.
+	Expires time.Duration
+
+	// Content length range of the uploaded file in bytes.
+	// MaxContentLength equal to 0 means no limit.
+	MinContentLength int64
+	MaxContentLength int64
+
+	// ContentTypePrefix restricts content type of the uploaded file, e.g. "image/".
+	// The form must contain the Content-Type field.
+	ContentTypePrefix string
+
+	ACL ACL
 }
@@ -0,0 +1,137 @@
+package storage
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/s3"
+	"github.com/pkg/errors"
+)
+
+// PresignedURL returns a presigned url to download the file, valid for the given duration.
+func (i *Interactor) PresignedURL(filepath string, expires time.Duration, opts PresignOptions) (string, error) {
+	input := &s3.GetObjectInput{
+		Bucket:       aws.String(i.bucket),
+		RequestPayer: i.requestPayer(),
+		Key:          aws.String(trimKey(filepath)),
+	}
+	if opts.ResponseContentDisposition != "" {
+		input.ResponseContentDisposition = aws.String(opts.ResponseContentDisposition)
+	}
+	if opts.ResponseContentType != "" {
+		input.ResponseContentType = aws.String(opts.ResponseContentType)
+	}
+	if err := input.Validate(); err != nil {
+		return "", errors.Wrap(err, "storage.presignedURL")
+	}
+
+	req, _ := i.s3.GetObjectRequest(input)
+	url, err := req.Presign(expires)
+	if err != nil {
+		return "", errors.Wrap(err, "storage.presignedURL")
+	}
+
+	return url, nil
+}
+
+// PostPolicy is a presigned POST policy form.
+// Fields must be sent as the form fields, followed by the "file" field with the file content.
+type PostPolicy struct {
+	URL    string
+	Fields map[string]string
+}
+
+// PresignPostPolicy returns a presigned POST policy form for direct browser uploads
+// with the limits enforced by the storage.
+// Uploaded files are stored under the keyPrefix with the original file name.
+func (i *Interactor) PresignPostPolicy(keyPrefix string, opts PostPolicyOptions) (*PostPolicy, error) {
+	if opts.MinContentLength < 0 || (opts.MaxContentLength > 0 && opts.MaxContentLength < opts.MinContentLength) {
+		return nil, errors.Wrap(ErrInvalidPostPolicy, "storage.presignPostPolicy")
+	}
+	if opts.Expires <= 0 {
+		opts.Expires = 15 * time.Minute
+	}
+
+	creds, err := i.s3.Config.Credentials.Get()
+	if err != nil {
+		return nil, errors.Wrap(err, "storage.presignPostPolicy")
+	}
+
+	now := time.Now().UTC()
+	date := now.Format("20060102")
+	region := i.s3.SigningRegion
+	credential := strings.Join([]string{creds.AccessKeyID, date, region, "s3", "aws4_request"}, "/")
+
+	fields := map[string]string{
+		"key":              keyPrefix + "${filename}",
+		"x-amz-algorithm":  "AWS4-HMAC-SHA256",
+		"x-amz-credential": credential,
+		"x-amz-date":       now.Format("20060102T150405Z"),
+	}
+	conditions := []interface{}{
+		map[string]string{"bucket": i.bucket},
+		[]interface{}{"starts-with", "$key", keyPrefix},
+		map[string]string{"x-amz-algorithm": fields["x-amz-algorithm"]},
+		map[string]string{"x-amz-credential": fields["x-amz-credential"]},
+		map[string]string{"x-amz-date": fields["x-amz-date"]},
+	}
+	if creds.SessionToken != "" {
+		fields["x-amz-security-token"] = creds.SessionToken
+		conditions = append(conditions, map[string]string{"x-amz-security-token": creds.SessionToken})
+	}
+	if opts.ACL != "" {
+		fields["acl"] = opts.ACL.String()
+		conditions = append(conditions, map[string]string{"acl": opts.ACL.String()})
+	}
+	if opts.MaxContentLength > 0 {
+		conditions = append(conditions, []interface{}{"content-length-range", opts.MinContentLength, opts.MaxContentLength})
+	}
+	if opts.ContentTypePrefix != "" {
+		conditions = append(conditions, []interface{}{"starts-with", "$Content-Type", opts.ContentTypePrefix})
+	}
+
+	policy, err := json.Marshal(map[string]interface{}{
+		"expiration": now.Add(opts.Expires).Format("2006-01-02T15:04:05.000Z"),
+		"conditions": conditions,
+	})
+	if err != nil {
+		return nil, errors.Wrap(err, "storage.presignPostPolicy")
+	}
+	fields["policy"] = base64.StdEncoding.EncodeToString(policy)
+
+	// Signature Version 4 of the policy.
+	signingKey := hmacSHA256([]byte("AWS4"+creds.SecretAccessKey), date)
+	signingKey = hmacSHA256(signingKey, region)
+	signingKey = hmacSHA256(signingKey, "s3")
+	signingKey = hmacSHA256(signingKey, "aws4_request")
+	fields["x-amz-signature"] = hex.EncodeToString(hmacSHA256(signingKey, fields["policy"]))
+
+	bucketURL, err := url.Parse(i.s3.Endpoint)
+	if err != nil {
+		return nil, errors.Wrap(err, "storage.presignPostPolicy")
+	}
+	if i.forcePathStyle {
+		bucketURL.Path = "/" + i.bucket
+	} else {
+		bucketURL.Host = i.bucket + "." + bucketURL.Host
+	}
+
+	return &PostPolicy{
+		URL:    bucketURL.String(),
+		Fields: fields,
+	}, nil
+}
+
+// hmacSHA256 returns HMAC-SHA256 of the data with the given key.
+func hmacSHA256(key []byte, data string) []byte {
+	h := hmac.New(sha256.New, key)
+	h.Write([]byte(data))
+	return h.Sum(nil)
+}
@@ -0,0 +1,50 @@
+package storage_test
+
+import (
+	"encoding/base64"
+	"net/url"
+	"testing"
+	"time"
+
+	"github.com/dmitrymomot/gofs/storage"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestPresignedURL(t *testing.T) {
+	link, err := interactor.PresignedURL("testing/report.csv", time.Minute, storage.PresignOptions{
+		ResponseContentDisposition: `attachment; filename="report.csv"`,
+		ResponseContentType:        "text/csv",
+	})
+	require.NoError(t, err)
+
+	u, err := url.Parse(link)
+	require.NoError(t, err)
+	assert.Equal(t, `attachment; filename="report.csv"`, u.Query().Get("response-content-disposition"))
+	assert.Equal(t, "text/csv", u.Query().Get("response-content-type"))
+	assert.NotEmpty(t, u.Query().Get("X-Amz-Signature"))
+}
+
+func TestPresignPostPolicy(t *testing.T) {
+	policy, err := interactor.PresignPostPolicy("testing/uploads/", storage.PostPolicyOptions{
+		MaxContentLength:  1024 * 1024,
+		ContentTypePrefix: "image/",
+		ACL:               storage.Public,
+	})
+	require.NoError(t, err)
+	assert.NotEmpty(t, policy.URL)
+	assert.Equal(t, "testing/uploads/${filename}", policy.Fields["key"])
+	assert.Equal(t, "public-read", policy.Fields["acl"])
+	assert.NotEmpty(t, policy.Fields["x-amz-signature"])
+
+	raw, err := base64.StdEncoding.DecodeString(policy.Fields["policy"])
+	require.NoError(t, err)
+	assert.Contains(t, string(raw), `["content-length-range",0,1048576]`)
+	assert.Contains(t, string(raw), `["starts-with","$Content-Type","image/"]`)
+
+	_, err = interactor.PresignPostPolicy("testing/", storage.PostPolicyOptions{
+		MinContentLength: 10,
+		MaxContentLength: 1,
+	})
+	assert.ErrorIs(t, err, storage.ErrInvalidPostPolicy)
+}
@@ -0,0 +1,277 @@
+package storage
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"io"
+	"os"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/s3"
+	"github.com/pkg/errors"
+)
+
+// Multipart upload limits of AWS S3.
+const (
+	mib = 1024 * 1024
+
+	MaxParts    int64 = 10000
+	MinPartSize int64 = 5 * mib
+	MaxPartSize int64 = 5 * 1024 * mib
+
+	// DefaultMultipartThreshold is the file size starting from which PutFile uses the multipart upload.
+	DefaultMultipartThreshold int64 = 5 * mib
+)
+
+// PutFile uploads the file choosing the upload method by its size:
+// files smaller than the multipart threshold are uploaded with a single request,
+// bigger ones are split into parts of CalculateOptimalPartSize size.
+// On failure the multipart upload is aborted.
+func (i *Interactor) PutFile(r io.ReadSeeker, key, contentType string, acl ACL) error {
+	if r == nil {
+		return errors.Wrap(ErrInvalidReader, "storage.putFile")
+	}
+
+	size, err := GetFileSize(r)
+	if err != nil {
+		return errors.Wrap(err, "storage.putFile")
+	}
+
+	return i.putSized(r, size, key, UploadOptions{ACL: acl, ContentType: contentType})
+}
+
+// LightweightUpload uploads the small file, e.g. a thumbnail or an avatar, with a single request
+// skipping the optional checks: the content type is trusted, the key isn't validated
+// and no upload options are applied. It's meant for high-rate uploads of the trusted content,
+// where the content type detection and validation dominate the cost of the upload.
+// If contentType is empty, it's detected from the content.
+// With the client-side encryption enabled the file is uploaded with Upload.
+func (i *Interactor) LightweightUpload(file []byte, filepath, contentType string, acl ACL) error {
+	if len(file) == 0 {
+		return errors.Wrap(ErrFileEmpty, "storage.lightweightUpload")
+	}
+	if contentType == "" {
+		var err error
+		if contentType, err = GetFileContentTypeByBytes(file); err != nil {
+			return errors.Wrap(err, "storage.lightweightUpload")
+		}
+	}
+	if i.keys != nil {
+		return i.Upload(file, filepath, acl, contentType)
+	}
+
+	input := &s3.PutObjectInput{
+		Bucket:       aws.String(i.bucket),
+		RequestPayer: i.requestPayer(),
+		Key:          aws.String(trimKey(filepath)),
+		Body:         bytes.NewReader(file),
+		ContentType:  aws.String(contentType),
+	}
+	if acl != "" {
+		input.ACL = aws.String(acl.String())
+	}
+
+	if _, err := i.s3.PutObject(input); err != nil {
+		i.logError("storage: upload %s: %v", filepath, err)
+		return errors.Wrap(err, "storage.lightweightUpload")
+	}
+	i.stats.uploads.Add(1)
+	i.stats.bytesUploaded.Add(int64(len(file)))
+
+	return nil
+}
+
+// UploadFileHandle uploads the opened file under the given key.
+// The content type is detected from the first bytes of the file,
+// the upload method is chosen by the file size as in PutFile.
+// The file is read from the beginning regardless of its current offset.
+func (i *Interactor) UploadFileHandle(f *os.File, key string, acl ACL) error {
+	if f == nil {
+		return errors.Wrap(ErrInvalidReader, "storage.uploadFileHandle")
+	}
+
+	stat, err := f.Stat()
+	if err != nil {
+		return errors.Wrap(err, "storage.uploadFileHandle: stat")
+	}
+	if stat.IsDir() {
+		return errors.Wrap(ErrInvalidReader, "storage.uploadFileHandle: directory")
+	}
+
+	if _, err := f.Seek(0, io.SeekStart); err != nil {
+		return errors.Wrap(err, "storage.uploadFileHandle: seek")
+	}
+	contentType, err := GetFileContentType(f)
+	if err != nil {
+		return errors.Wrap(err, "storage.uploadFileHandle")
+	}
+	if _, err := f.Seek(0, io.SeekStart); err != nil {
+		return errors.Wrap(err, "storage.uploadFileHandle: rewind")
+	}
+
+	return i.putSized(f, stat.Size(), key, UploadOptions{ACL: acl, ContentType: contentType})
+}
+
+// putSized uploads size bytes from r with a single request or in parts.
+// Returns ErrSizeMismatch if r isn't of the given size, e.g. the file was changed meanwhile.
+func (i *Interactor) putSized(r io.Reader, size int64, key string, opts UploadOptions) error {
+	r = ExactSizeReader(r, size)
+	if size < i.multipartThreshold {
+		data, err := io.ReadAll(r)
+		if err != nil {
+			return errors.Wrap(err, "storage.putFile")
+		}
+		return i.UploadWithOptions(data, key, opts)
+	}
+
+	partSize, err := calculatePartSize(size, i.maxParts, i.minPartSize)
+	if err != nil {
+		return errors.Wrap(err, "storage.putFile")
+	}
+
+	return i.uploadMultipart(r, key, opts, partSize, size)
+}
+
+// uploadMultipart uploads size bytes from r in parts of partSize.
+// The multipart upload is aborted if any step fails.
+func (i *Interactor) uploadMultipart(r io.Reader, key string, opts UploadOptions, partSize, size int64) (err error) {
+	totalParts := size / partSize
+	if size%partSize != 0 {
+		totalParts++
+	}
+
+	uploadID, err := i.CreateMultipartUploadWithOptions(key, opts)
+	if err != nil {
+		return err
+	}
+	defer func() {
+		if err != nil {
+			_ = i.abortMultipartUpload(key, uploadID)
+		}
+	}()
+
+	parts := make([]CompletedPart, 0, totalParts)
+	buf := make([]byte, partSize)
+	for partNum := int64(1); partNum <= totalParts; partNum++ {
+		n, err := io.ReadFull(r, buf)
+		if err != nil && err != io.ErrUnexpectedEOF {
+			return errors.Wrap(err, "storage.uploadMultipart")
+		}
+
+		part, err := i.UploadPart(key, uploadID, buf[:n], partNum, totalParts)
+		if err != nil {
+			return err
+		}
+		parts = append(parts, part)
+	}
+
+	// Reading past the end detects the extra data of the sized readers.
+	if _, err := r.Read(buf[:1]); err != nil && err != io.EOF {
+		return errors.Wrap(err, "storage.uploadMultipart")
+	}
+
+	return i.CompleteMultipartUpload(key, uploadID, parts...)
+}
+
+// UploadAndHash uploads the content of r and returns its hex-encoded SHA256 hash,
+// computed in the same pass over the data while it's streamed to the storage.
+// The size of the content doesn't have to be known in advance:
+// the content bigger than the multipart threshold is uploaded in parts.
+// The parts are of the multipart threshold size (at least the min part size), so the content
+// can't exceed the max parts of them: the longer content fails with ErrFileTooLarge
+// once the last allowed part is read, and the upload is aborted.
+// The hash is returned only if the upload succeeded.
+// Returns ErrFileEmpty if r yields no data.
+func (i *Interactor) UploadAndHash(r io.Reader, key, contentType string, acl ACL) (string, error) {
+	if r == nil {
+		return "", errors.Wrap(ErrInvalidReader, "storage.uploadAndHash")
+	}
+
+	h := sha256.New()
+	opts := UploadOptions{ACL: acl, ContentType: contentType}
+	if err := i.uploadStream(io.TeeReader(r, h), key, opts); err != nil {
+		return "", err
+	}
+
+	return hex.EncodeToString(h.Sum(nil)), nil
+}
+
+// uploadStream uploads the content of r of unknown size.
+// The content that fits into a single part is uploaded with a single request,
+// otherwise it's uploaded in parts reading one part ahead to detect the last one.
+func (i *Interactor) uploadStream(r io.Reader, key string, opts UploadOptions) (err error) {
+	partSize := i.minPartSize
+	if i.multipartThreshold > partSize {
+		partSize = i.multipartThreshold
+	}
+
+	buf := make([]byte, partSize)
+	n, err := io.ReadFull(r, buf)
+	if err == io.EOF || err == io.ErrUnexpectedEOF {
+		return i.UploadWithOptions(buf[:n], key, opts)
+	}
+	if err != nil {
+		return errors.Wrap(err, "storage.uploadStream")
+	}
+
+	uploadID, err := i.CreateMultipartUploadWithOptions(key, opts)
+	if err != nil {
+		return err
+	}
+	defer func() {
+		if err != nil {
+			_ = i.abortMultipartUpload(key, uploadID)
+		}
+	}()
+
+	var parts []CompletedPart
+	next := make([]byte, partSize)
+	for partNum := int64(1); ; partNum++ {
+		m, err := io.ReadFull(r, next)
+		if err != nil && err != io.EOF && err != io.ErrUnexpectedEOF {
+			return errors.Wrap(err, "storage.uploadStream")
+		}
+
+		// The total number of parts is unknown until the last part is read.
+		totalParts := i.maxParts
+		if m == 0 {
+			totalParts = partNum
+		} else if partNum == i.maxParts {
+			return errors.Wrap(ErrFileTooLarge, "storage.uploadStream")
+		}
+
+		part, err := i.UploadPart(key, uploadID, buf[:n], partNum, totalParts)
+		if err != nil {
+			return err
+		}
+		parts = append(parts, part)
+
+		if m == 0 {
+			break
+		}
+		buf, next, n = next, buf, m
+	}
+
+	return i.CompleteMultipartUpload(key, uploadID, parts...)
+}
+
+// MaxFileParts returns the number of parts the file is split into with the given part size,
+// validated against the interactor limits:
+// returns ErrPartTooSmall if the part size is less than the min part size
+// and ErrTotalParts if the number of parts exceeds the max parts.
+func (i *Interactor) MaxFileParts(file io.ReadSeeker, partSize int64) (int64, error) {
+	if partSize < i.minPartSize {
+		return 0, errors.Wrap(ErrPartTooSmall, "storage.maxFileParts")
+	}
+
+	totalParts, err := GetMaxFileParts(file, partSize)
+	if err != nil {
+		return 0, errors.Wrap(err, "storage.maxFileParts")
+	}
+	if totalParts > i.maxParts {
+		return 0, errors.Wrap(ErrTotalParts, "storage.maxFileParts")
+	}
+
+	return totalParts, nil
+}
@@ -0,0 +1,571 @@
+package storage_test
+
+import (
+	"context"
+	"crypto/md5"
+	"encoding/base64"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/dmitrymomot/gofs/storage"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// newLocalInteractor returns the interactor of the local server serving the requests with h.
+// The nil handler accepts every upload.
+func newLocalInteractor(b testing.TB, h http.Handler, opts ...storage.Option) *storage.Interactor {
+	if h == nil {
+		h = http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			w.Header().Set("ETag", `"etag"`)
+		})
+	}
+	srv := httptest.NewServer(h)
+	b.Cleanup(srv.Close)
+
+	client, err := storage.NewS3Client(storage.Options{
+		Key:            "key",
+		Secret:         "secret",
+		Endpoint:       srv.URL,
+		Region:         "us-east-1",
+		ForcePathStyle: true,
+		DisableSSL:     true,
+	})
+	require.NoError(b, err)
+
+	return storage.New(client, "bucket", srv.URL, opts...)
+}
+
+func TestUploadPartSizeLimits(t *testing.T) {
+	s := newLocalInteractor(t, nil, storage.WithMinPartSize(4), storage.WithMaxPartSize(8))
+
+	_, err := s.UploadPart("file.txt", "upload-id", []byte("abc"), 1, 2)
+	assert.ErrorIs(t, err, storage.ErrPartTooSmall)
+
+	_, err = s.UploadPart("file.txt", "upload-id", []byte("abcd"), 1, 2)
+	assert.NoError(t, err)
+
+	_, err = s.UploadPart("file.txt", "upload-id", []byte("abcdefgh"), 1, 2)
+	assert.NoError(t, err)
+
+	_, err = s.UploadPart("file.txt", "upload-id", []byte("abcdefghi"), 1, 2)
+	assert.ErrorIs(t, err, storage.ErrPartTooLarge)
+
+	// The last part can be smaller than the min size, but not empty.
+	_, err = s.UploadPart("file.txt", "upload-id", nil, 2, 2)
+	assert.ErrorIs(t, err, storage.ErrPartEmpty)
+
+	_, err = s.UploadPart("file.txt", "upload-id", []byte{}, 1, 1)
+	assert.ErrorIs(t, err, storage.ErrPartEmpty)
+
+	// The last part is checked against the max size too.
+	_, err = s.UploadPart("file.txt", "upload-id", []byte("abcdefghi"), 2, 2)
+	assert.ErrorIs(t, err, storage.ErrPartTooLarge)
+}
+
+// BenchmarkUploadValidated is the safe upload path: the content is validated before the upload.
+func BenchmarkUploadValidated(b *testing.B) {
+	s := newLocalInteractor(b, nil)
+	data, err := os.ReadFile("testdata/image.png")
+	require.NoError(b, err)
+
+	b.ReportAllocs()
+	b.SetBytes(int64(len(data)))
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		contentType, err := storage.ValidateUpload("image.png", data, []string{"image/*"})
+		if err != nil {
+			b.Fatal(err)
+		}
+		if err := s.Upload(data, "avatars/image.png", storage.Public, contentType); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+func BenchmarkLightweightUpload(b *testing.B) {
+	s := newLocalInteractor(b, nil)
+	data, err := os.ReadFile("testdata/image.png")
+	require.NoError(b, err)
+
+	b.ReportAllocs()
+	b.SetBytes(int64(len(data)))
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		if err := s.LightweightUpload(data, "avatars/image.png", "image/png", storage.Public); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+func TestUploadOnConflict(t *testing.T) {
+	var (
+		mu      sync.Mutex
+		objects = map[string]bool{"/bucket/photo.jpg": true, "/bucket/photo-1.jpg": true}
+	)
+	s := newLocalInteractor(t, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		defer mu.Unlock()
+
+		switch r.Method {
+		case http.MethodHead:
+			if !objects[r.URL.Path] {
+				w.WriteHeader(http.StatusNotFound)
+			}
+		case http.MethodPut:
+			objects[r.URL.Path] = true
+			w.Header().Set("ETag", `"etag"`)
+		}
+	}))
+
+	upload := func(key string, strategy storage.ConflictStrategy) (string, error) {
+		result, err := s.UploadWithResult([]byte("content"), key, storage.UploadOptions{ContentType: "image/jpeg", OnConflict: strategy})
+		if err != nil {
+			return "", err
+		}
+		return result.Key, nil
+	}
+
+	key, err := upload("photo.jpg", "")
+	require.NoError(t, err)
+	assert.Equal(t, "photo.jpg", key)
+
+	_, err = upload("photo.jpg", storage.ConflictFail)
+	assert.ErrorIs(t, err, storage.ErrObjectAlreadyExists)
+
+	key, err = upload("new.jpg", storage.ConflictFail)
+	require.NoError(t, err)
+	assert.Equal(t, "new.jpg", key)
+
+	key, err = upload("photo.jpg", storage.ConflictRename)
+	require.NoError(t, err)
+	assert.Equal(t, "photo-2.jpg", key)
+	assert.True(t, objects["/bucket/photo-2.jpg"])
+
+	_, err = upload("photo.jpg", "skip")
+	assert.ErrorIs(t, err, storage.ErrInvalidConflictStrategy)
+}
+
+func TestUploadIfMatch(t *testing.T) {
+	for name, supported := range map[string]bool{"if-match": true, "head fallback": false} {
+		supported := supported
+		t.Run(name, func(t *testing.T) {
+			var (
+				mu   sync.Mutex
+				etag = `"v1"`
+				puts int
+			)
+			s := newLocalInteractor(t, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				mu.Lock()
+				defer mu.Unlock()
+
+				switch r.Method {
+				case http.MethodHead:
+					w.Header().Set("ETag", etag)
+				case http.MethodPut:
+					if ifMatch := r.Header.Get("If-Match"); ifMatch != "" {
+						if !supported {
+							w.WriteHeader(http.StatusNotImplemented)
+							return
+						}
+						if ifMatch != etag {
+							w.WriteHeader(http.StatusPreconditionFailed)
+							fmt.Fprint(w, `<Error><Code>PreconditionFailed</Code></Error>`)
+							return
+						}
+					}
+					puts++
+					etag = fmt.Sprintf(`"v%d"`, puts+1)
+					w.Header().Set("ETag", etag)
+				}
+			}))
+			opts := storage.UploadOptions{ContentType: "application/json"}
+
+			assert.ErrorIs(t, s.UploadIfMatch([]byte("{}"), "config.json", "", opts), storage.ErrEmptyETag)
+
+			require.NoError(t, s.UploadIfMatch([]byte("{}"), "config.json", "v1", opts))
+			assert.Equal(t, 1, puts)
+
+			// The file was changed by the previous upload.
+			assert.ErrorIs(t, s.UploadIfMatch([]byte("{}"), "config.json", `"v1"`, opts), storage.ErrPreconditionFailed)
+			assert.Equal(t, 1, puts)
+
+			require.NoError(t, s.UploadIfMatch([]byte("{}"), "config.json", `"v2"`, opts))
+			assert.Equal(t, 2, puts)
+		})
+	}
+}
+
+func TestUploadAndHashTooLarge(t *testing.T) {
+	var (
+		mu       sync.Mutex
+		requests []string
+	)
+	s := newLocalInteractor(t, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		requests = append(requests, r.Method)
+		mu.Unlock()
+		if r.Method == http.MethodPost && r.URL.Query().Has("uploads") {
+			fmt.Fprint(w, `<InitiateMultipartUploadResult><UploadId>upload-id</UploadId></InitiateMultipartUploadResult>`)
+			return
+		}
+		w.Header().Set("ETag", `"etag"`)
+	}), storage.WithMultipartThreshold(4), storage.WithMinPartSize(4), storage.WithMaxParts(2))
+
+	// The stream longer than two parts fails before the last allowed part is uploaded.
+	_, err := s.UploadAndHash(strings.NewReader("0123456789"), "stream.txt", "text/plain", storage.Private)
+	assert.ErrorIs(t, err, storage.ErrFileTooLarge)
+	assert.Equal(t, []string{http.MethodPost, http.MethodPut, http.MethodDelete}, requests)
+}
+
+func TestUploadIfMatchHeaders(t *testing.T) {
+	var puts int
+	s := newLocalInteractor(t, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("If-Match") != `"v1"` {
+			w.WriteHeader(http.StatusPreconditionFailed)
+			fmt.Fprint(w, `<Error><Code>PreconditionFailed</Code></Error>`)
+			return
+		}
+		puts++
+		w.Header().Set("ETag", `"v2"`)
+	}))
+
+	// The precondition isn't overridden by the extra headers.
+	opts := storage.UploadOptions{ContentType: "application/json", Headers: map[string]string{"If-Match": `"v1"`}}
+	assert.ErrorIs(t, s.UploadIfMatch([]byte("{}"), "config.json", "v0", opts), storage.ErrPreconditionFailed)
+	assert.Zero(t, puts)
+}
+
+func TestCompleteMultipartUploadOrder(t *testing.T) {
+	var (
+		mu     sync.Mutex
+		bodies []string
+	)
+	s := newLocalInteractor(t, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		mu.Lock()
+		bodies = append(bodies, string(body))
+		mu.Unlock()
+		fmt.Fprint(w, `<CompleteMultipartUploadResult><ETag>"etag-2"</ETag></CompleteMultipartUploadResult>`)
+	}))
+
+	parts := []storage.CompletedPart{&testPart{partNumber: 2, etag: "b"}, &testPart{partNumber: 1, etag: "a"}}
+	require.NoError(t, s.CompleteMultipartUpload("file.txt", "upload-id", parts...))
+	assert.Equal(t, int64(2), parts[0].PartNumber(), "the caller's slice isn't modified")
+	assert.Less(t, strings.Index(bodies[0], "<PartNumber>1</PartNumber>"), strings.Index(bodies[0], "<PartNumber>2</PartNumber>"))
+
+	_, err := s.CompleteMultipartUploadWithOptions(context.Background(), "file.txt", "upload-id", storage.CompleteOptions{PreSorted: true}, parts...)
+	require.NoError(t, err)
+	assert.Greater(t, strings.Index(bodies[1], "<PartNumber>1</PartNumber>"), strings.Index(bodies[1], "<PartNumber>2</PartNumber>"))
+}
+
+func TestCompleteMultipartUploadWithResult(t *testing.T) {
+	s := newLocalInteractor(t, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("X-Amz-Version-Id", "version-1")
+		fmt.Fprint(w, `<CompleteMultipartUploadResult>
+	<Location>http://bucket.example.com/dir/file.txt</Location>
+	<Key>dir/file.txt</Key>
+	<ETag>"3858f62230ac3c915f300c664312c11f-2"</ETag>
+</CompleteMultipartUploadResult>`)
+	}))
+
+	parts := []storage.CompletedPart{&testPart{partNumber: 1, etag: "a"}, &testPart{partNumber: 2, etag: "b"}}
+	result, err := s.CompleteMultipartUploadWithResult("dir/file.txt", "upload-id", parts...)
+	require.NoError(t, err)
+	assert.Equal(t, &storage.UploadResult{
+		Key:       "dir/file.txt",
+		ETag:      `"3858f62230ac3c915f300c664312c11f-2"`,
+		VersionID: "version-1",
+		Location:  "http://bucket.example.com/dir/file.txt",
+	}, result)
+}
+
+func TestAtomicReplace(t *testing.T) {
+	var (
+		mu  sync.Mutex
+		ops []string
+	)
+	s := newLocalInteractor(t, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		op := r.Method + " " + strings.Split(r.URL.Path, ".tmp-")[0]
+		if strings.Contains(r.URL.Path, ".tmp-") {
+			op += ".tmp"
+		}
+		if src := r.Header.Get("X-Amz-Copy-Source"); src != "" {
+			op = "COPY " + strings.Split(src, ".tmp-")[0] + ".tmp " + r.URL.Path
+		}
+		mu.Lock()
+		ops = append(ops, op)
+		mu.Unlock()
+
+		switch {
+		case strings.HasPrefix(op, "COPY"):
+			fmt.Fprint(w, `<CopyObjectResult><ETag>"etag"</ETag></CopyObjectResult>`)
+		case r.Method == http.MethodHead:
+			w.Header().Set("Content-Length", "2")
+		case r.Method == http.MethodDelete:
+			w.WriteHeader(http.StatusNoContent)
+		}
+	}))
+
+	require.NoError(t, s.AtomicReplace("config.json", []byte("{}"), storage.Private, "application/json"))
+	assert.Equal(t, []string{
+		"PUT /bucket/config.json.tmp",
+		"HEAD /bucket/config.json.tmp",
+		"COPY bucket/config.json.tmp /bucket/config.json",
+		"DELETE /bucket/config.json.tmp",
+	}, ops)
+
+	// The short stream fails before the file is replaced.
+	ops = nil
+	err := s.AtomicReplaceReader(strings.NewReader("{}"), 3, "config.json", storage.Private, "application/json")
+	assert.ErrorIs(t, err, storage.ErrSizeMismatch)
+	assert.Empty(t, ops)
+}
+
+func TestUploadHeaders(t *testing.T) {
+	var (
+		mu          sync.Mutex
+		hints       = map[string]string{}
+		contentType string
+	)
+	s := newLocalInteractor(t, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		hints[r.Method+" "+r.URL.RawQuery] = r.Header.Get("X-Amz-Tier-Hint")
+		if r.Method == http.MethodPut && r.URL.RawQuery == "" {
+			contentType = r.Header.Get("Content-Type")
+		}
+		mu.Unlock()
+
+		w.Header().Set("ETag", `"etag"`)
+		if r.Method == http.MethodPost {
+			fmt.Fprint(w, `<InitiateMultipartUploadResult><UploadId>upload-id</UploadId></InitiateMultipartUploadResult>`)
+		}
+	}))
+	headers := map[string]string{"X-Amz-Tier-Hint": "cold"}
+
+	// The headers override the ones set by the options.
+	_, err := s.UploadWithResult([]byte("content"), "file.txt", storage.UploadOptions{
+		ContentType: "text/plain",
+		Headers:     map[string]string{"X-Amz-Tier-Hint": "cold", "Content-Type": "text/markdown"},
+	})
+	require.NoError(t, err)
+	assert.Equal(t, "text/markdown", contentType)
+	uploadID, err := s.CreateMultipartUploadWithContext(context.Background(), "file.txt", storage.UploadOptions{ContentType: "text/plain", Headers: headers})
+	require.NoError(t, err)
+	_, err = s.UploadPartWithContext(context.Background(), "file.txt", uploadID, []byte("content"), 1, 1, storage.UploadPartOptions{Headers: headers})
+	require.NoError(t, err)
+	body, _, err := s.DownloadWithContext(context.Background(), "file.txt", storage.DownloadOptions{Headers: headers})
+	require.NoError(t, err)
+	body.Close()
+
+	assert.Equal(t, map[string]string{
+		"PUT ":                                "cold",
+		"POST uploads=":                       "cold",
+		"PUT partNumber=1&uploadId=upload-id": "cold",
+		"GET ":                                "cold",
+	}, hints)
+}
+
+func TestUploadRedirect(t *testing.T) {
+	var (
+		mu        sync.Mutex
+		redirects = map[string]string{}
+	)
+	s := newLocalInteractor(t, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		assert.Empty(t, body)
+		mu.Lock()
+		redirects[r.URL.Path] = r.Header.Get("X-Amz-Website-Redirect-Location")
+		mu.Unlock()
+		w.Header().Set("ETag", `"etag"`)
+	}))
+
+	require.NoError(t, s.UploadRedirect("go/docs", "https://example.com/docs?lang=en"))
+	require.NoError(t, s.UploadRedirect("old/index.html", "/new/index.html"))
+	assert.Equal(t, map[string]string{
+		"/bucket/go/docs":        "https://example.com/docs?lang=en",
+		"/bucket/old/index.html": "/new/index.html",
+	}, redirects)
+
+	for _, target := range []string{"", "example.com/docs", "//example.com/docs", "ftp://example.com/file", "https://", "new/index.html"} {
+		assert.ErrorIs(t, s.UploadRedirect("alias", target), storage.ErrInvalidRedirectLocation, target)
+	}
+
+	_, err := s.UploadWithResult([]byte("content"), "file.txt", storage.UploadOptions{ContentType: "text/plain", WebsiteRedirectLocation: "javascript:alert(1)"})
+	assert.ErrorIs(t, err, storage.ErrInvalidRedirectLocation)
+	assert.Len(t, redirects, 2)
+}
+
+func TestClientEncryptionMultipart(t *testing.T) {
+	var (
+		mu     sync.Mutex
+		bodies = map[string][]byte{}
+	)
+	s := newLocalInteractor(t, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		mu.Lock()
+		bodies[r.Method+" "+r.URL.Path] = body
+		mu.Unlock()
+		w.Header().Set("ETag", `"etag"`)
+	}),
+		storage.WithClientEncryption(storage.StaticKey([]byte("0123456789abcdef0123456789abcdef"))),
+		storage.WithMultipartThreshold(8),
+		storage.WithMinPartSize(4),
+	)
+
+	// The small file is encrypted with a single request.
+	require.NoError(t, s.PutFile(strings.NewReader("secret"), "small.txt", "text/plain", storage.Private))
+	assert.NotContains(t, string(bodies["PUT /bucket/small.txt"]), "secret")
+
+	// The bigger ones would be stored in plaintext.
+	err := s.PutFile(strings.NewReader("secret secret secret"), "big.txt", "text/plain", storage.Private)
+	assert.ErrorIs(t, err, storage.ErrEncryptionUnsupported)
+	_, err = s.UploadAndHash(strings.NewReader("secret secret secret"), "stream.txt", "text/plain", storage.Private)
+	assert.ErrorIs(t, err, storage.ErrEncryptionUnsupported)
+	_, err = s.CreateMultipartUpload("big.txt", "text/plain", storage.Private)
+	assert.ErrorIs(t, err, storage.ErrEncryptionUnsupported)
+	_, err = s.UploadPart("big.txt", "upload-id", []byte("secret"), 1, 1)
+	assert.ErrorIs(t, err, storage.ErrEncryptionUnsupported)
+
+	assert.Len(t, bodies, 1, "nothing but the small file is sent")
+}
+
+func TestClientEncryptionContentMD5(t *testing.T) {
+	var (
+		mu       sync.Mutex
+		requests int
+	)
+	s := newLocalInteractor(t, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		mu.Lock()
+		requests++
+		mu.Unlock()
+		// The storage checks the hash of the received ciphertext.
+		assert.Equal(t, md5Base64(body), r.Header.Get("Content-MD5"))
+		w.Header().Set("ETag", `"etag"`)
+	}), storage.WithClientEncryption(storage.StaticKey([]byte("0123456789abcdef0123456789abcdef"))))
+
+	_, err := s.UploadWithResult([]byte("secret"), "file.txt", storage.UploadOptions{ContentType: "text/plain", ContentMD5: md5Base64([]byte("other"))})
+	assert.ErrorIs(t, err, storage.ErrChecksumMismatch)
+	assert.Zero(t, requests)
+
+	_, err = s.UploadWithResult([]byte("secret"), "file.txt", storage.UploadOptions{ContentType: "text/plain", ContentMD5: md5Base64([]byte("secret"))})
+	require.NoError(t, err)
+	assert.Equal(t, 1, requests)
+}
+
+// md5Base64 returns the base64-encoded MD5 of the data as in the Content-MD5 header.
+func md5Base64(data []byte) string {
+	sum := md5.Sum(data)
+	return base64.StdEncoding.EncodeToString(sum[:])
+}
+
+func TestUploadWithInvalidDefaultACL(t *testing.T) {
+	var requests int
+	s := newLocalInteractor(t, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests++
+	}), storage.WithDefaultACL("public"))
+	assert.Equal(t, storage.Private, s.DefaultACL())
+
+	err := s.UploadWithDefaultACL([]byte("content"), "file.txt", "text/plain")
+	assert.ErrorIs(t, err, storage.ErrInvalidACL)
+	err = s.PutFileWithDefaultACL(strings.NewReader("content"), "file.txt", "text/plain")
+	assert.ErrorIs(t, err, storage.ErrInvalidACL)
+	_, err = s.CreateMultipartUploadWithDefaultACL("file.txt", "text/plain")
+	assert.ErrorIs(t, err, storage.ErrInvalidACL)
+	assert.Zero(t, requests)
+}
+
+func TestRequesterPays(t *testing.T) {
+	var (
+		mu     sync.Mutex
+		payers = map[string]string{}
+	)
+	s := newLocalInteractor(t, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		payers[r.Method+" "+r.URL.RawQuery] = r.Header.Get("X-Amz-Request-Payer")
+		mu.Unlock()
+
+		switch {
+		case r.Method == http.MethodGet && r.URL.Query().Has("legal-hold"):
+			fmt.Fprint(w, `<LegalHold><Status>ON</Status></LegalHold>`)
+		case r.Method == http.MethodGet:
+			fmt.Fprint(w, `<ListPartsResult><IsTruncated>false</IsTruncated></ListPartsResult>`)
+		default:
+			w.Header().Set("ETag", `"etag"`)
+		}
+	}), storage.WithRequesterPays())
+
+	_, err := s.PartsReport("file.txt", "upload-id")
+	require.NoError(t, err)
+	require.NoError(t, s.SetLegalHold("file.txt", true))
+	on, err := s.GetLegalHold("file.txt")
+	require.NoError(t, err)
+	assert.True(t, on)
+	require.NoError(t, s.LightweightUpload([]byte("content"), "file.txt", "text/plain", storage.Private))
+
+	assert.Equal(t, map[string]string{
+		"GET uploadId=upload-id": "requester",
+		"PUT legal-hold=":        "requester",
+		"GET legal-hold=":        "requester",
+		"PUT ":                   "requester",
+	}, payers)
+}
+
+func TestRequesterPaysWrites(t *testing.T) {
+	var (
+		mu       sync.Mutex
+		requests []string
+	)
+	s := newLocalInteractor(t, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		requests = append(requests, r.Method+" "+r.URL.RawQuery)
+		mu.Unlock()
+		assert.Equal(t, "requester", r.Header.Get("X-Amz-Request-Payer"), r.Method+" "+r.URL.String())
+
+		query := r.URL.Query()
+		switch {
+		case r.Method == http.MethodPost && query.Has("uploads"):
+			fmt.Fprint(w, `<InitiateMultipartUploadResult><UploadId>upload-id</UploadId></InitiateMultipartUploadResult>`)
+		case r.Method == http.MethodPost && query.Has("uploadId"):
+			fmt.Fprint(w, `<CompleteMultipartUploadResult><ETag>"etag"</ETag></CompleteMultipartUploadResult>`)
+		case r.Method == http.MethodPost && query.Has("delete"):
+			fmt.Fprint(w, `<DeleteResult></DeleteResult>`)
+		case r.Method == http.MethodGet && query.Has("versions"):
+			fmt.Fprint(w, `<ListVersionsResult><IsTruncated>false</IsTruncated></ListVersionsResult>`)
+		case r.Method == http.MethodGet && query.Has("uploads"):
+			fmt.Fprint(w, `<ListMultipartUploadsResult><IsTruncated>false</IsTruncated></ListMultipartUploadsResult>`)
+		case r.Method == http.MethodHead:
+			w.Header().Set("Content-Length", "7")
+		case r.Header.Get("X-Amz-Copy-Source") != "":
+			fmt.Fprint(w, `<CopyObjectResult><ETag>"etag"</ETag></CopyObjectResult>`)
+		default:
+			w.Header().Set("ETag", `"etag"`)
+		}
+	}), storage.WithRequesterPays())
+
+	require.NoError(t, s.Upload([]byte("content"), "file.txt", storage.Private, "text/plain"))
+	uploadID, err := s.CreateMultipartUpload("big.bin", "application/octet-stream", storage.Private)
+	require.NoError(t, err)
+	part, err := s.UploadPart("big.bin", uploadID, []byte("content"), 1, 1)
+	require.NoError(t, err)
+	require.NoError(t, s.CompleteMultipartUpload("big.bin", uploadID, part))
+	require.NoError(t, s.AbortMultipartUpload("big.bin", uploadID))
+	require.NoError(t, s.CopyLarge("file.txt", "copy.txt", storage.Private))
+	require.NoError(t, s.SetACL("file.txt", storage.Public))
+	_, err = s.ListVersions("")
+	require.NoError(t, err)
+	_, err = s.ListMultipartUploads("")
+	require.NoError(t, err)
+	require.NoError(t, s.Delete("file.txt"))
+	_, err = s.DeleteMany("a.txt", "b.txt")
+	require.NoError(t, err)
+
+	assert.Len(t, requests, 12)
+}
@@ -0,0 +1,151 @@
+package storage
+
+import (
+	"fmt"
+	"io"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/s3"
+	"github.com/pkg/errors"
+)
+
+// objectReader reads the remote object with ranged requests,
+// which are issued lazily starting from the current offset.
+type objectReader struct {
+	interactor *Interactor
+	key        string
+	size       int64
+	offset     int64
+	body       io.ReadCloser
+}
+
+// OpenReader returns a seekable reader of the remote object,
+// so libraries which seek (e.g. archive/zip) can work with it without a local copy.
+// The object size is fetched once on open; the content is requested on the first read after a seek.
+func (i *Interactor) OpenReader(filepath string) (io.ReadSeekCloser, error) {
+	info, err := i.Stat(filepath)
+	if err != nil {
+		return nil, err
+	}
+
+	return &objectReader{
+		interactor: i,
+		key:        filepath,
+		size:       info.ContentLength,
+	}, nil
+}
+
+// Peek returns the first n bytes of the object with a ranged request,
+// e.g. to detect the content type without downloading the whole object.
+// If the object is smaller than n, its whole content is returned.
+// Like OpenReader, it reads the raw content of the client-side encrypted objects.
+// Returns ErrObjectNotFound if there is no object with the given path.
+func (i *Interactor) Peek(filepath string, n int64) ([]byte, error) {
+	if n <= 0 {
+		return []byte{}, nil
+	}
+
+	input := &s3.GetObjectInput{
+		Bucket:       aws.String(i.bucket),
+		RequestPayer: i.requestPayer(),
+		Key:          aws.String(trimKey(filepath)),
+		Range:        aws.String(fmt.Sprintf("bytes=0-%d", n-1)),
+	}
+	if err := input.Validate(); err != nil {
+		return nil, errors.Wrap(err, "storage.peek")
+	}
+
+	result, err := i.s3.GetObject(input)
+	if err != nil {
+		switch {
+		case isNotFound(err):
+			return nil, ErrObjectNotFound
+		case hasCode(err, "InvalidRange"):
+			// The object is empty.
+			return []byte{}, nil
+		}
+		i.logError("storage: peek %s: %v", filepath, err)
+		return nil, errors.Wrap(err, "storage.peek")
+	}
+	defer result.Body.Close()
+
+	// Some storages ignore the range and send the whole object.
+	data, err := io.ReadAll(io.LimitReader(i.countDownload(result.Body), n))
+	if err != nil {
+		return nil, errors.Wrap(err, "storage.peek")
+	}
+
+	return data, nil
+}
+
+// Read reads the object content from the current offset.
+func (r *objectReader) Read(p []byte) (int, error) {
+	if r.offset >= r.size {
+		return 0, io.EOF
+	}
+
+	if r.body == nil {
+		input := &s3.GetObjectInput{
+			Bucket:       aws.String(r.interactor.bucket),
+			RequestPayer: r.interactor.requestPayer(),
+			Key:          aws.String(trimKey(r.key)),
+			Range:        aws.String(fmt.Sprintf("bytes=%d-", r.offset)),
+		}
+		result, err := r.interactor.s3.GetObject(input)
+		if err != nil {
+			return 0, errors.Wrap(err, "storage.objectReader.read")
+		}
+		r.body = result.Body
+	}
+
+	n, err := r.body.Read(p)
+	r.offset += int64(n)
+	if err == io.EOF && r.offset < r.size {
+		// The response ended early, the next read requests the rest.
+		r.body.Close()
+		r.body = nil
+		if n == 0 {
+			return 0, io.ErrUnexpectedEOF
+		}
+		err = nil
+	}
+
+	return n, err
+}
+
+// Seek sets the offset for the next read.
+func (r *objectReader) Seek(offset int64, whence int) (int64, error) {
+	var abs int64
+	switch whence {
+	case io.SeekStart:
+		abs = offset
+	case io.SeekCurrent:
+		abs = r.offset + offset
+	case io.SeekEnd:
+		abs = r.size + offset
+	default:
+		return 0, errors.New("storage.objectReader.seek: invalid whence")
+	}
+	if abs < 0 {
+		return 0, errors.New("storage.objectReader.seek: negative position")
+	}
+
+	if abs != r.offset && r.body != nil {
+		r.body.Close()
+		r.body = nil
+	}
+	r.offset = abs
+
+	return abs, nil
+}
+
+// Close closes the current response body.
+func (r *objectReader) Close() error {
+	if r.body == nil {
+		return nil
+	}
+
+	err := r.body.Close()
+	r.body = nil
+	return err
+}
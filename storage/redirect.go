@@ -0,0 +1,42 @@
+package storage
+
+import (
+	"net/url"
+	"strings"
+
+	"github.com/pkg/errors"
+)
+
+// UploadRedirect creates the empty object which redirects the requests to the bucket website endpoint
+// to targetURL: an absolute http(s) URL or a path of another object in the bucket starting with "/".
+// It's the way to make the aliases and the short links on the static site buckets.
+// The redirect works with the website endpoint only, the REST API returns the empty object.
+func (i *Interactor) UploadRedirect(key, targetURL string) error {
+	if err := validateRedirectLocation(targetURL); err != nil {
+		return errors.Wrap(err, "storage.uploadRedirect")
+	}
+
+	_, err := i.UploadWithResult(nil, key, UploadOptions{
+		AllowEmpty:              true,
+		WebsiteRedirectLocation: targetURL,
+	})
+	return err
+}
+
+// validateRedirectLocation checks the website redirect location is a path in the bucket
+// or an absolute http(s) URL.
+func validateRedirectLocation(location string) error {
+	if strings.HasPrefix(location, "/") && !strings.HasPrefix(location, "//") {
+		if _, err := url.ParseRequestURI(location); err != nil {
+			return ErrInvalidRedirectLocation
+		}
+		return nil
+	}
+
+	u, err := url.Parse(location)
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		return ErrInvalidRedirectLocation
+	}
+
+	return nil
+}
@@ -0,0 +1,70 @@
+package storage
+
+import (
+	"fmt"
+	"net/http"
+
+	"github.com/aws/aws-sdk-go/aws/request"
+	"github.com/aws/aws-sdk-go/service/s3"
+)
+
+// bucketRegionHeader is the response header with the actual region of the bucket.
+const bucketRegionHeader = "X-Amz-Bucket-Region"
+
+// WrongRegionError is returned when the bucket is in another region than the client is configured for.
+// Region is the actual region of the bucket, it's empty if the storage didn't report it.
+// It matches ErrWrongRegion with errors.Is.
+type WrongRegionError struct {
+	Region string
+	Err    error
+}
+
+// Error returns the error message.
+func (e *WrongRegionError) Error() string {
+	if e.Region == "" {
+		return ErrWrongRegion.Error()
+	}
+	return fmt.Sprintf("%s: set the region to %q", ErrWrongRegion, e.Region)
+}
+
+// Is reports whether the target is ErrWrongRegion.
+func (e *WrongRegionError) Is(target error) bool {
+	return target == ErrWrongRegion
+}
+
+// Unwrap returns the original storage error.
+func (e *WrongRegionError) Unwrap() error {
+	return e.Err
+}
+
+// wrongRegionHandler replaces the errors caused by the region mismatch with WrongRegionError.
+var wrongRegionHandler = request.NamedHandler{
+	Name: "gofs.storage.WrongRegionHandler",
+	Fn: func(r *request.Request) {
+		if r.Error == nil || r.HTTPResponse == nil {
+			return
+		}
+
+		region := r.HTTPResponse.Header.Get(bucketRegionHeader)
+		switch {
+		case r.HTTPResponse.StatusCode == http.StatusMovedPermanently, hasCode(r.Error, "PermanentRedirect"):
+			// The bucket must be addressed with the endpoint of its region.
+		case region != "" && hasCode(r.Error, "AuthorizationHeaderMalformed"):
+			// The request is signed for the wrong region.
+		default:
+			return
+		}
+
+		r.Error = &WrongRegionError{Region: region, Err: r.Error}
+	},
+}
+
+// withRegionErrors returns a copy of the client reporting the region mismatch with WrongRegionError.
+// The handlers of the original client are left untouched.
+func withRegionErrors(c *s3.S3) *s3.S3 {
+	cl := *c.Client
+	cl.Handlers = cl.Handlers.Copy()
+	cl.Handlers.UnmarshalError.PushBackNamed(wrongRegionHandler)
+
+	return &s3.S3{Client: &cl}
+}
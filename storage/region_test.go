@@ -0,0 +1,33 @@
+package storage_test
+
+import (
+	"errors"
+	"net/http"
+	"testing"
+
+	"github.com/dmitrymomot/gofs/storage"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestWrongRegion(t *testing.T) {
+	s := newLocalInteractor(t, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("X-Amz-Bucket-Region", "eu-west-1")
+		w.Header().Set("Content-Type", "application/xml")
+		w.WriteHeader(http.StatusMovedPermanently)
+		if r.Method != http.MethodHead {
+			_, _ = w.Write([]byte(`<Error><Code>PermanentRedirect</Code><Message>The bucket you are attempting to access must be addressed using the specified endpoint.</Message></Error>`))
+		}
+	}))
+
+	_, err := s.Stat("file.txt")
+	assert.ErrorIs(t, err, storage.ErrWrongRegion)
+
+	_, _, err = s.Download("file.txt")
+	require.ErrorIs(t, err, storage.ErrWrongRegion)
+
+	var regionErr *storage.WrongRegionError
+	require.True(t, errors.As(err, &regionErr))
+	assert.Equal(t, "eu-west-1", regionErr.Region)
+	assert.Contains(t, err.Error(), `"eu-west-1"`)
+}
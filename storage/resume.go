@@ -0,0 +1,136 @@
+package storage
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"time"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/request"
+	"github.com/aws/aws-sdk-go/service/s3"
+	"github.com/pkg/errors"
+)
+
+// resumableReader reads the download body, re-requesting the rest of the object
+// from the last read offset if the reading fails.
+// The resumed requests are conditional on the ETag of the first response,
+// so a changed object fails the download instead of mixing the versions.
+type resumableReader struct {
+	ctx        context.Context
+	interactor *Interactor
+	input      s3.GetObjectInput
+	reqOpts    []request.Option
+	body       io.ReadCloser
+	offset     int64
+	size       int64
+	retries    int
+	maxRetries int
+	backoff    time.Duration
+	err        error // the error of the failed resume, returned by the next reads
+}
+
+// resumableBody wraps the body of the GetObject result into resumableReader.
+func (i *Interactor) resumableBody(ctx context.Context, input *s3.GetObjectInput, result *s3.GetObjectOutput, opts DownloadOptions) io.ReadCloser {
+	r := &resumableReader{
+		ctx:        ctx,
+		interactor: i,
+		input:      *input,
+		reqOpts:    headerOptions(opts.Headers),
+		body:       result.Body,
+		size:       aws.Int64Value(result.ContentLength),
+		maxRetries: opts.MaxRetries,
+		backoff:    opts.RetryBackoff,
+	}
+	r.input.IfMatch = result.ETag
+	if result.VersionId != nil {
+		r.input.VersionId = result.VersionId
+	}
+
+	return r
+}
+
+// Read reads the body, resuming the download if it fails.
+func (r *resumableReader) Read(p []byte) (int, error) {
+	for {
+		if r.err != nil {
+			return 0, r.err
+		}
+		if r.body == nil {
+			return 0, errors.New("storage.resumableReader.read: read after close")
+		}
+
+		n, err := r.body.Read(p)
+		r.offset += int64(n)
+		if err == io.EOF && r.offset < r.size {
+			// The connection dropped, but the response looks complete.
+			err = io.ErrUnexpectedEOF
+		}
+		if err == nil || err == io.EOF {
+			if n > 0 {
+				r.retries = 0
+			}
+			return n, err
+		}
+
+		if r.err = r.resume(err); r.err != nil {
+			return n, r.err
+		}
+		if n > 0 {
+			return n, nil
+		}
+	}
+}
+
+// resume re-requests the object from the current offset, retrying with the backoff.
+// Returns the cause if the retries are exhausted.
+func (r *resumableReader) resume(cause error) error {
+	r.body.Close()
+	r.body = nil
+
+	for ; r.retries < r.maxRetries; r.retries++ {
+		if err := r.ctx.Err(); err != nil {
+			return err
+		}
+
+		timer := time.NewTimer(r.backoff << r.retries)
+		select {
+		case <-r.ctx.Done():
+			timer.Stop()
+			return r.ctx.Err()
+		case <-timer.C:
+		}
+
+		r.interactor.logger.Debugf("storage: resume download %s from %d: %v", aws.StringValue(r.input.Key), r.offset, cause)
+
+		input := r.input
+		input.Range = aws.String(fmt.Sprintf("bytes=%d-", r.offset))
+		result, err := r.interactor.s3.GetObjectWithContext(r.ctx, &input, r.reqOpts...)
+		if err != nil {
+			if hasCode(err, "PreconditionFailed") || isNotFound(err) {
+				// The object was changed or removed, resuming can't succeed.
+				return errors.Wrap(err, "storage.resumableReader.read")
+			}
+			cause = err
+			continue
+		}
+
+		r.retries++
+		r.body = result.Body
+		return nil
+	}
+
+	r.interactor.logError("storage: download %s: %v", aws.StringValue(r.input.Key), cause)
+	return errors.Wrap(cause, "storage.resumableReader.read")
+}
+
+// Close closes the current response body.
+func (r *resumableReader) Close() error {
+	if r.body == nil {
+		return nil
+	}
+
+	err := r.body.Close()
+	r.body = nil
+	return err
+}
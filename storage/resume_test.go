@@ -0,0 +1,72 @@
+package storage_test
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync/atomic"
+	"testing"
+
+	"github.com/dmitrymomot/gofs/storage"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestDownloadResume(t *testing.T) {
+	content := bytes.Repeat([]byte("0123456789"), 1000)
+	var requests, hinted int32
+	s := newLocalInteractor(t, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		n := atomic.AddInt32(&requests, 1)
+		if r.Header.Get("X-Amz-Tier-Hint") == "hot" {
+			atomic.AddInt32(&hinted, 1)
+		}
+		w.Header().Set("ETag", `"etag"`)
+
+		start := 0
+		if rng := r.Header.Get("Range"); rng != "" {
+			assert.Equal(t, `"etag"`, r.Header.Get("If-Match"))
+			start, _ = strconv.Atoi(strings.TrimSuffix(strings.TrimPrefix(rng, "bytes="), "-"))
+			w.Header().Set("Content-Range", fmt.Sprintf("bytes %d-%d/%d", start, len(content)-1, len(content)))
+			w.Header().Set("Content-Length", strconv.Itoa(len(content)-start))
+			w.WriteHeader(http.StatusPartialContent)
+		} else {
+			w.Header().Set("Content-Length", strconv.Itoa(len(content)))
+		}
+
+		if n > 2 {
+			_, _ = w.Write(content[start:])
+			return
+		}
+
+		// Drop the connection in the middle of the body.
+		_, _ = w.Write(content[start : start+1000])
+		w.(http.Flusher).Flush()
+		conn, _, err := w.(http.Hijacker).Hijack()
+		require.NoError(t, err)
+		conn.Close()
+	}))
+
+	body, _, err := s.DownloadWithContext(context.Background(), "file.txt", storage.DownloadOptions{
+		MaxRetries: 1,
+		Headers:    map[string]string{"X-Amz-Tier-Hint": "hot"},
+	})
+	require.NoError(t, err)
+	data, err := io.ReadAll(body)
+	require.NoError(t, err)
+	require.NoError(t, body.Close())
+	assert.Equal(t, content, data)
+	assert.Equal(t, int32(3), atomic.LoadInt32(&requests))
+	assert.Equal(t, int32(3), atomic.LoadInt32(&hinted), "the resumed requests have the headers too")
+
+	// Without resuming the download fails.
+	atomic.StoreInt32(&requests, 0)
+	body, _, err = s.DownloadWithContext(context.Background(), "file.txt", storage.DownloadOptions{})
+	require.NoError(t, err)
+	_, err = io.ReadAll(body)
+	assert.Error(t, err)
+	body.Close()
+}
@@ -8,10 +8,47 @@ import (
 	"github.com/pkg/errors"
 )
 
-// NewS3Client returns configured AWS S3 client
+// Supported storage providers.
+const (
+	ProviderAWS    Provider = "aws"
+	ProviderMinIO  Provider = "minio"
+	ProviderSpaces Provider = "spaces"
+	ProviderWasabi Provider = "wasabi"
+)
+
+// defaultRegion is the region used when the provider doesn't need one,
+// the SDK requires it to sign the requests anyway.
+const defaultRegion = "us-east-1"
+
+// Provider is the S3-compatible storage provider.
+type Provider string
+
+// String returns the string representation of the provider.
+func (p Provider) String() string {
+	return string(p)
+}
+
+// NewS3Client returns configured AWS S3 client.
+// The defaults of opt.Provider are applied to the empty fields:
+//   - aws: the us-east-1 region, the endpoint is resolved by the SDK;
+//   - minio: the path-style urls and the us-east-1 region, the endpoint is required;
+//   - spaces: the endpoint of the region (datacenter), e.g. https://nyc3.digitaloceanspaces.com;
+//   - wasabi: the us-east-1 region and the endpoint of the region, e.g. https://s3.us-east-1.wasabisys.com.
+//
+// Boolean fields can be enabled only, e.g. ForcePathStyle can't be disabled for MinIO.
 func NewS3Client(opt Options) (*s3.S3, error) {
+	opt, err := opt.withProviderDefaults()
+	if err != nil {
+		return nil, errors.Wrap(err, "storage.NewS3Client")
+	}
+
+	creds := credentials.NewStaticCredentials(opt.Key, opt.Secret, "")
+	if opt.Anonymous {
+		creds = credentials.AnonymousCredentials
+	}
+
 	s3Config := &aws.Config{
-		Credentials:      credentials.NewStaticCredentials(opt.Key, opt.Secret, ""),
+		Credentials:      creds,
 		Endpoint:         aws.String(opt.Endpoint),
 		Region:           aws.String(opt.Region),
 		DisableSSL:       aws.Bool(opt.DisableSSL),
@@ -23,3 +60,40 @@ func NewS3Client(opt Options) (*s3.S3, error) {
 	}
 	return s3.New(newSession), nil
 }
+
+// withProviderDefaults returns the options with the empty fields set to the defaults of the provider.
+func (o Options) withProviderDefaults() (Options, error) {
+	switch o.Provider {
+	case "":
+	case ProviderAWS:
+		o.Region = stringOrDefault(o.Region, defaultRegion)
+	case ProviderMinIO:
+		if o.Endpoint == "" {
+			return o, ErrMissingEndpoint
+		}
+		o.Region = stringOrDefault(o.Region, defaultRegion)
+		o.ForcePathStyle = true
+	case ProviderSpaces:
+		if o.Endpoint == "" && o.Region == "" {
+			return o, ErrMissingEndpoint
+		}
+		o.Endpoint = stringOrDefault(o.Endpoint, "https://"+o.Region+".digitaloceanspaces.com")
+		// Spaces accept any region in the signature, the datacenter is a part of the endpoint.
+		o.Region = stringOrDefault(o.Region, defaultRegion)
+	case ProviderWasabi:
+		o.Region = stringOrDefault(o.Region, defaultRegion)
+		o.Endpoint = stringOrDefault(o.Endpoint, "https://s3."+o.Region+".wasabisys.com")
+	default:
+		return o, ErrUnknownProvider
+	}
+
+	return o, nil
+}
+
+// stringOrDefault returns s if it isn't empty and def otherwise.
+func stringOrDefault(s, def string) string {
+	if s == "" {
+		return def
+	}
+	return s
+}
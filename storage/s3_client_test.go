@@ -0,0 +1,86 @@
+package storage_test
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/dmitrymomot/gofs/storage"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestNewS3ClientProvider(t *testing.T) {
+	for name, tc := range map[string]struct {
+		opts      storage.Options
+		endpoint  string
+		region    string
+		pathStyle bool
+	}{
+		"minio": {
+			opts:      storage.Options{Provider: storage.ProviderMinIO, Endpoint: "http://localhost:9000"},
+			endpoint:  "http://localhost:9000",
+			region:    "us-east-1",
+			pathStyle: true,
+		},
+		"spaces": {
+			opts:     storage.Options{Provider: storage.ProviderSpaces, Region: "nyc3"},
+			endpoint: "https://nyc3.digitaloceanspaces.com",
+			region:   "nyc3",
+		},
+		"wasabi": {
+			opts:     storage.Options{Provider: storage.ProviderWasabi},
+			endpoint: "https://s3.us-east-1.wasabisys.com",
+			region:   "us-east-1",
+		},
+		"explicit fields": {
+			opts:     storage.Options{Provider: storage.ProviderWasabi, Region: "eu-central-1", Endpoint: "https://s3.example.com"},
+			endpoint: "https://s3.example.com",
+			region:   "eu-central-1",
+		},
+	} {
+		t.Run(name, func(t *testing.T) {
+			client, err := storage.NewS3Client(tc.opts)
+			require.NoError(t, err)
+			assert.Equal(t, tc.endpoint, aws.StringValue(client.Config.Endpoint))
+			assert.Equal(t, tc.region, aws.StringValue(client.Config.Region))
+			assert.Equal(t, tc.pathStyle, aws.BoolValue(client.Config.S3ForcePathStyle))
+		})
+	}
+
+	_, err := storage.NewS3Client(storage.Options{Provider: storage.ProviderMinIO})
+	assert.ErrorIs(t, err, storage.ErrMissingEndpoint)
+
+	_, err = storage.NewS3Client(storage.Options{Provider: "unknown"})
+	assert.ErrorIs(t, err, storage.ErrUnknownProvider)
+}
+
+func TestNewS3ClientAnonymous(t *testing.T) {
+	var authorization string
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		authorization = r.Header.Get("Authorization")
+		w.Header().Set("Content-Type", "text/plain")
+		_, _ = w.Write([]byte("Hello, World!"))
+	}))
+	defer srv.Close()
+
+	client, err := storage.NewS3Client(storage.Options{
+		Endpoint:       srv.URL,
+		Region:         "us-east-1",
+		ForcePathStyle: true,
+		DisableSSL:     true,
+		Anonymous:      true,
+	})
+	require.NoError(t, err)
+	s := storage.New(client, "public-bucket", srv.URL)
+
+	body, _, err := s.Download("file.txt")
+	require.NoError(t, err)
+	defer body.Close()
+	data, err := io.ReadAll(body)
+	require.NoError(t, err)
+	assert.Equal(t, "Hello, World!", string(data))
+	assert.Empty(t, authorization)
+}
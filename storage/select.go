@@ -0,0 +1,209 @@
+package storage
+
+import (
+	"fmt"
+	"io"
+	"strings"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/awserr"
+	"github.com/aws/aws-sdk-go/service/s3"
+	"github.com/pkg/errors"
+)
+
+// Supported formats of SelectContent.
+const (
+	SelectCSV  SelectFormat = "CSV"
+	SelectJSON SelectFormat = "JSON"
+)
+
+type (
+	// SelectFormat is the format of the queried object and the query result.
+	SelectFormat string
+
+	// SelectQuery is the SQL query of SelectContent,
+	// e.g. "SELECT s.name FROM S3Object s WHERE s.age > '30'".
+	SelectQuery struct {
+		Expression string
+
+		// InputFormat is the format of the queried object.
+		InputFormat SelectFormat
+		// OutputFormat is the format of the result rows, default is InputFormat.
+		OutputFormat SelectFormat
+
+		// CSVHeader means the first line of the CSV object is the header,
+		// so the columns can be referenced by names.
+		CSVHeader bool
+		// CSVDelimiter is the CSV field delimiter, default is a comma.
+		CSVDelimiter string
+
+		// JSONDocument means the JSON object is a single document,
+		// by default it's expected to be a JSON lines file.
+		JSONDocument bool
+	}
+
+	// selectReader streams the records of the select response.
+	selectReader struct {
+		stream  *s3.SelectObjectContentEventStream
+		pending []byte
+		ended   bool
+	}
+)
+
+// SelectContent runs the SQL query against the CSV or JSON object on the storage side
+// and streams the matching rows, so only the needed data is downloaded.
+// Returns ErrInvalidSelectQuery if the query is rejected by the storage, e.g. it can't be parsed.
+// Reading the result fails with ErrIncompleteSelect if the stream ends before the end of the result.
+func (i *Interactor) SelectContent(filepath string, query SelectQuery) (io.ReadCloser, error) {
+	input, err := query.input(i.bucket, filepath)
+	if err != nil {
+		return nil, errors.Wrap(err, "storage.selectContent")
+	}
+	if err := input.Validate(); err != nil {
+		return nil, errors.Wrap(err, "storage.selectContent")
+	}
+
+	result, err := i.s3.SelectObjectContentWithContext(aws.BackgroundContext(), input, i.requestPayerHeader()...)
+	if err != nil {
+		if isNotFound(err) {
+			return nil, ErrObjectNotFound
+		}
+		if isSelectQueryError(err) {
+			return nil, errors.Wrap(&selectQueryError{err: err}, "storage.selectContent")
+		}
+		i.logError("storage: select content of %s: %v", filepath, err)
+		return nil, errors.Wrap(err, "storage.selectContent")
+	}
+
+	return &selectReader{stream: result.EventStream}, nil
+}
+
+// input returns the select request of the query.
+func (q SelectQuery) input(bucket, key string) (*s3.SelectObjectContentInput, error) {
+	if strings.TrimSpace(q.Expression) == "" {
+		return nil, ErrInvalidSelectQuery
+	}
+
+	inputSerialization := &s3.InputSerialization{}
+	switch q.InputFormat {
+	case SelectCSV:
+		csv := &s3.CSVInput{FileHeaderInfo: aws.String(s3.FileHeaderInfoNone)}
+		if q.CSVHeader {
+			csv.FileHeaderInfo = aws.String(s3.FileHeaderInfoUse)
+		}
+		if q.CSVDelimiter != "" {
+			csv.FieldDelimiter = aws.String(q.CSVDelimiter)
+		}
+		inputSerialization.CSV = csv
+	case SelectJSON:
+		jsonType := s3.JSONTypeLines
+		if q.JSONDocument {
+			jsonType = s3.JSONTypeDocument
+		}
+		inputSerialization.JSON = &s3.JSONInput{Type: aws.String(jsonType)}
+	default:
+		return nil, ErrInvalidSelectQuery
+	}
+
+	outputFormat := q.OutputFormat
+	if outputFormat == "" {
+		outputFormat = q.InputFormat
+	}
+	outputSerialization := &s3.OutputSerialization{}
+	switch outputFormat {
+	case SelectCSV:
+		csv := &s3.CSVOutput{}
+		if q.CSVDelimiter != "" {
+			csv.FieldDelimiter = aws.String(q.CSVDelimiter)
+		}
+		outputSerialization.CSV = csv
+	case SelectJSON:
+		outputSerialization.JSON = &s3.JSONOutput{}
+	default:
+		return nil, ErrInvalidSelectQuery
+	}
+
+	return &s3.SelectObjectContentInput{
+		Bucket:              aws.String(bucket),
+		Key:                 aws.String(trimKey(key)),
+		Expression:          aws.String(q.Expression),
+		ExpressionType:      aws.String(s3.ExpressionTypeSql),
+		InputSerialization:  inputSerialization,
+		OutputSerialization: outputSerialization,
+	}, nil
+}
+
+// selectQueryError is the storage error rejecting the select query,
+// it matches ErrInvalidSelectQuery with errors.Is.
+type selectQueryError struct {
+	err error
+}
+
+// Error returns the error message.
+func (e *selectQueryError) Error() string {
+	return fmt.Sprintf("%s: %s", ErrInvalidSelectQuery, e.err)
+}
+
+// Is reports whether the target is ErrInvalidSelectQuery.
+func (e *selectQueryError) Is(target error) bool {
+	return target == ErrInvalidSelectQuery
+}
+
+// Unwrap returns the original storage error.
+func (e *selectQueryError) Unwrap() error {
+	return e.err
+}
+
+// isSelectQueryError reports whether the storage rejected the select query itself,
+// e.g. because of a syntax error or an unknown column.
+func isSelectQueryError(err error) bool {
+	var awsErr awserr.Error
+	if !errors.As(err, &awsErr) {
+		return false
+	}
+
+	code := awsErr.Code()
+	return strings.HasPrefix(code, "Parse") ||
+		strings.HasPrefix(code, "Evaluator") ||
+		strings.HasPrefix(code, "InvalidColumnIndex") ||
+		strings.HasPrefix(code, "InvalidTextEncoding") ||
+		code == "MissingHeaders" ||
+		code == "InvalidExpressionType" ||
+		code == "UnsupportedSqlOperation"
+}
+
+// Read reads the result rows.
+func (r *selectReader) Read(p []byte) (int, error) {
+	for len(r.pending) == 0 {
+		if r.ended {
+			return 0, io.EOF
+		}
+
+		event, ok := <-r.stream.Events()
+		if !ok {
+			if err := r.stream.Err(); err != nil {
+				if isSelectQueryError(err) {
+					return 0, errors.Wrap(&selectQueryError{err: err}, "storage.selectContent")
+				}
+				return 0, errors.Wrap(err, "storage.selectContent")
+			}
+			return 0, ErrIncompleteSelect
+		}
+
+		switch e := event.(type) {
+		case *s3.RecordsEvent:
+			r.pending = e.Payload
+		case *s3.EndEvent:
+			r.ended = true
+		}
+	}
+
+	n := copy(p, r.pending)
+	r.pending = r.pending[n:]
+	return n, nil
+}
+
+// Close closes the response stream.
+func (r *selectReader) Close() error {
+	return r.stream.Close()
+}
@@ -0,0 +1,32 @@
+package storage_test
+
+import (
+	"fmt"
+	"net/http"
+	"testing"
+
+	"github.com/aws/aws-sdk-go/aws/awserr"
+	"github.com/dmitrymomot/gofs/storage"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestSelectContentQueryError(t *testing.T) {
+	s := newLocalInteractor(t, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, "requester", r.Header.Get("X-Amz-Request-Payer"))
+		w.WriteHeader(http.StatusBadRequest)
+		fmt.Fprint(w, `<Error><Code>ParseSyntaxError</Code><Message>Syntax error in the query</Message></Error>`)
+	}), storage.WithRequesterPays())
+
+	_, err := s.SelectContent("data.csv", storage.SelectQuery{
+		Expression:  "SELEC * FROM S3Object",
+		InputFormat: storage.SelectCSV,
+	})
+	assert.ErrorIs(t, err, storage.ErrInvalidSelectQuery)
+	assert.Contains(t, err.Error(), "storage.selectContent")
+
+	// The storage error is kept.
+	var awsErr awserr.Error
+	require.ErrorAs(t, err, &awsErr)
+	assert.Equal(t, "ParseSyntaxError", awsErr.Code())
+}
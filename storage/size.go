@@ -0,0 +1,44 @@
+package storage
+
+import "io"
+
+// exactSizeReader fails the reading if the stream isn't of the declared size.
+type exactSizeReader struct {
+	r         io.Reader
+	remaining int64
+}
+
+// ExactSizeReader returns a reader which yields the content of r
+// and fails with ErrSizeMismatch if r ends before the declared size or continues past it,
+// so the streaming upload fails early instead of storing a truncated object.
+// The extra data is detected by reading a byte past the declared size.
+func ExactSizeReader(r io.Reader, size int64) io.Reader {
+	return &exactSizeReader{r: r, remaining: size}
+}
+
+// Read reads up to the remaining number of bytes.
+func (r *exactSizeReader) Read(p []byte) (int, error) {
+	if r.remaining <= 0 {
+		var probe [1]byte
+		n, err := r.r.Read(probe[:])
+		if n > 0 {
+			return 0, ErrSizeMismatch
+		}
+		return 0, err
+	}
+
+	if int64(len(p)) > r.remaining {
+		p = p[:r.remaining]
+	}
+	n, err := r.r.Read(p)
+	r.remaining -= int64(n)
+	if err == io.EOF {
+		if r.remaining > 0 {
+			return n, ErrSizeMismatch
+		}
+		// The end of the stream is reported by the next read, after checking for the extra data.
+		err = nil
+	}
+
+	return n, err
+}
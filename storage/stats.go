@@ -0,0 +1,63 @@
+package storage
+
+import (
+	"io"
+	"sync/atomic"
+)
+
+type (
+	// stats holds the operation counters of the interactor.
+	stats struct {
+		uploads         atomic.Int64
+		downloads       atomic.Int64
+		bytesUploaded   atomic.Int64
+		bytesDownloaded atomic.Int64
+		errors          atomic.Int64
+	}
+
+	// StatsSnapshot is a point-in-time copy of the interactor counters.
+	// The counters are process-local and start from zero on the interactor creation.
+	StatsSnapshot struct {
+		Uploads         int64 // uploaded objects, a multipart upload is counted on completion
+		Downloads       int64 // started downloads
+		BytesUploaded   int64 // bytes sent, including the parts of multipart uploads
+		BytesDownloaded int64 // bytes read from the download bodies
+		Errors          int64 // failed storage requests
+	}
+
+	// countingReader counts the bytes read from the wrapped reader.
+	countingReader struct {
+		io.ReadCloser
+		n *atomic.Int64
+	}
+)
+
+// Stats returns the current values of the operation counters.
+func (i *Interactor) Stats() StatsSnapshot {
+	return StatsSnapshot{
+		Uploads:         i.stats.uploads.Load(),
+		Downloads:       i.stats.downloads.Load(),
+		BytesUploaded:   i.stats.bytesUploaded.Load(),
+		BytesDownloaded: i.stats.bytesDownloaded.Load(),
+		Errors:          i.stats.errors.Load(),
+	}
+}
+
+// logError logs the failed operation and counts the error.
+func (i *Interactor) logError(format string, args ...interface{}) {
+	i.stats.errors.Add(1)
+	i.logger.Errorf(format, args...)
+}
+
+// countDownload counts the started download and wraps its body to count the read bytes.
+func (i *Interactor) countDownload(body io.ReadCloser) io.ReadCloser {
+	i.stats.downloads.Add(1)
+	return &countingReader{ReadCloser: body, n: &i.stats.bytesDownloaded}
+}
+
+// Read reads from the wrapped reader and counts the read bytes.
+func (r *countingReader) Read(p []byte) (int, error) {
+	n, err := r.ReadCloser.Read(p)
+	r.n.Add(int64(n))
+	return n, err
+}
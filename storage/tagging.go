@@ -0,0 +1,73 @@
+package storage
+
+import (
+	"net/url"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+
+	"github.com/aws/aws-sdk-go/service/s3"
+)
+
+// Object tagging limits of S3.
+const (
+	maxObjectTags     = 10
+	maxTagKeyLength   = 128
+	maxTagValueLength = 256
+)
+
+// Tagging directives of the server-side copy.
+const (
+	TaggingCopy    TaggingDirective = s3.TaggingDirectiveCopy
+	TaggingReplace TaggingDirective = s3.TaggingDirectiveReplace
+)
+
+// TaggingDirective tells the server-side copy whether to keep the tags of the source object
+// or to replace them with the given ones.
+type TaggingDirective string
+
+// String returns the string representation of the tagging directive.
+func (d TaggingDirective) String() string {
+	return string(d)
+}
+
+// validateTags checks the tags against the S3 limits:
+// up to 10 tags, the keys up to 128 characters and the values up to 256 characters
+// of letters, digits, spaces and the symbols + - = . _ : / @.
+func validateTags(tags map[string]string) error {
+	if len(tags) > maxObjectTags {
+		return ErrInvalidTags
+	}
+
+	for key, value := range tags {
+		if key == "" || utf8.RuneCountInString(key) > maxTagKeyLength || !isTagText(key) {
+			return ErrInvalidTags
+		}
+		if utf8.RuneCountInString(value) > maxTagValueLength || !isTagText(value) {
+			return ErrInvalidTags
+		}
+	}
+
+	return nil
+}
+
+// isTagText reports whether the string contains only the characters allowed in tags.
+func isTagText(s string) bool {
+	for _, r := range s {
+		if !unicode.IsLetter(r) && !unicode.IsDigit(r) && !unicode.IsSpace(r) && !strings.ContainsRune("+-=._:/@", r) {
+			return false
+		}
+	}
+
+	return true
+}
+
+// encodeTags encodes the tags as the URL query of the x-amz-tagging header.
+func encodeTags(tags map[string]string) string {
+	values := make(url.Values, len(tags))
+	for key, value := range tags {
+		values.Set(key, value)
+	}
+
+	return values.Encode()
+}
@@ -0,0 +1,55 @@
+package storage
+
+import (
+	"context"
+	"io"
+	"time"
+
+	"github.com/aws/aws-sdk-go/aws/request"
+	"github.com/aws/aws-sdk-go/service/s3"
+)
+
+// cancelOnClose cancels the request context when the response body is closed.
+type cancelOnClose struct {
+	io.ReadCloser
+	cancel context.CancelFunc
+}
+
+// Close closes the body and releases the request context.
+func (b *cancelOnClose) Close() error {
+	defer b.cancel()
+	return b.ReadCloser.Close()
+}
+
+// timeoutHandler limits the time every request waits for the response.
+// The request context is derived from the caller's one, so the sooner deadline wins.
+// The timeout doesn't limit reading the streamed responses after they arrived:
+// the context of a downloaded object is released when its body is closed.
+func timeoutHandler(timeout time.Duration) request.NamedHandler {
+	return request.NamedHandler{
+		Name: "gofs.storage.TimeoutHandler",
+		Fn: func(r *request.Request) {
+			ctx, cancel := context.WithCancel(r.Context())
+			timer := time.AfterFunc(timeout, cancel)
+			r.SetContext(ctx)
+
+			r.Handlers.Complete.PushBack(func(r *request.Request) {
+				timer.Stop()
+				if r.Error == nil {
+					switch out := r.Data.(type) {
+					case *s3.GetObjectOutput:
+						if out.Body != nil {
+							out.Body = &cancelOnClose{ReadCloser: out.Body, cancel: cancel}
+							return
+						}
+					case *s3.SelectObjectContentOutput:
+						// The event stream is read after the request completes,
+						// the context is released with the caller's one.
+						return
+					}
+				}
+				cancel()
+			})
+		},
+	}
+}
@@ -0,0 +1,41 @@
+package storage_test
+
+import (
+	"io"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/dmitrymomot/gofs/storage"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestDefaultTimeout(t *testing.T) {
+	s := newLocalInteractor(t, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.Method {
+		case http.MethodHead:
+			// Hung request.
+			time.Sleep(500 * time.Millisecond)
+		case http.MethodGet:
+			// Slow body of the response which arrived in time.
+			w.Header().Set("Content-Length", "5")
+			w.WriteHeader(http.StatusOK)
+			w.(http.Flusher).Flush()
+			time.Sleep(200 * time.Millisecond)
+			_, _ = w.Write([]byte("Hello"))
+		}
+	}), storage.WithDefaultTimeout(100*time.Millisecond))
+
+	start := time.Now()
+	_, err := s.Stat("file.txt")
+	assert.Error(t, err)
+	assert.Less(t, time.Since(start), 400*time.Millisecond)
+
+	body, _, err := s.Download("file.txt")
+	require.NoError(t, err)
+	defer body.Close()
+	data, err := io.ReadAll(body)
+	require.NoError(t, err)
+	assert.Equal(t, "Hello", string(data))
+}
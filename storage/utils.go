@@ -1,14 +1,24 @@
 package storage
 
 import (
+	"bytes"
+	"crypto/md5"
+	"encoding/base64"
 	"io"
+	"mime"
 	"strings"
 
 	"github.com/gabriel-vasile/mimetype"
 	"github.com/pkg/errors"
 )
 
+// sniffLen is the number of bytes read from a reader to detect its content type.
+// It's equal to the default read limit of the mimetype package.
+const sniffLen = 3072
+
 // GetFileContentType returns the content type of a file.
+// It reads from the input, so the input is partially consumed afterwards;
+// use DetectAndReplay to keep the content readable.
 func GetFileContentType(input io.Reader) (string, error) {
 	if input == nil {
 		return "", errors.Wrap(ErrInvalidReader, "storage.GetFileContentType")
@@ -24,14 +34,134 @@ func GetFileContentType(input io.Reader) (string, error) {
 }
 
 // GetFileContentTypeByBytes returns the content type of a file.
+// The type is detected by the signatures of the known formats in the first bytes of the content,
+// so a few bytes are often not enough: they are detected as "text/plain" if they look like text
+// and as "application/octet-stream" otherwise, which is also returned for any unknown content.
+// Returns ErrFileEmpty if the input is empty.
 func GetFileContentTypeByBytes(input []byte) (string, error) {
-	if input == nil {
-		return "", errors.Wrap(ErrInvalidReader, "storage.GetFileContentTypeByBytes")
+	if len(input) == 0 {
+		return "", errors.Wrap(ErrFileEmpty, "storage.GetFileContentTypeByBytes")
 	}
 
 	mtype := mimetype.Detect(input)
-	parts := strings.Split(mtype.String(), ";")
-	return parts[0], nil
+	if contentType := strings.Split(mtype.String(), ";")[0]; contentType != "" {
+		return contentType, nil
+	}
+
+	return "application/octet-stream", nil
+}
+
+// DetectAndReplay returns the content type of the input and a reader
+// which replays the bytes consumed by the detection followed by the rest of the input.
+// Use the returned reader instead of the input.
+func DetectAndReplay(input io.Reader) (string, io.Reader, error) {
+	if input == nil {
+		return "", nil, errors.Wrap(ErrInvalidReader, "storage.DetectAndReplay")
+	}
+
+	head := make([]byte, sniffLen)
+	n, err := io.ReadFull(input, head)
+	if err != nil && err != io.EOF && err != io.ErrUnexpectedEOF {
+		return "", nil, errors.Wrap(err, "storage.DetectAndReplay")
+	}
+	head = head[:n]
+
+	parts := strings.Split(mimetype.Detect(head).String(), ";")
+	return parts[0], io.MultiReader(bytes.NewReader(head), input), nil
+}
+
+// CalculateOptimalPartSize returns the smallest part size, rounded up to a whole MiB,
+// which allows uploading the file of the given size in no more than MaxParts parts.
+// The result is never less than MinPartSize.
+func CalculateOptimalPartSize(fileSize int64) (int64, error) {
+	partSize, err := calculatePartSize(fileSize, MaxParts, MinPartSize)
+	if err != nil {
+		return 0, errors.Wrap(err, "storage.CalculateOptimalPartSize")
+	}
+
+	return partSize, nil
+}
+
+// calculatePartSize returns the optimal part size for the given limits.
+func calculatePartSize(fileSize, maxParts, minPartSize int64) (int64, error) {
+	if fileSize <= 0 {
+		return 0, ErrFileEmpty
+	}
+	if fileSize > maxParts*MaxPartSize {
+		return 0, ErrFileTooLarge
+	}
+
+	partSize := fileSize / maxParts
+	if fileSize%maxParts != 0 {
+		partSize++
+	}
+	if rem := partSize % mib; rem != 0 {
+		partSize += mib - rem
+	}
+	if partSize < minPartSize {
+		partSize = minPartSize
+	}
+
+	return partSize, nil
+}
+
+// ValidateUpload detects the content type of the file, checks it against the allowed content types
+// and cross-checks it with the file extension.
+// The allowed list may contain wildcards like "image/*"; the empty list allows any type.
+// Content which can't be detected falls back to the type guessed by the extension.
+// Returns the canonical content type to be used for the upload.
+func ValidateUpload(fileName string, data []byte, allowed []string) (string, error) {
+	if len(data) == 0 {
+		return "", errors.Wrap(ErrFileEmpty, "storage.ValidateUpload")
+	}
+
+	detected := mimetype.Detect(data)
+	contentType := strings.Split(detected.String(), ";")[0]
+
+	if ext := GetFileExtension(fileName); ext != "" && ext != fileName {
+		if extType := strings.Split(mime.TypeByExtension("."+ext), ";")[0]; extType != "" {
+			switch {
+			case contentType == "application/octet-stream":
+				// Undetectable content, trust the extension.
+				contentType = extType
+			case contentType == "text/plain" && strings.HasPrefix(extType, "text/"):
+				// The extension is more specific than the generic text.
+				contentType = extType
+			case !mimeIs(detected, extType):
+				return "", errors.Wrap(ErrExtensionMismatch, "storage.ValidateUpload")
+			}
+		}
+	}
+
+	if len(allowed) > 0 && !contentTypeAllowed(contentType, allowed) {
+		return "", errors.Wrap(ErrInvalidContentType, "storage.ValidateUpload")
+	}
+
+	return contentType, nil
+}
+
+// mimeIs reports whether the detected type or any of its parents is the expected type.
+func mimeIs(detected *mimetype.MIME, expected string) bool {
+	for m := detected; m != nil; m = m.Parent() {
+		if m.Is(expected) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// contentTypeAllowed reports whether the content type matches any of the allowed types.
+func contentTypeAllowed(contentType string, allowed []string) bool {
+	for _, a := range allowed {
+		a = strings.ToLower(strings.TrimSpace(a))
+		if a == "*/*" || a == contentType ||
+			(strings.HasSuffix(a, "/*") && strings.HasPrefix(contentType, strings.TrimSuffix(a, "*"))) {
+			return true
+		}
+	}
+
+	return false
 }
 
 // Get max file parts can be if the file is split into parts with the given part size.
@@ -110,3 +240,24 @@ func GetFileNameWithoutExtension(fileName string) string {
 
 	return strings.Join(parts[:len(parts)-1], ".")
 }
+
+// contentMD5 returns base64-encoded MD5 hash of the data,
+// in the format of the Content-MD5 header.
+func contentMD5(data []byte) string {
+	sum := md5.Sum(data)
+	return base64.StdEncoding.EncodeToString(sum[:])
+}
+
+// mergeMetadata returns a new metadata map with the values of both maps,
+// values of the extra map take precedence.
+func mergeMetadata(metadata, extra map[string]string) map[string]string {
+	merged := make(map[string]string, len(metadata)+len(extra))
+	for k, v := range metadata {
+		merged[k] = v
+	}
+	for k, v := range extra {
+		merged[k] = v
+	}
+
+	return merged
+}
@@ -2,6 +2,7 @@ package storage_test
 
 import (
 	"bytes"
+	"io"
 	"os"
 	"testing"
 
@@ -34,6 +35,44 @@ func TestGetFileContentType(t *testing.T) {
 	})
 }
 
+func TestGetFileContentTypeByBytes(t *testing.T) {
+	_, err := storage.GetFileContentTypeByBytes(nil)
+	assert.ErrorIs(t, err, storage.ErrFileEmpty)
+
+	_, err = storage.GetFileContentTypeByBytes([]byte{})
+	assert.ErrorIs(t, err, storage.ErrFileEmpty)
+
+	contentType, err := storage.GetFileContentTypeByBytes([]byte("abc"))
+	assert.NoError(t, err)
+	assert.Equal(t, "text/plain", contentType)
+
+	contentType, err = storage.GetFileContentTypeByBytes([]byte{0x00, 0xfe, 0x01})
+	assert.NoError(t, err)
+	assert.Equal(t, "application/octet-stream", contentType)
+}
+
+func TestDetectAndReplay(t *testing.T) {
+	t.Run("Test Case 1 - Invalid Reader", func(t *testing.T) {
+		contentType, r, err := storage.DetectAndReplay(nil)
+		assert.Error(t, err)
+		assert.Equal(t, "", contentType)
+		assert.Nil(t, r)
+	})
+
+	t.Run("Test Case 2 - Content is replayed", func(t *testing.T) {
+		fileBytes, err := os.ReadFile("testdata/image.png")
+		assert.NoError(t, err)
+
+		contentType, r, err := storage.DetectAndReplay(bytes.NewReader(fileBytes))
+		assert.NoError(t, err)
+		assert.Equal(t, "image/png", contentType)
+
+		data, err := io.ReadAll(r)
+		assert.NoError(t, err)
+		assert.Equal(t, fileBytes, data)
+	})
+}
+
 func TestGetMaxFileParts(t *testing.T) {
 	t.Run("Test Case 1 - Invalid Reader", func(t *testing.T) {
 		maxParts, err := storage.GetMaxFileParts(nil, 5*1024*1024)
@@ -55,3 +94,100 @@ func TestGetMaxFileParts(t *testing.T) {
 		assert.Equal(t, 6, maxParts)
 	})
 }
+
+func TestCalculateOptimalPartSize(t *testing.T) {
+	t.Run("Test Case 1 - Empty file", func(t *testing.T) {
+		_, err := storage.CalculateOptimalPartSize(0)
+		assert.ErrorIs(t, err, storage.ErrFileEmpty)
+	})
+
+	t.Run("Test Case 2 - Small file", func(t *testing.T) {
+		partSize, err := storage.CalculateOptimalPartSize(1024)
+		assert.NoError(t, err)
+		assert.Equal(t, storage.MinPartSize, partSize)
+	})
+
+	t.Run("Test Case 3 - Large file", func(t *testing.T) {
+		fileSize := int64(100 * 1024 * 1024 * 1024) // 100GB
+		partSize, err := storage.CalculateOptimalPartSize(fileSize)
+		assert.NoError(t, err)
+		assert.Equal(t, int64(11*1024*1024), partSize)
+		assert.LessOrEqual(t, (fileSize+partSize-1)/partSize, storage.MaxParts)
+	})
+
+	t.Run("Test Case 4 - Too large file", func(t *testing.T) {
+		_, err := storage.CalculateOptimalPartSize(storage.MaxParts*storage.MaxPartSize + 1)
+		assert.ErrorIs(t, err, storage.ErrFileTooLarge)
+	})
+}
+
+func TestValidateUpload(t *testing.T) {
+	image, err := os.ReadFile("testdata/image.png")
+	assert.NoError(t, err)
+
+	t.Run("Test Case 1 - Empty file", func(t *testing.T) {
+		_, err := storage.ValidateUpload("image.png", nil, nil)
+		assert.ErrorIs(t, err, storage.ErrFileEmpty)
+	})
+
+	t.Run("Test Case 2 - Allowed type", func(t *testing.T) {
+		contentType, err := storage.ValidateUpload("image.png", image, []string{"image/*"})
+		assert.NoError(t, err)
+		assert.Equal(t, "image/png", contentType)
+	})
+
+	t.Run("Test Case 3 - Not allowed type", func(t *testing.T) {
+		_, err := storage.ValidateUpload("text.txt", []byte("Hello, World!"), []string{"image/png", "image/jpeg"})
+		assert.ErrorIs(t, err, storage.ErrInvalidContentType)
+	})
+
+	t.Run("Test Case 4 - Extension mismatch", func(t *testing.T) {
+		_, err := storage.ValidateUpload("image.jpg", image, []string{"image/*"})
+		assert.ErrorIs(t, err, storage.ErrExtensionMismatch)
+	})
+
+	t.Run("Test Case 5 - Extension fallback", func(t *testing.T) {
+		contentType, err := storage.ValidateUpload("data.wasm", []byte{0x00, 0x01, 0x02, 0x03}, nil)
+		assert.NoError(t, err)
+		assert.Equal(t, "application/wasm", contentType)
+	})
+}
+
+func TestACLIsValid(t *testing.T) {
+	assert.True(t, storage.Public.IsValid())
+	assert.True(t, storage.Private.IsValid())
+	assert.True(t, storage.ACL("bucket-owner-full-control").IsValid())
+	assert.False(t, storage.ACL("").IsValid())
+	assert.False(t, storage.ACL("public").IsValid())
+}
+
+func TestExactSizeReader(t *testing.T) {
+	data := []byte("0123456789")
+
+	t.Run("exact", func(t *testing.T) {
+		got, err := io.ReadAll(storage.ExactSizeReader(bytes.NewReader(data), 10))
+		assert.NoError(t, err)
+		assert.Equal(t, data, got)
+	})
+
+	t.Run("shorter", func(t *testing.T) {
+		_, err := io.ReadAll(storage.ExactSizeReader(bytes.NewReader(data), 11))
+		assert.ErrorIs(t, err, storage.ErrSizeMismatch)
+	})
+
+	t.Run("longer", func(t *testing.T) {
+		_, err := io.ReadAll(storage.ExactSizeReader(bytes.NewReader(data), 9))
+		assert.ErrorIs(t, err, storage.ErrSizeMismatch)
+	})
+
+	t.Run("read full", func(t *testing.T) {
+		buf := make([]byte, 5)
+		r := storage.ExactSizeReader(io.MultiReader(bytes.NewReader(data[:3]), bytes.NewReader(data[3:])), 10)
+		for i := 0; i < 2; i++ {
+			_, err := io.ReadFull(r, buf)
+			assert.NoError(t, err)
+		}
+		_, err := io.ReadFull(r, buf)
+		assert.ErrorIs(t, err, io.EOF)
+	})
+}
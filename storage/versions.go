@@ -0,0 +1,69 @@
+package storage
+
+import (
+	"sort"
+	"time"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/s3"
+	"github.com/pkg/errors"
+)
+
+// ObjectVersion describes a single version of an object in a versioned bucket.
+type ObjectVersion struct {
+	Key            string
+	VersionID      string
+	IsLatest       bool
+	IsDeleteMarker bool
+	Size           int64
+	ETag           string
+	LastModified   time.Time
+}
+
+// ListVersions returns all versions and delete markers of the objects with the given prefix.
+// Versions of the same key are returned from the newest to the oldest.
+func (i *Interactor) ListVersions(prefix string) ([]ObjectVersion, error) {
+	input := &s3.ListObjectVersionsInput{
+		Bucket: aws.String(i.bucket),
+		Prefix: aws.String(prefix),
+	}
+	if err := input.Validate(); err != nil {
+		return nil, errors.Wrap(err, "storage.listVersions")
+	}
+
+	var versions []ObjectVersion
+	if err := i.s3.ListObjectVersionsPagesWithContext(aws.BackgroundContext(), input, func(page *s3.ListObjectVersionsOutput, _ bool) bool {
+		for _, v := range page.Versions {
+			versions = append(versions, ObjectVersion{
+				Key:          aws.StringValue(v.Key),
+				VersionID:    aws.StringValue(v.VersionId),
+				IsLatest:     aws.BoolValue(v.IsLatest),
+				Size:         aws.Int64Value(v.Size),
+				ETag:         aws.StringValue(v.ETag),
+				LastModified: aws.TimeValue(v.LastModified),
+			})
+		}
+		for _, m := range page.DeleteMarkers {
+			versions = append(versions, ObjectVersion{
+				Key:            aws.StringValue(m.Key),
+				VersionID:      aws.StringValue(m.VersionId),
+				IsLatest:       aws.BoolValue(m.IsLatest),
+				IsDeleteMarker: true,
+				LastModified:   aws.TimeValue(m.LastModified),
+			})
+		}
+		return true
+	}, i.requestPayerHeader()...); err != nil {
+		return nil, errors.Wrap(err, "storage.listVersions")
+	}
+
+	// Delete markers are returned separately from versions, so merge them back in order.
+	sort.SliceStable(versions, func(a, b int) bool {
+		if versions[a].Key != versions[b].Key {
+			return versions[a].Key < versions[b].Key
+		}
+		return versions[a].LastModified.After(versions[b].LastModified)
+	})
+
+	return versions, nil
+}
@@ -1,15 +1,23 @@
 package gofs
 
+import "context"
+
 // DB is the interface for the storage database.
 // The database is used to store the status of multipart uploads.
 type DB interface {
 	// CreateUpload creates a new multipart upload.
 	CreateUpload(key string, uploadID string, totalParts int64) error
 
-	// AddPart adds a new part to the multipart upload.
-	AddPart(key string, partNumber int64, etag string) error
+	// UpdateTotalParts changes the expected number of parts of the upload,
+	// e.g. when the size of a streamed file turns out larger than expected.
+	// Returns ErrTotalPartsTooSmall if a part with a greater number is already uploaded.
+	UpdateTotalParts(key string, totalParts int64) error
+
+	// AddPart adds a new part of the given size in bytes to the multipart upload.
+	AddPart(key string, partNumber int64, etag string, size int64) error
 
 	// CompleteUpload completes the multipart upload.
+	// Returns ErrIncompleteUpload if any part is missed.
 	CompleteUpload(key string) error
 
 	// AbortUpload aborts the multipart upload.
@@ -18,11 +26,65 @@ type DB interface {
 	// GetUploadID returns the upload ID for the given key.
 	GetUploadID(key string) (string, error)
 
-	// GetParts returns the parts for the given key.
+	// GetParts returns the parts for the given key, sorted by part number.
 	GetParts(key string) ([]CompletedPart, error)
 
 	// GetStatus returns the status of the given key.
 	GetStatus(key string) (UploadStatus, error)
+
+	// ListUploads returns the keys of all in-progress uploads.
+	ListUploads() ([]string, error)
+
+	// Close releases the database resources.
+	Close() error
+}
+
+// ConcurrentDB is an optional extension of DB implemented by the databases
+// which add the parts with the compare-and-set semantics, so several instances
+// can upload the parts of the same upload without overwriting each other.
+// Check for it with a type assertion.
+type ConcurrentDB interface {
+	DB
+
+	// AddPartIfAbsent adds the part unless the part with the same number is already added.
+	// Adding the part with the same ETag again succeeds,
+	// the part with another ETag is rejected with ErrPartConflict.
+	AddPartIfAbsent(key string, partNumber int64, etag string, size int64) error
+}
+
+// ContextDB is an optional extension of DB implemented by the persistent databases
+// which respect the cancellation and the deadline of the context.
+// The Uploader uses it when available and falls back to the DB methods otherwise.
+// Check for it with a type assertion.
+type ContextDB interface {
+	DB
+
+	// CreateUploadWithContext is CreateUpload with the context.
+	CreateUploadWithContext(ctx context.Context, key string, uploadID string, totalParts int64) error
+
+	// UpdateTotalPartsWithContext is UpdateTotalParts with the context.
+	UpdateTotalPartsWithContext(ctx context.Context, key string, totalParts int64) error
+
+	// AddPartWithContext is AddPart with the context.
+	AddPartWithContext(ctx context.Context, key string, partNumber int64, etag string, size int64) error
+
+	// CompleteUploadWithContext is CompleteUpload with the context.
+	CompleteUploadWithContext(ctx context.Context, key string) error
+
+	// AbortUploadWithContext is AbortUpload with the context.
+	AbortUploadWithContext(ctx context.Context, key string) error
+
+	// GetUploadIDWithContext is GetUploadID with the context.
+	GetUploadIDWithContext(ctx context.Context, key string) (string, error)
+
+	// GetPartsWithContext is GetParts with the context.
+	GetPartsWithContext(ctx context.Context, key string) ([]CompletedPart, error)
+
+	// GetStatusWithContext is GetStatus with the context.
+	GetStatusWithContext(ctx context.Context, key string) (UploadStatus, error)
+
+	// ListUploadsWithContext is ListUploads with the context.
+	ListUploadsWithContext(ctx context.Context) ([]string, error)
 }
 
 // CompletedPart represents a part of a multipart upload.
@@ -31,9 +93,27 @@ type CompletedPart interface {
 	ETag() string
 }
 
+// SizedPart is an optional extension of CompletedPart implemented by the parts
+// which know their size. Check for it with a type assertion.
+type SizedPart interface {
+	CompletedPart
+
+	// Size returns the size of the part in bytes.
+	Size() int64
+}
+
 // UploadStatus represents the status of a multipart upload.
 type UploadStatus interface {
 	IsCompleted() bool
 	TotalParts() int64
 	CompletedPartsNum() int64
 }
+
+// SizedStatus is an optional extension of UploadStatus implemented by the statuses
+// which know the sizes of the uploaded parts. Check for it with a type assertion.
+type SizedStatus interface {
+	UploadStatus
+
+	// UploadedBytes returns the total size of the uploaded parts in bytes.
+	UploadedBytes() int64
+}
@@ -0,0 +1,849 @@
+package gofs
+
+import (
+	"context"
+	"errors"
+	"io"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/dmitrymomot/gofs/storage"
+	"golang.org/x/sync/errgroup"
+)
+
+// Default settings of the uploader.
+const (
+	DefaultMaxRetries   = 3
+	DefaultRetryBackoff = 500 * time.Millisecond
+	DefaultConcurrency  = 4
+)
+
+type (
+	// Storage is the cloud storage the uploader sends the files to.
+	// It's implemented by *storage.Interactor.
+	// The uploaded data is reused by the uploader, so it must not be retained after the call returns.
+	Storage interface {
+		UploadWithContext(ctx context.Context, file []byte, filepath string, opts storage.UploadOptions) (*storage.UploadResult, error)
+		CreateMultipartUploadWithContext(ctx context.Context, filename string, opts storage.UploadOptions) (string, error)
+		UploadPartWithContext(ctx context.Context, filename, uploadID string, data []byte, partNum, totalParts int64, opts storage.UploadPartOptions) (storage.CompletedPart, error)
+		CompleteMultipartUploadWithContext(ctx context.Context, filename, uploadID string, completedParts ...storage.CompletedPart) (*storage.UploadResult, error)
+		AbortMultipartUpload(filename, uploadID string) error
+		ListMultipartUploads(prefix string) ([]storage.MultipartUpload, error)
+		PartsReport(filename, uploadID string) ([]storage.PartReport, error)
+	}
+
+	// Uploader uploads files to the storage in parts,
+	// retrying failed requests and tracking the uploaded parts in the database.
+	Uploader struct {
+		storage      Storage
+		db           ContextDB
+		partSize     int64
+		maxRetries   int
+		retryBackoff time.Duration
+		jitter       JitterStrategy
+		concurrency  int
+		buffers      bufferPool
+		logger       storage.Logger
+		clock        clock
+
+		drainMu  sync.Mutex
+		draining bool
+		inflight sync.WaitGroup // running ManagedUpload calls
+	}
+
+	// partRecord tells how the uploaded part is recorded in the database.
+	partRecord int
+
+	// noopLogger discards all messages, it's the default logger.
+	noopLogger struct{}
+
+	// bufferPool reuses the part buffers across the uploads to spare the allocations.
+	// It keeps a bounded number of free buffers, the extra ones are left to the garbage collector.
+	bufferPool struct {
+		free chan []byte
+	}
+
+	// UploaderOption is a functional option of the uploader.
+	UploaderOption func(*Uploader)
+
+	// ProgressFunc is called with the number of the uploaded bytes and the total size of the file.
+	ProgressFunc func(uploaded, total int64)
+
+	// UploadPlan describes how ManagedUpload splits the file into parts.
+	UploadPlan struct {
+		PartSize     int64
+		TotalParts   int64
+		LastPartSize int64
+		Multipart    bool // false if the file is uploaded with a single request
+	}
+)
+
+var _ Storage = (*storage.Interactor)(nil)
+
+// NewUploader creates a new uploader.
+func NewUploader(s Storage, db DB, opts ...UploaderOption) *Uploader {
+	u := &Uploader{
+		storage:      s,
+		db:           withContext(db),
+		maxRetries:   DefaultMaxRetries,
+		retryBackoff: DefaultRetryBackoff,
+		jitter:       JitterFull,
+		concurrency:  DefaultConcurrency,
+		logger:       noopLogger{},
+		clock:        systemClock,
+	}
+
+	for _, opt := range opts {
+		opt(u)
+	}
+	u.buffers = newBufferPool(u.concurrency + 2)
+
+	return u
+}
+
+// WithPartSize sets the size of the uploaded parts.
+// By default it's storage.CalculateOptimalPartSize of the file size if the size is known,
+// and storage.MinPartSize otherwise.
+func WithPartSize(size int64) UploaderOption {
+	return func(u *Uploader) {
+		if size > 0 {
+			u.partSize = size
+		}
+	}
+}
+
+// WithMaxRetries sets the number of retries of a failed request.
+// Default is DefaultMaxRetries, zero disables retries.
+func WithMaxRetries(n int) UploaderOption {
+	return func(u *Uploader) {
+		if n >= 0 {
+			u.maxRetries = n
+		}
+	}
+}
+
+// WithRetryBackoff sets the delay before the first retry, it's doubled for every next retry.
+// Default is DefaultRetryBackoff.
+func WithRetryBackoff(d time.Duration) UploaderOption {
+	return func(u *Uploader) {
+		if d >= 0 {
+			u.retryBackoff = d
+		}
+	}
+}
+
+// WithJitter sets the strategy randomizing the retry delays, an invalid strategy is ignored.
+// Default is JitterFull.
+func WithJitter(s JitterStrategy) UploaderOption {
+	return func(u *Uploader) {
+		if s.IsValid() {
+			u.jitter = s
+		}
+	}
+}
+
+// WithLogger sets the logger to report the retries with their delays.
+// By default nothing is logged.
+func WithLogger(l storage.Logger) UploaderOption {
+	return func(u *Uploader) {
+		if l != nil {
+			u.logger = l
+		}
+	}
+}
+
+// WithConcurrency sets the number of parts uploaded in parallel.
+// Every part in flight is buffered in memory: an upload holds up to concurrency+2 part buffers,
+// the parts being uploaded and the ones being read. Up to concurrency+2 buffers are kept for the next uploads.
+// Default is DefaultConcurrency.
+func WithConcurrency(n int) UploaderOption {
+	return func(u *Uploader) {
+		if n > 0 {
+			u.concurrency = n
+		}
+	}
+}
+
+// UploadFile uploads the content of r to the storage under the given key.
+// The content that fits into a single part is uploaded with a single request,
+// otherwise it's uploaded in parts in parallel, every failed request is retried with the backoff.
+// If r is an io.Seeker, its size is known in advance and the upload is tracked in the database:
+// the parts are recorded as they complete.
+// If the upload fails or ctx is canceled, the multipart upload is aborted
+// and removed from the database.
+func (u *Uploader) UploadFile(ctx context.Context, r io.Reader, key, contentType string, acl storage.ACL) (err error) {
+	if r == nil {
+		return storage.ErrInvalidReader
+	}
+	if key == "" {
+		return ErrFileKeyEmpty
+	}
+
+	opts := storage.UploadOptions{ACL: acl, ContentType: contentType}
+
+	partSize := u.partSize
+	var totalParts int64 // unknown
+	if rs, ok := r.(io.ReadSeeker); ok {
+		size, err := storage.GetFileSize(rs)
+		if err != nil {
+			return err
+		}
+		if partSize == 0 && size > 0 {
+			if partSize, err = storage.CalculateOptimalPartSize(size); err != nil {
+				return err
+			}
+		}
+		if partSize > 0 {
+			totalParts = (size + partSize - 1) / partSize
+		}
+	}
+	if partSize == 0 {
+		partSize = storage.MinPartSize
+	}
+
+	first, err := u.readPart(r, partSize)
+	if err != nil {
+		return err
+	}
+	if int64(len(first)) < partSize || totalParts == 1 {
+		defer u.buffers.put(first)
+		return u.retry(ctx, func() error {
+			_, err := u.storage.UploadWithContext(ctx, first, key, opts)
+			return err
+		})
+	}
+
+	uploadID, err := u.storage.CreateMultipartUploadWithContext(ctx, key, opts)
+	if err != nil {
+		u.buffers.put(first)
+		return err
+	}
+	tracked, record := totalParts > 1, recordNone
+	if tracked {
+		record = recordIfAbsent
+		if err := u.db.CreateUploadWithContext(ctx, key, uploadID, totalParts); err != nil {
+			u.buffers.put(first)
+			u.abortFailedUpload(key, uploadID)
+			return err
+		}
+	}
+	defer func() {
+		if err != nil {
+			u.abortFailedUpload(key, uploadID)
+			if tracked {
+				// The context may be already canceled.
+				_ = u.db.AbortUploadWithContext(context.Background(), key)
+			}
+		}
+	}()
+
+	var (
+		mu    sync.Mutex
+		parts []storage.CompletedPart
+	)
+
+	g, gctx := errgroup.WithContext(ctx)
+	g.SetLimit(u.concurrency)
+
+	var readErr error
+	data := first
+	for partNum := int64(1); gctx.Err() == nil; partNum++ {
+		var next []byte
+		if next, readErr = u.readPart(r, partSize); readErr != nil {
+			u.buffers.put(data)
+			break
+		}
+
+		// The storage needs the total number of parts to validate the part:
+		// if it's unknown, the part is declared to be followed by another one until the last part is read.
+		partTotal := totalParts
+		if partTotal == 0 {
+			partTotal = partNum
+			if len(next) > 0 {
+				partTotal++
+			}
+		}
+
+		partNum, partData := partNum, data
+		g.Go(func() error {
+			defer u.buffers.put(partData)
+
+			part, err := u.uploadPart(gctx, key, uploadID, partData, partNum, partTotal, record)
+			if err != nil {
+				return err
+			}
+
+			mu.Lock()
+			parts = append(parts, part)
+			mu.Unlock()
+
+			return nil
+		})
+
+		if len(next) == 0 {
+			u.buffers.put(next)
+			break
+		}
+		data = next
+	}
+
+	if err := g.Wait(); err != nil {
+		return err
+	}
+	if readErr != nil {
+		return readErr
+	}
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+
+	sort.Slice(parts, func(i, j int) bool {
+		return parts[i].PartNumber() < parts[j].PartNumber()
+	})
+	if err := u.retry(ctx, func() error {
+		_, err := u.storage.CompleteMultipartUploadWithContext(ctx, key, uploadID, parts...)
+		return err
+	}); err != nil {
+		return err
+	}
+
+	if tracked {
+		return u.db.CompleteUploadWithContext(ctx, key)
+	}
+
+	return nil
+}
+
+// PlanUpload returns the parts ManagedUpload splits the file of the given size into,
+// without any request to the storage, e.g. to show the upload plan to the user.
+// The part size is the one set by WithPartSize or the optimal one for the file size.
+// Returns storage.ErrFileTooLarge if the file can't be uploaded within the max number of parts.
+func (u *Uploader) PlanUpload(fileSize int64) (UploadPlan, error) {
+	if fileSize <= 0 {
+		return UploadPlan{}, storage.ErrFileEmpty
+	}
+
+	partSize := u.partSize
+	if partSize == 0 {
+		var err error
+		if partSize, err = storage.CalculateOptimalPartSize(fileSize); err != nil {
+			return UploadPlan{}, err
+		}
+	}
+	totalParts := (fileSize + partSize - 1) / partSize
+	if totalParts > storage.MaxParts {
+		return UploadPlan{}, storage.ErrFileTooLarge
+	}
+
+	return UploadPlan{
+		PartSize:     partSize,
+		TotalParts:   totalParts,
+		LastPartSize: fileSize - (totalParts-1)*partSize,
+		Multipart:    totalParts > 1,
+	}, nil
+}
+
+// ManagedUpload uploads size bytes of r to the storage under the given key in parts in parallel,
+// reporting the progress and completing the upload once all the parts are uploaded.
+// The upload is tracked in the database: if it fails, e.g. the process crashes or ctx is canceled,
+// it's left in progress, and calling ManagedUpload again with the same key and content
+// uploads the missed parts only. An upload which is gone from the storage is started over.
+// Use CleanupStaleUploads to abort the abandoned uploads.
+// The progress (optional) includes the parts uploaded before the resume,
+// it's never called concurrently.
+// Returns ErrUploaderDraining if the uploader is drained, see Drain.
+func (u *Uploader) ManagedUpload(ctx context.Context, r io.ReaderAt, size int64, key, contentType string, acl storage.ACL, progress ProgressFunc) error {
+	if !u.begin() {
+		return ErrUploaderDraining
+	}
+	defer u.inflight.Done()
+
+	if r == nil {
+		return storage.ErrInvalidReader
+	}
+	if key == "" {
+		return ErrFileKeyEmpty
+	}
+	if size <= 0 {
+		return storage.ErrFileEmpty
+	}
+	if progress == nil {
+		progress = func(uploaded, total int64) {}
+	}
+
+	plan, err := u.PlanUpload(size)
+	if err != nil {
+		return err
+	}
+	partSize, totalParts := plan.PartSize, plan.TotalParts
+	opts := storage.UploadOptions{ACL: acl, ContentType: contentType}
+
+	if !plan.Multipart {
+		data := u.buffers.get(size)
+		defer u.buffers.put(data)
+		if err := readAtFull(r, data, 0); err != nil {
+			return err
+		}
+		if err := u.retry(ctx, func() error {
+			_, err := u.storage.UploadWithContext(ctx, data, key, opts)
+			return err
+		}); err != nil {
+			return err
+		}
+		progress(size, size)
+		return nil
+	}
+
+	partLen := func(partNum int64) int64 {
+		if partNum == totalParts {
+			return plan.LastPartSize
+		}
+		return partSize
+	}
+
+	uploadID, done, err := u.resumeUpload(ctx, key, totalParts, partLen, opts)
+	if err != nil {
+		return err
+	}
+
+	var (
+		mu       sync.Mutex
+		uploaded int64
+	)
+	for partNum, ok := range done {
+		if ok {
+			uploaded += partLen(partNum)
+		}
+	}
+	progress(uploaded, size)
+
+	// The slot is taken before the drain check, so the parts waiting for it aren't started after Drain.
+	g, gctx := errgroup.WithContext(ctx)
+	slots := make(chan struct{}, u.concurrency)
+	draining := false
+	for partNum := int64(1); partNum <= totalParts && gctx.Err() == nil; partNum++ {
+		if done[partNum] {
+			continue
+		}
+
+		select {
+		case slots <- struct{}{}:
+		case <-gctx.Done():
+			continue
+		}
+		// The in-flight parts are uploaded, the rest are left for the resume.
+		if draining = u.isDraining(); draining {
+			break
+		}
+
+		// The stale record of the part is replaced by the new one.
+		record := recordIfAbsent
+		if _, stale := done[partNum]; stale {
+			record = recordReplace
+		}
+
+		partNum := partNum
+		g.Go(func() error {
+			defer func() { <-slots }()
+
+			data := u.buffers.get(partLen(partNum))
+			defer u.buffers.put(data)
+
+			if err := readAtFull(r, data, (partNum-1)*partSize); err != nil {
+				return err
+			}
+			if _, err := u.uploadPart(gctx, key, uploadID, data, partNum, totalParts, record); err != nil {
+				return err
+			}
+
+			mu.Lock()
+			uploaded += int64(len(data))
+			progress(uploaded, size)
+			mu.Unlock()
+
+			return nil
+		})
+	}
+	if err := g.Wait(); err != nil {
+		return err
+	}
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	if draining {
+		return ErrUploaderDraining
+	}
+
+	dbParts, err := u.db.GetPartsWithContext(ctx, key)
+	if err != nil {
+		return err
+	}
+	parts := make([]storage.CompletedPart, 0, len(dbParts))
+	for _, part := range dbParts {
+		parts = append(parts, part)
+	}
+	if err := u.retry(ctx, func() error {
+		_, err := u.storage.CompleteMultipartUploadWithContext(ctx, key, uploadID, parts...)
+		return err
+	}); err != nil {
+		return err
+	}
+
+	return u.db.CompleteUploadWithContext(ctx, key)
+}
+
+// Drain prepares the uploader for the shutdown: the running ManagedUpload calls stop starting new parts
+// and return ErrUploaderDraining once their in-flight parts are uploaded,
+// the new calls are rejected right away. The uploaded parts are recorded in the database,
+// so the uploads are resumed by ManagedUpload with the same keys after the restart.
+// Drain waits for the running calls until ctx is done and returns ctx error if they are still running.
+// UploadFile isn't affected, its uploads can't be resumed.
+func (u *Uploader) Drain(ctx context.Context) error {
+	u.drainMu.Lock()
+	u.draining = true
+	u.drainMu.Unlock()
+
+	done := make(chan struct{})
+	go func() {
+		u.inflight.Wait()
+		close(done)
+	}()
+
+	select {
+	case <-done:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// begin registers a ManagedUpload call unless the uploader is draining.
+// The registration is done under the lock, so it never races with Drain waiting for the calls.
+func (u *Uploader) begin() bool {
+	u.drainMu.Lock()
+	defer u.drainMu.Unlock()
+
+	if u.draining {
+		return false
+	}
+	u.inflight.Add(1)
+
+	return true
+}
+
+// abortFailedUpload aborts the upload the uploader has failed to complete or started over.
+// The storage implementing AbortFailedUpload, like *storage.Interactor, aborts it even in the dry-run mode,
+// otherwise the parts of the upload would occupy the storage.
+func (u *Uploader) abortFailedUpload(key, uploadID string) {
+	if s, ok := u.storage.(interface {
+		AbortFailedUpload(filename, uploadID string) error
+	}); ok {
+		_ = s.AbortFailedUpload(key, uploadID)
+		return
+	}
+	_ = u.storage.AbortMultipartUpload(key, uploadID)
+}
+
+// dryRun reports whether the storage runs the destructive operations in the dry-run mode.
+func (u *Uploader) dryRun() bool {
+	s, ok := u.storage.(interface{ DryRun() bool })
+	return ok && s.DryRun()
+}
+
+// isDraining reports whether Drain is called.
+func (u *Uploader) isDraining() bool {
+	u.drainMu.Lock()
+	defer u.drainMu.Unlock()
+
+	return u.draining
+}
+
+// resumeUpload returns the ID and the uploaded part numbers of the upload tracked in the database,
+// or starts a new upload if there is none. The tracked upload is started over
+// if it's gone from the storage or has another number of parts, i.e. the content differs.
+// The parts of the known size other than partLen are stale: they are mapped to false
+// and must be uploaded again.
+func (u *Uploader) resumeUpload(ctx context.Context, key string, totalParts int64, partLen func(partNum int64) int64, opts storage.UploadOptions) (string, map[int64]bool, error) {
+	uploadID, err := u.db.GetUploadIDWithContext(ctx, key)
+	switch {
+	case err == nil:
+		status, err := u.db.GetStatusWithContext(ctx, key)
+		if err != nil {
+			return "", nil, err
+		}
+		exists, err := u.uploadExists(key, uploadID)
+		if err != nil {
+			return "", nil, err
+		}
+		if exists && status.TotalParts() == totalParts {
+			parts, err := u.db.GetPartsWithContext(ctx, key)
+			if err != nil {
+				return "", nil, err
+			}
+			done := make(map[int64]bool, len(parts))
+			for _, part := range parts {
+				sized, ok := part.(SizedPart)
+				done[part.PartNumber()] = !ok || sized.Size() == partLen(part.PartNumber())
+			}
+			return uploadID, done, nil
+		}
+
+		if exists {
+			u.abortFailedUpload(key, uploadID)
+		}
+		if err := u.db.AbortUploadWithContext(ctx, key); err != nil {
+			return "", nil, err
+		}
+	case !errors.Is(err, ErrNotFound):
+		return "", nil, err
+	}
+
+	if err := u.retry(ctx, func() (err error) {
+		uploadID, err = u.storage.CreateMultipartUploadWithContext(ctx, key, opts)
+		return err
+	}); err != nil {
+		return "", nil, err
+	}
+	if err := u.db.CreateUploadWithContext(ctx, key, uploadID, totalParts); err != nil {
+		u.abortFailedUpload(key, uploadID)
+		return "", nil, err
+	}
+
+	return uploadID, map[int64]bool{}, nil
+}
+
+// uploadExists reports whether the multipart upload is still in progress in the storage.
+func (u *Uploader) uploadExists(key, uploadID string) (bool, error) {
+	uploads, err := u.storage.ListMultipartUploads(key)
+	if err != nil {
+		return false, err
+	}
+	for _, upload := range uploads {
+		if upload.Key == key && upload.UploadID == uploadID {
+			return true, nil
+		}
+	}
+
+	return false, nil
+}
+
+// CleanupStaleUploads aborts the multipart uploads started more than olderThan ago
+// and removes them from the database. Such uploads are left by crashed or abandoned clients,
+// their parts occupy the storage until the upload is aborted.
+// A failed abort doesn't stop the cleanup: returns the number of the aborted uploads
+// and *storage.BatchError of the failed ones by their upload IDs, since a key may have several uploads.
+// In the dry-run mode of the storage the uploads are only counted, the database is left intact.
+func (u *Uploader) CleanupStaleUploads(olderThan time.Duration) (int, error) {
+	uploads, err := u.storage.ListMultipartUploads("")
+	if err != nil {
+		return 0, err
+	}
+
+	deadline := u.clock.Now().Add(-olderThan)
+	aborted, dryRun := 0, u.dryRun()
+	failed := make(map[string]error)
+	for _, upload := range uploads {
+		if !upload.Initiated.Before(deadline) {
+			continue
+		}
+
+		if err := u.storage.AbortMultipartUpload(upload.Key, upload.UploadID); err != nil {
+			failed[upload.UploadID] = err
+			continue
+		}
+		aborted++
+		if dryRun {
+			continue
+		}
+
+		// The key may be tracked by another, newer upload.
+		if uploadID, err := u.db.GetUploadIDWithContext(context.Background(), upload.Key); err == nil && uploadID == upload.UploadID {
+			if err := u.db.AbortUploadWithContext(context.Background(), upload.Key); err != nil {
+				failed[upload.UploadID] = err
+			}
+		}
+	}
+
+	if len(failed) > 0 {
+		return aborted, &storage.BatchError{Errors: failed}
+	}
+
+	return aborted, nil
+}
+
+// Part recording modes of uploadPart.
+const (
+	recordNone     partRecord = iota // the upload isn't tracked
+	recordIfAbsent                   // the part recorded with another ETag fails with ErrPartConflict
+	recordReplace                    // the stale record of the part is overwritten
+)
+
+// uploadPart uploads the part with retries and records it in the database as requested.
+func (u *Uploader) uploadPart(ctx context.Context, key, uploadID string, data []byte, partNum, totalParts int64, record partRecord) (storage.CompletedPart, error) {
+	var part storage.CompletedPart
+	if err := u.retry(ctx, func() (err error) {
+		part, err = u.storage.UploadPartWithContext(ctx, key, uploadID, data, partNum, totalParts, storage.UploadPartOptions{})
+		return err
+	}); err != nil {
+		return nil, err
+	}
+
+	size := int64(len(data))
+	switch record {
+	case recordIfAbsent:
+		err := addPartIfAbsent(ctx, u.db, key, part.PartNumber(), part.ETag(), size)
+		if errors.Is(err, ErrPartConflict) {
+			err = u.syncConflictingPart(ctx, key, uploadID, part.PartNumber(), err)
+		}
+		if err != nil {
+			return nil, err
+		}
+	case recordReplace:
+		if err := u.db.AddPartWithContext(ctx, key, part.PartNumber(), part.ETag(), size); err != nil {
+			return nil, err
+		}
+	}
+
+	return part, nil
+}
+
+// syncConflictingPart records the part as the storage keeps it after the conflicting uploads
+// and returns the conflict error. The storage keeps the last uploaded part, which isn't necessarily
+// the recorded one: the completion with the stale ETag would fail.
+func (u *Uploader) syncConflictingPart(ctx context.Context, key, uploadID string, partNum int64, conflict error) error {
+	report, err := u.storage.PartsReport(key, uploadID)
+	if err != nil {
+		return err
+	}
+	for _, part := range report {
+		if part.PartNumber == partNum {
+			if err := u.db.AddPartWithContext(ctx, key, partNum, part.ETag, part.Size); err != nil {
+				return err
+			}
+			break
+		}
+	}
+
+	return conflict
+}
+
+// retry calls fn until it succeeds, fails with a permanent error or the retries are exhausted.
+// The backoff between the attempts is doubled every time, the delay is randomized by the jitter strategy.
+func (u *Uploader) retry(ctx context.Context, fn func() error) error {
+	backoff := u.retryBackoff
+	for attempt := 0; ; attempt++ {
+		err := fn()
+		if err == nil {
+			return nil
+		}
+		if ctx.Err() != nil {
+			return ctx.Err()
+		}
+		if attempt >= u.maxRetries || !isRetryable(err) {
+			return err
+		}
+
+		delay := u.jitter.Delay(backoff)
+		u.logger.Debugf("gofs: retry %d of %d in %s: %v", attempt+1, u.maxRetries, delay, err)
+
+		timer := time.NewTimer(delay)
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return ctx.Err()
+		case <-timer.C:
+		}
+		backoff *= 2
+	}
+}
+
+// isRetryable reports whether the request can succeed if it's repeated:
+// the invalid requests fail the same way every time.
+func isRetryable(err error) bool {
+	for _, permanent := range []error{
+		storage.ErrFileEmpty,
+		storage.ErrMissedUploadID,
+		storage.ErrTotalParts,
+		storage.ErrPartNum,
+		storage.ErrPartTooSmall,
+		storage.ErrPartTooLarge,
+		storage.ErrPartEmpty,
+		storage.ErrNoCompletedParts,
+		storage.ErrInvalidRetention,
+		storage.ErrInvalidPart,
+		storage.ErrEncryptionMismatch,
+		storage.ErrEncryptionUnsupported,
+		storage.ErrChecksumUnsupported,
+	} {
+		if errors.Is(err, permanent) {
+			return false
+		}
+	}
+
+	return true
+}
+
+// readAtFull fills data from r at the given offset.
+// Returns storage.ErrSizeMismatch if r ends before, i.e. it's shorter than the declared size.
+func readAtFull(r io.ReaderAt, data []byte, off int64) error {
+	n, err := r.ReadAt(data, off)
+	if n == len(data) {
+		// ReadAt may return io.EOF along with the last bytes.
+		return nil
+	}
+	if err != nil && err != io.EOF {
+		return err
+	}
+
+	return storage.ErrSizeMismatch
+}
+
+// readPart reads up to size bytes from r into a pooled buffer.
+// The returned part is shorter than size only if r is exhausted.
+func (u *Uploader) readPart(r io.Reader, size int64) ([]byte, error) {
+	buf := u.buffers.get(size)
+	n, err := io.ReadFull(r, buf)
+	if err != nil && err != io.EOF && err != io.ErrUnexpectedEOF {
+		u.buffers.put(buf)
+		return nil, err
+	}
+
+	return buf[:n], nil
+}
+
+// newBufferPool creates a buffer pool keeping up to size free buffers.
+func newBufferPool(size int) bufferPool {
+	return bufferPool{free: make(chan []byte, size)}
+}
+
+// get returns a buffer of the given size, reusing a pooled one if it's big enough.
+func (p *bufferPool) get(size int64) []byte {
+	select {
+	case buf := <-p.free:
+		if int64(cap(buf)) >= size {
+			return buf[:size]
+		}
+	default:
+	}
+
+	return make([]byte, size)
+}
+
+// put zeroes the buffer and returns it to the pool,
+// so the data of an upload never leaks into another one.
+func (p *bufferPool) put(buf []byte) {
+	buf = buf[:cap(buf)]
+	for i := range buf {
+		buf[i] = 0
+	}
+
+	select {
+	case p.free <- buf:
+	default:
+		// The pool is full.
+	}
+}
+
+func (noopLogger) Debugf(string, ...interface{}) {}
+func (noopLogger) Errorf(string, ...interface{}) {}
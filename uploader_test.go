@@ -0,0 +1,810 @@
+package gofs_test
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"hash/crc32"
+	"io"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/dmitrymomot/gofs"
+	"github.com/dmitrymomot/gofs/storage"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type (
+	// fakeStorage keeps the uploaded objects in memory.
+	fakeStorage struct {
+		sync.Mutex
+		objects  map[string][]byte
+		parts    map[int64][]byte
+		aborted  bool
+		failures map[int64]int // number of failed attempts per part number
+		attempts map[int64]int
+		onPart   func(partNum int64)
+		uploads  []storage.MultipartUpload
+	}
+
+	// fakePart has the ETag derived from the part content, as the real storage has.
+	fakePart struct {
+		partNumber int64
+		data       []byte
+	}
+)
+
+func newFakeStorage() *fakeStorage {
+	return &fakeStorage{
+		objects:  make(map[string][]byte),
+		parts:    make(map[int64][]byte),
+		failures: make(map[int64]int),
+		attempts: make(map[int64]int),
+	}
+}
+
+func (s *fakeStorage) UploadWithContext(ctx context.Context, file []byte, filepath string, opts storage.UploadOptions) (*storage.UploadResult, error) {
+	s.Lock()
+	defer s.Unlock()
+
+	s.objects[filepath] = append([]byte(nil), file...)
+	return &storage.UploadResult{Key: filepath}, nil
+}
+
+func (s *fakeStorage) CreateMultipartUploadWithContext(ctx context.Context, filename string, opts storage.UploadOptions) (string, error) {
+	s.Lock()
+	defer s.Unlock()
+
+	uploadID := fmt.Sprintf("upload-id-%d", len(s.uploads)+1)
+	s.uploads = append(s.uploads, storage.MultipartUpload{Key: filename, UploadID: uploadID, Initiated: time.Now()})
+	return uploadID, nil
+}
+
+func (s *fakeStorage) UploadPartWithContext(ctx context.Context, filename, uploadID string, data []byte, partNum, totalParts int64, opts storage.UploadPartOptions) (storage.CompletedPart, error) {
+	if s.onPart != nil {
+		s.onPart(partNum)
+	}
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	if partNum > totalParts {
+		return nil, storage.ErrPartNum
+	}
+
+	s.Lock()
+	defer s.Unlock()
+
+	s.attempts[partNum]++
+	if s.attempts[partNum] <= s.failures[partNum] {
+		return nil, errors.New("connection reset")
+	}
+	s.parts[partNum] = append([]byte(nil), data...)
+
+	return fakePart{partNumber: partNum, data: s.parts[partNum]}, nil
+}
+
+func (s *fakeStorage) CompleteMultipartUploadWithContext(ctx context.Context, filename, uploadID string, completedParts ...storage.CompletedPart) (*storage.UploadResult, error) {
+	s.Lock()
+	defer s.Unlock()
+
+	var buf bytes.Buffer
+	for i, part := range completedParts {
+		if part.PartNumber() != int64(i+1) {
+			return nil, storage.ErrInvalidPart
+		}
+		stored := fakePart{partNumber: part.PartNumber(), data: s.parts[part.PartNumber()]}
+		if strings.Trim(part.ETag(), `"`) != stored.ETag() {
+			return nil, storage.ErrInvalidPart
+		}
+		buf.Write(s.parts[part.PartNumber()])
+	}
+	s.objects[filename] = buf.Bytes()
+	s.removeUpload(uploadID)
+
+	return &storage.UploadResult{Key: filename}, nil
+}
+
+func (s *fakeStorage) AbortMultipartUpload(filename, uploadID string) error {
+	s.Lock()
+	defer s.Unlock()
+
+	s.aborted = true
+	s.removeUpload(uploadID)
+	return nil
+}
+
+func (s *fakeStorage) ListMultipartUploads(prefix string) ([]storage.MultipartUpload, error) {
+	s.Lock()
+	defer s.Unlock()
+
+	var uploads []storage.MultipartUpload
+	for _, upload := range s.uploads {
+		if strings.HasPrefix(upload.Key, prefix) {
+			uploads = append(uploads, upload)
+		}
+	}
+	return uploads, nil
+}
+
+func (s *fakeStorage) PartsReport(filename, uploadID string) ([]storage.PartReport, error) {
+	s.Lock()
+	defer s.Unlock()
+
+	var parts []storage.PartReport
+	for partNum, data := range s.parts {
+		parts = append(parts, storage.PartReport{
+			PartNumber: partNum,
+			Size:       int64(len(data)),
+			ETag:       fmt.Sprintf(`"%s"`, fakePart{partNumber: partNum, data: data}.ETag()),
+		})
+	}
+	return parts, nil
+}
+
+func (s *fakeStorage) removeUpload(uploadID string) {
+	for i, upload := range s.uploads {
+		if upload.UploadID == uploadID {
+			s.uploads = append(s.uploads[:i], s.uploads[i+1:]...)
+			return
+		}
+	}
+}
+
+func (p fakePart) PartNumber() int64 {
+	return p.partNumber
+}
+
+func (p fakePart) ETag() string {
+	return fmt.Sprintf("etag-%d-%08x", p.partNumber, crc32.ChecksumIEEE(p.data))
+}
+
+func BenchmarkUploaderUploadFile(b *testing.B) {
+	data := bytes.Repeat([]byte("0123456789"), 1024*1024)
+	u := gofs.NewUploader(newFakeStorage(), gofs.NewInMemoryDB(), gofs.WithPartSize(1024*1024))
+
+	b.ReportAllocs()
+	b.SetBytes(int64(len(data)))
+	for i := 0; i < b.N; i++ {
+		if err := u.UploadFile(context.Background(), bytes.NewReader(data), "file.bin", "application/octet-stream", storage.Private); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+func TestUploaderUploadFile(t *testing.T) {
+	data := bytes.Repeat([]byte("0123456789"), 100)
+
+	t.Run("single request", func(t *testing.T) {
+		s := newFakeStorage()
+		u := gofs.NewUploader(s, gofs.NewInMemoryDB(), gofs.WithPartSize(2048))
+
+		require.NoError(t, u.UploadFile(context.Background(), bytes.NewReader(data), "file.txt", "text/plain", storage.Private))
+		assert.Equal(t, data, s.objects["file.txt"])
+		assert.Empty(t, s.parts)
+	})
+
+	t.Run("parts with retries", func(t *testing.T) {
+		s := newFakeStorage()
+		s.failures[2] = 2
+		db := gofs.NewInMemoryDB()
+		u := gofs.NewUploader(s, db, gofs.WithPartSize(128), gofs.WithRetryBackoff(0))
+
+		require.NoError(t, u.UploadFile(context.Background(), bytes.NewReader(data), "file.txt", "text/plain", storage.Private))
+		assert.Equal(t, data, s.objects["file.txt"])
+		assert.Len(t, s.parts, 8)
+		assert.Equal(t, 3, s.attempts[2])
+		assert.False(t, s.aborted)
+
+		// The completed upload is removed from the database.
+		_, err := db.GetUploadID("file.txt")
+		assert.ErrorIs(t, err, gofs.ErrNotFound)
+	})
+
+	t.Run("stream of unknown size", func(t *testing.T) {
+		s := newFakeStorage()
+		u := gofs.NewUploader(s, gofs.NewInMemoryDB(), gofs.WithPartSize(100), gofs.WithConcurrency(1))
+
+		require.NoError(t, u.UploadFile(context.Background(), io.MultiReader(bytes.NewReader(data)), "file.txt", "text/plain", storage.Private))
+		assert.Equal(t, data, s.objects["file.txt"])
+		assert.Len(t, s.parts, 10)
+	})
+
+	t.Run("retries exhausted", func(t *testing.T) {
+		s := newFakeStorage()
+		s.failures[3] = 10
+		db := gofs.NewInMemoryDB()
+		u := gofs.NewUploader(s, db, gofs.WithPartSize(128), gofs.WithRetryBackoff(0), gofs.WithMaxRetries(2))
+
+		assert.Error(t, u.UploadFile(context.Background(), bytes.NewReader(data), "file.txt", "text/plain", storage.Private))
+		assert.Equal(t, 3, s.attempts[3])
+		assert.True(t, s.aborted)
+		assert.NotContains(t, s.objects, "file.txt")
+
+		uploads, err := db.ListUploads()
+		require.NoError(t, err)
+		assert.Empty(t, uploads)
+	})
+
+	t.Run("canceled", func(t *testing.T) {
+		ctx, cancel := context.WithCancel(context.Background())
+		defer cancel()
+
+		s := newFakeStorage()
+		s.onPart = func(partNum int64) {
+			if partNum == 2 {
+				cancel()
+			}
+		}
+		u := gofs.NewUploader(s, gofs.NewInMemoryDB(), gofs.WithPartSize(128), gofs.WithConcurrency(1))
+
+		assert.ErrorIs(t, u.UploadFile(ctx, bytes.NewReader(data), "file.txt", "text/plain", storage.Private), context.Canceled)
+		assert.True(t, s.aborted)
+		assert.NotContains(t, s.objects, "file.txt")
+	})
+
+	t.Run("bounded buffers", func(t *testing.T) {
+		u := gofs.NewUploader(newFakeStorage(), gofs.NewInMemoryDB(), gofs.WithPartSize(128), gofs.WithConcurrency(1))
+
+		var wg sync.WaitGroup
+		for i := 0; i < 4; i++ {
+			key := fmt.Sprintf("file-%d.txt", i)
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				assert.NoError(t, u.UploadFile(context.Background(), bytes.NewReader(data), key, "text/plain", storage.Private))
+			}()
+		}
+		wg.Wait()
+
+		// The buffers of the concurrent uploads above concurrency+2 aren't kept.
+		assert.LessOrEqual(t, gofs.FreeBuffers(u), 3)
+		assert.Positive(t, gofs.FreeBuffers(u))
+	})
+}
+
+func TestUploaderManagedUpload(t *testing.T) {
+	data := bytes.Repeat([]byte("0123456789"), 100)
+
+	t.Run("single request", func(t *testing.T) {
+		s := newFakeStorage()
+		u := gofs.NewUploader(s, gofs.NewInMemoryDB(), gofs.WithPartSize(2048))
+
+		var progress []int64
+		require.NoError(t, u.ManagedUpload(context.Background(), bytes.NewReader(data), int64(len(data)), "file.txt", "text/plain", storage.Private, func(uploaded, total int64) {
+			progress = append(progress, uploaded)
+		}))
+		assert.Equal(t, data, s.objects["file.txt"])
+		assert.Empty(t, s.parts)
+		assert.Equal(t, []int64{int64(len(data))}, progress)
+	})
+
+	t.Run("parts with progress", func(t *testing.T) {
+		s := newFakeStorage()
+		db := gofs.NewInMemoryDB()
+		u := gofs.NewUploader(s, db, gofs.WithPartSize(128), gofs.WithConcurrency(3))
+
+		var progress []int64
+		require.NoError(t, u.ManagedUpload(context.Background(), bytes.NewReader(data), int64(len(data)), "file.txt", "text/plain", storage.Private, func(uploaded, total int64) {
+			assert.Equal(t, int64(len(data)), total)
+			progress = append(progress, uploaded)
+		}))
+		assert.Equal(t, data, s.objects["file.txt"])
+		assert.Len(t, s.parts, 8)
+		assert.IsIncreasing(t, progress)
+		assert.Equal(t, int64(len(data)), progress[len(progress)-1])
+		assert.Empty(t, s.uploads)
+
+		_, err := db.GetUploadID("file.txt")
+		assert.ErrorIs(t, err, gofs.ErrNotFound)
+	})
+
+	t.Run("short reader", func(t *testing.T) {
+		for _, partSize := range []int64{128, 2048} {
+			s := newFakeStorage()
+			u := gofs.NewUploader(s, gofs.NewInMemoryDB(), gofs.WithPartSize(partSize))
+
+			err := u.ManagedUpload(context.Background(), bytes.NewReader(data), int64(len(data))+50, "file.txt", "text/plain", storage.Private, nil)
+			assert.ErrorIs(t, err, storage.ErrSizeMismatch, "part size %d", partSize)
+			assert.NotContains(t, s.objects, "file.txt", "the zero padded content isn't stored")
+		}
+	})
+
+	t.Run("resume", func(t *testing.T) {
+		s := newFakeStorage()
+		s.failures[3] = 1
+		db := gofs.NewInMemoryDB()
+		u := gofs.NewUploader(s, db, gofs.WithPartSize(128), gofs.WithConcurrency(1), gofs.WithMaxRetries(0))
+
+		assert.Error(t, u.ManagedUpload(context.Background(), bytes.NewReader(data), int64(len(data)), "file.txt", "text/plain", storage.Private, nil))
+		assert.False(t, s.aborted)
+		assert.NotContains(t, s.objects, "file.txt")
+		require.Len(t, s.uploads, 1)
+
+		var progress []int64
+		require.NoError(t, u.ManagedUpload(context.Background(), bytes.NewReader(data), int64(len(data)), "file.txt", "text/plain", storage.Private, func(uploaded, total int64) {
+			progress = append(progress, uploaded)
+		}))
+		assert.Equal(t, data, s.objects["file.txt"])
+		assert.Equal(t, 1, s.attempts[1], "uploaded parts are not uploaded again")
+		assert.Equal(t, 2, s.attempts[3])
+		assert.Equal(t, int64(256), progress[0], "progress starts from the uploaded parts")
+		assert.Empty(t, s.uploads)
+	})
+
+	t.Run("restart gone upload", func(t *testing.T) {
+		s := newFakeStorage()
+		s.failures[3] = 1
+		db := gofs.NewInMemoryDB()
+		u := gofs.NewUploader(s, db, gofs.WithPartSize(128), gofs.WithConcurrency(1), gofs.WithMaxRetries(0))
+
+		assert.Error(t, u.ManagedUpload(context.Background(), bytes.NewReader(data), int64(len(data)), "file.txt", "text/plain", storage.Private, nil))
+		s.uploads = nil
+
+		require.NoError(t, u.ManagedUpload(context.Background(), bytes.NewReader(data), int64(len(data)), "file.txt", "text/plain", storage.Private, nil))
+		assert.Equal(t, data, s.objects["file.txt"])
+		assert.Equal(t, 2, s.attempts[1])
+	})
+}
+
+func TestUploaderPlanUpload(t *testing.T) {
+	u := gofs.NewUploader(newFakeStorage(), gofs.NewInMemoryDB())
+
+	plan, err := u.PlanUpload(1024)
+	require.NoError(t, err)
+	assert.Equal(t, gofs.UploadPlan{PartSize: storage.MinPartSize, TotalParts: 1, LastPartSize: 1024}, plan)
+
+	plan, err = u.PlanUpload(100 << 30)
+	require.NoError(t, err)
+	assert.Equal(t, int64(11<<20), plan.PartSize)
+	assert.Equal(t, int64(9310), plan.TotalParts)
+	assert.Equal(t, int64(100<<30)-(plan.TotalParts-1)*plan.PartSize, plan.LastPartSize)
+	assert.True(t, plan.Multipart)
+
+	_, err = u.PlanUpload(storage.MaxParts*storage.MaxPartSize + 1)
+	assert.ErrorIs(t, err, storage.ErrFileTooLarge)
+	_, err = u.PlanUpload(0)
+	assert.ErrorIs(t, err, storage.ErrFileEmpty)
+
+	u = gofs.NewUploader(newFakeStorage(), gofs.NewInMemoryDB(), gofs.WithPartSize(8<<20))
+	plan, err = u.PlanUpload(100 << 20)
+	require.NoError(t, err)
+	assert.Equal(t, gofs.UploadPlan{PartSize: 8 << 20, TotalParts: 13, LastPartSize: 4 << 20, Multipart: true}, plan)
+
+	// The fixed part size is too small for the file.
+	_, err = u.PlanUpload(storage.MaxParts*(8<<20) + 1)
+	assert.ErrorIs(t, err, storage.ErrFileTooLarge)
+}
+
+func TestUploaderDrain(t *testing.T) {
+	data := bytes.Repeat([]byte("0123456789"), 100)
+	s := newFakeStorage()
+	db := gofs.NewInMemoryDB()
+	u := gofs.NewUploader(s, db, gofs.WithPartSize(128), gofs.WithConcurrency(1))
+
+	started, release := make(chan struct{}), make(chan struct{})
+	s.onPart = func(partNum int64) {
+		if partNum == 2 {
+			close(started)
+			<-release
+		}
+	}
+
+	result := make(chan error, 1)
+	go func() {
+		result <- u.ManagedUpload(context.Background(), bytes.NewReader(data), int64(len(data)), "file.txt", "text/plain", storage.Private, nil)
+	}()
+	<-started
+
+	// The in-flight part keeps the drain waiting.
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+	assert.ErrorIs(t, u.Drain(ctx), context.DeadlineExceeded)
+
+	close(release)
+	require.NoError(t, u.Drain(context.Background()))
+	assert.ErrorIs(t, <-result, gofs.ErrUploaderDraining)
+	assert.ErrorIs(t, u.ManagedUpload(context.Background(), bytes.NewReader(data), int64(len(data)), "other.txt", "text/plain", storage.Private, nil), gofs.ErrUploaderDraining)
+
+	status, err := db.GetStatus("file.txt")
+	require.NoError(t, err)
+	assert.Equal(t, int64(2), status.CompletedPartsNum())
+	assert.NotContains(t, s.objects, "file.txt")
+
+	// The drained upload is resumed after the restart.
+	s.onPart = nil
+	u = gofs.NewUploader(s, db, gofs.WithPartSize(128))
+	require.NoError(t, u.ManagedUpload(context.Background(), bytes.NewReader(data), int64(len(data)), "file.txt", "text/plain", storage.Private, nil))
+	assert.Equal(t, data, s.objects["file.txt"])
+	assert.Equal(t, 1, s.attempts[1])
+	assert.Equal(t, 1, s.attempts[2])
+}
+
+func TestUploaderDrainConcurrent(t *testing.T) {
+	data := bytes.Repeat([]byte("0123456789"), 100)
+	s := newFakeStorage()
+	db := gofs.NewInMemoryDB()
+	u := gofs.NewUploader(s, db, gofs.WithPartSize(128), gofs.WithConcurrency(3))
+
+	var started sync.WaitGroup
+	started.Add(3)
+	release := map[int64]chan struct{}{1: make(chan struct{}), 2: make(chan struct{}), 3: make(chan struct{})}
+	s.onPart = func(partNum int64) {
+		if ch, ok := release[partNum]; ok {
+			started.Done()
+			<-ch
+		}
+	}
+
+	uploaded := make(chan struct{}, 3)
+	result := make(chan error, 1)
+	go func() {
+		result <- u.ManagedUpload(context.Background(), bytes.NewReader(data), int64(len(data)), "file.txt", "text/plain", storage.Private, func(_, _ int64) {
+			uploaded <- struct{}{}
+		})
+	}()
+	<-uploaded // the initial progress
+	started.Wait()
+
+	drained := make(chan error, 1)
+	go func() { drained <- u.Drain(context.Background()) }()
+	require.Eventually(t, func() bool {
+		return errors.Is(u.ManagedUpload(context.Background(), bytes.NewReader(data), int64(len(data)), "other.txt", "text/plain", storage.Private, nil), gofs.ErrUploaderDraining)
+	}, time.Second, time.Millisecond)
+
+	// The freed slot doesn't start the next part, which would cancel the in-flight ones.
+	close(release[1])
+	<-uploaded
+	time.Sleep(10 * time.Millisecond)
+	close(release[2])
+	close(release[3])
+
+	assert.ErrorIs(t, <-result, gofs.ErrUploaderDraining)
+	require.NoError(t, <-drained)
+
+	status, err := db.GetStatus("file.txt")
+	require.NoError(t, err)
+	assert.Equal(t, int64(3), status.CompletedPartsNum())
+	assert.Zero(t, s.attempts[4])
+}
+
+func TestPartReader(t *testing.T) {
+	data := []byte("0123456789abcdefghij012")
+
+	var (
+		content []byte
+		sizes   []int64
+	)
+	next := gofs.PartReader(bytes.NewReader(data), 10, int64(len(data)))
+	for want := int64(1); ; want++ {
+		partNum, r, size, ok := next()
+		if !ok {
+			break
+		}
+		assert.Equal(t, want, partNum)
+		part, err := io.ReadAll(r)
+		require.NoError(t, err)
+		assert.Len(t, part, int(size))
+		content = append(content, part...)
+		sizes = append(sizes, size)
+	}
+	assert.Equal(t, []int64{10, 10, 3}, sizes)
+	assert.Equal(t, data, content)
+
+	// The exhausted reader stays exhausted.
+	_, _, _, ok := next()
+	assert.False(t, ok)
+
+	_, _, _, ok = gofs.PartReader(bytes.NewReader(nil), 10, 0)()
+	assert.False(t, ok)
+}
+
+func TestUploaderDistributed(t *testing.T) {
+	data := bytes.Repeat([]byte("0123456789"), 100)
+	const partSize = 128
+	totalParts := int64((len(data) + partSize - 1) / partSize)
+
+	s := newFakeStorage()
+	db := gofs.NewInMemoryDB()
+	u := gofs.NewUploader(s, db)
+
+	_, err := u.StartUpload(context.Background(), "file.txt", totalParts, "text/plain", storage.Private)
+	require.NoError(t, err)
+	_, err = u.StartUpload(context.Background(), "file.txt", totalParts, "text/plain", storage.Private)
+	assert.ErrorIs(t, err, gofs.ErrAlreadyExists)
+
+	upload := func(partNum int64) error {
+		offset, length := gofs.PartRange(int64(len(data)), partSize, partNum)
+		return u.UploadPart(context.Background(), "file.txt", partNum, data[offset:offset+length])
+	}
+	assert.ErrorIs(t, upload(totalParts+1), storage.ErrPartNum)
+
+	// Every worker uploads its own part numbers, the last ones first.
+	var wg sync.WaitGroup
+	for _, start := range []int64{5, 1} {
+		start := start
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for partNum := start; partNum < start+4 && partNum < totalParts; partNum++ {
+				assert.NoError(t, upload(partNum))
+			}
+		}()
+	}
+	wg.Wait()
+
+	// The part uploaded again with the same content is recorded once,
+	// another content conflicts with the recorded part.
+	require.NoError(t, upload(1))
+	conflicting := bytes.Repeat([]byte("x"), partSize)
+	err = u.UploadPart(context.Background(), "file.txt", 1, conflicting)
+	assert.ErrorIs(t, err, gofs.ErrPartConflict)
+
+	ready, err := u.CompleteIfReady(context.Background(), "file.txt")
+	require.NoError(t, err)
+	assert.False(t, ready, "the last part is missed")
+
+	require.NoError(t, upload(totalParts))
+	// The storage keeps the last uploaded part, the record follows it.
+	ready, err = u.CompleteIfReady(context.Background(), "file.txt")
+	require.NoError(t, err)
+	assert.True(t, ready)
+	assert.Equal(t, append(conflicting, data[partSize:]...), s.objects["file.txt"])
+
+	_, err = u.CompleteIfReady(context.Background(), "file.txt")
+	assert.ErrorIs(t, err, gofs.ErrNotFound)
+}
+
+func TestUploaderVerifyParts(t *testing.T) {
+	data := bytes.Repeat([]byte("0123456789"), 30)
+	s := newFakeStorage()
+	db := gofs.NewInMemoryDB()
+	u := gofs.NewUploader(s, db)
+
+	_, err := u.StartUpload(context.Background(), "file.txt", 3, "text/plain", storage.Private)
+	require.NoError(t, err)
+	for partNum := int64(1); partNum <= 3; partNum++ {
+		require.NoError(t, u.UploadPart(context.Background(), "file.txt", partNum, data[(partNum-1)*100:partNum*100]))
+	}
+	require.NoError(t, u.VerifyParts(context.Background(), "file.txt"))
+
+	// The part is re-uploaded by another instance and the other one is gone from the storage.
+	require.NoError(t, db.AddPart("file.txt", 2, "etag-other", 100))
+	delete(s.parts, 3)
+
+	err = u.VerifyParts(context.Background(), "file.txt")
+	assert.ErrorIs(t, err, gofs.ErrPartsMismatch)
+	var mismatch *gofs.PartsMismatchError
+	require.ErrorAs(t, err, &mismatch)
+	assert.Equal(t, []int64{2, 3}, mismatch.PartNumbers)
+
+	assert.ErrorIs(t, u.VerifyParts(context.Background(), "missing.txt"), gofs.ErrNotFound)
+}
+
+func TestUploaderCleanupStaleUploads(t *testing.T) {
+	s := newFakeStorage()
+	s.uploads = []storage.MultipartUpload{
+		{Key: "stale.txt", UploadID: "stale-id", Initiated: time.Now().Add(-48 * time.Hour)},
+		{Key: "replaced.txt", UploadID: "old-id", Initiated: time.Now().Add(-48 * time.Hour)},
+		{Key: "fresh.txt", UploadID: "fresh-id", Initiated: time.Now().Add(-time.Minute)},
+	}
+
+	db := gofs.NewInMemoryDB()
+	require.NoError(t, db.CreateUpload("stale.txt", "stale-id", 2))
+	require.NoError(t, db.CreateUpload("replaced.txt", "new-id", 2))
+	require.NoError(t, db.CreateUpload("fresh.txt", "fresh-id", 2))
+
+	u := gofs.NewUploader(s, db)
+	aborted, err := u.CleanupStaleUploads(24 * time.Hour)
+	require.NoError(t, err)
+	assert.Equal(t, 2, aborted)
+
+	uploads, err := db.ListUploads()
+	require.NoError(t, err)
+	assert.Equal(t, []string{"fresh.txt", "replaced.txt"}, uploads)
+}
+
+// failingAbortStorage fails to abort the uploads.
+type failingAbortStorage struct {
+	*fakeStorage
+}
+
+func (s failingAbortStorage) AbortMultipartUpload(filename, uploadID string) error {
+	return errors.New("abort failed: " + uploadID)
+}
+
+func TestUploaderCleanupStaleUploadsFailed(t *testing.T) {
+	now := time.Now()
+	s := newFakeStorage()
+	s.uploads = []storage.MultipartUpload{
+		{Key: "file.txt", UploadID: "first-id", Initiated: now},
+		{Key: "file.txt", UploadID: "second-id", Initiated: now},
+	}
+
+	// The uploads are stale by the clock of the uploader.
+	u := gofs.NewUploader(failingAbortStorage{s}, gofs.NewInMemoryDB())
+	gofs.SetClock(u, func() time.Time { return now.Add(48 * time.Hour) })
+
+	aborted, err := u.CleanupStaleUploads(24 * time.Hour)
+	assert.Zero(t, aborted)
+	var batchErr *storage.BatchError
+	require.ErrorAs(t, err, &batchErr)
+	assert.Len(t, batchErr.Errors, 2)
+	assert.Contains(t, batchErr.Errors, "first-id")
+	assert.Contains(t, batchErr.Errors, "second-id")
+}
+
+// dryRunStorage is the storage in the dry-run mode: only the failed uploads are aborted.
+type dryRunStorage struct {
+	*fakeStorage
+}
+
+func (s dryRunStorage) AbortMultipartUpload(filename, uploadID string) error {
+	return nil
+}
+
+func (s dryRunStorage) AbortFailedUpload(filename, uploadID string) error {
+	return s.fakeStorage.AbortMultipartUpload(filename, uploadID)
+}
+
+func (s dryRunStorage) DryRun() bool {
+	return true
+}
+
+func TestUploaderDryRun(t *testing.T) {
+	t.Run("failed upload is aborted", func(t *testing.T) {
+		s := newFakeStorage()
+		s.failures[2] = 10
+		u := gofs.NewUploader(dryRunStorage{s}, gofs.NewInMemoryDB(), gofs.WithPartSize(128), gofs.WithRetryBackoff(0), gofs.WithMaxRetries(0))
+
+		assert.Error(t, u.UploadFile(context.Background(), bytes.NewReader(make([]byte, 1000)), "file.txt", "text/plain", storage.Private))
+		assert.True(t, s.aborted)
+		assert.Empty(t, s.uploads)
+	})
+
+	t.Run("stale uploads are only counted", func(t *testing.T) {
+		s := newFakeStorage()
+		s.uploads = []storage.MultipartUpload{
+			{Key: "stale.txt", UploadID: "stale-id", Initiated: time.Now().Add(-48 * time.Hour)},
+		}
+		db := gofs.NewInMemoryDB()
+		require.NoError(t, db.CreateUpload("stale.txt", "stale-id", 2))
+
+		aborted, err := gofs.NewUploader(dryRunStorage{s}, db).CleanupStaleUploads(24 * time.Hour)
+		require.NoError(t, err)
+		assert.Equal(t, 1, aborted)
+		assert.Len(t, s.uploads, 1)
+
+		uploadID, err := db.GetUploadID("stale.txt")
+		require.NoError(t, err)
+		assert.Equal(t, "stale-id", uploadID)
+	})
+}
+
+// recordLogger keeps the debug messages.
+type recordLogger struct {
+	sync.Mutex
+	messages []string
+}
+
+func (l *recordLogger) Debugf(format string, args ...interface{}) {
+	l.Lock()
+	defer l.Unlock()
+	l.messages = append(l.messages, fmt.Sprintf(format, args...))
+}
+
+func (l *recordLogger) Errorf(string, ...interface{}) {}
+
+func TestJitterStrategyDelay(t *testing.T) {
+	const backoff = 100 * time.Millisecond
+
+	for i := 0; i < 100; i++ {
+		d := gofs.JitterFull.Delay(backoff)
+		assert.True(t, d >= 0 && d <= backoff, d)
+
+		d = gofs.JitterEqual.Delay(backoff)
+		assert.True(t, d >= backoff/2 && d <= backoff, d)
+	}
+	assert.Equal(t, backoff, gofs.JitterNone.Delay(backoff))
+	assert.Zero(t, gofs.JitterFull.Delay(0))
+	assert.False(t, gofs.JitterStrategy("random").IsValid())
+}
+
+func TestUploaderRetryJitter(t *testing.T) {
+	data := bytes.Repeat([]byte("0123456789"), 100)
+	s := newFakeStorage()
+	s.failures[2] = 2
+	logger := &recordLogger{}
+	u := gofs.NewUploader(s, gofs.NewInMemoryDB(),
+		gofs.WithPartSize(128),
+		gofs.WithRetryBackoff(time.Millisecond),
+		gofs.WithJitter(gofs.JitterNone),
+		gofs.WithJitter("random"), // ignored
+		gofs.WithLogger(logger),
+	)
+
+	require.NoError(t, u.UploadFile(context.Background(), bytes.NewReader(data), "file.txt", "text/plain", storage.Private))
+	require.Len(t, logger.messages, 2)
+	assert.Contains(t, logger.messages[0], "retry 1 of 3 in 1ms")
+	assert.Contains(t, logger.messages[1], "retry 2 of 3 in 2ms")
+}
+
+type ctxKey struct{}
+
+// contextDB records the values of the contexts passed to the database.
+type contextDB struct {
+	gofs.DB
+	mu     sync.Mutex
+	values []interface{}
+}
+
+func (db *contextDB) record(ctx context.Context) {
+	db.mu.Lock()
+	defer db.mu.Unlock()
+	db.values = append(db.values, ctx.Value(ctxKey{}))
+}
+
+func (db *contextDB) CreateUploadWithContext(ctx context.Context, key string, uploadID string, totalParts int64) error {
+	db.record(ctx)
+	return db.CreateUpload(key, uploadID, totalParts)
+}
+
+func (db *contextDB) UpdateTotalPartsWithContext(ctx context.Context, key string, totalParts int64) error {
+	db.record(ctx)
+	return db.UpdateTotalParts(key, totalParts)
+}
+
+func (db *contextDB) AddPartWithContext(ctx context.Context, key string, partNumber int64, etag string, size int64) error {
+	db.record(ctx)
+	return db.AddPart(key, partNumber, etag, size)
+}
+
+func (db *contextDB) CompleteUploadWithContext(ctx context.Context, key string) error {
+	db.record(ctx)
+	return db.CompleteUpload(key)
+}
+
+func (db *contextDB) AbortUploadWithContext(ctx context.Context, key string) error {
+	db.record(ctx)
+	return db.AbortUpload(key)
+}
+
+func (db *contextDB) GetUploadIDWithContext(ctx context.Context, key string) (string, error) {
+	db.record(ctx)
+	return db.GetUploadID(key)
+}
+
+func (db *contextDB) GetPartsWithContext(ctx context.Context, key string) ([]gofs.CompletedPart, error) {
+	db.record(ctx)
+	return db.GetParts(key)
+}
+
+func (db *contextDB) GetStatusWithContext(ctx context.Context, key string) (gofs.UploadStatus, error) {
+	db.record(ctx)
+	return db.GetStatus(key)
+}
+
+func (db *contextDB) ListUploadsWithContext(ctx context.Context) ([]string, error) {
+	db.record(ctx)
+	return db.ListUploads()
+}
+
+func TestUploaderContextDB(t *testing.T) {
+	data := bytes.Repeat([]byte("0123456789"), 100)
+	s := newFakeStorage()
+	db := &contextDB{DB: gofs.NewInMemoryDB()}
+	u := gofs.NewUploader(s, db, gofs.WithPartSize(128))
+
+	ctx := context.WithValue(context.Background(), ctxKey{}, "upload")
+	require.NoError(t, u.UploadFile(ctx, bytes.NewReader(data), "file.txt", "text/plain", storage.Private))
+	assert.Equal(t, data, s.objects["file.txt"])
+
+	// CreateUpload, AddPart of each of 8 parts and CompleteUpload.
+	require.Len(t, db.values, 10)
+	for _, v := range db.values {
+		assert.Equal(t, "upload", v)
+	}
+}